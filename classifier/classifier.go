@@ -0,0 +1,238 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Package classifier finds the PDR matching a packet,
+// following the precedence and PDI rules of TS 29.244, section 5.2.1.
+package classifier
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/nextmn/go-pfcp-networking/sdf"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// Length of IPv6 prefixes when no length is provided
+const defaultIPv6PrefixLength = 64
+
+// A Packet is a decoded packet, as received by the UP function
+type Packet struct {
+	SourceInterface uint8 // ie.SrcInterfaceAccess, ie.SrcInterfaceCore, …
+	// Network Instance the packet is received on; when empty, Network Instance of PDIs is not checked
+	NetworkInstance string
+
+	// GTP-U encapsulation (HasTEID is false for packets that are not encapsulated)
+	HasTEID      bool
+	TEID         uint32
+	LocalAddress net.IP // destination address of the GTP-U packet; when nil, it is not checked
+	HasQFI       bool
+	QFI          uint8
+
+	// IP packet (inner packet for GTP-U encapsulated packets)
+	SrcIP    net.IP
+	DstIP    net.IP
+	Protocol uint8
+	SrcPort  uint16
+	DstPort  uint16
+
+	TrafficClass uint8 // IPv4 ToS or IPv6 Traffic Class
+	HasSPI       bool  // true for IPsec packets
+	SPI          uint32
+	FlowLabel    uint32 // IPv6 only
+}
+
+// A Match is the PDR matching a packet, with its session and FAR
+type Match struct {
+	Session    api.PFCPSessionInterface
+	PDR        api.PDRInterface
+	FAR        api.FARInterface
+	Precedence uint32
+}
+
+// A Classifier matches packets against PDRs of the sessions of an entity
+type Classifier struct {
+	sessions func() []api.PFCPSessionInterface
+}
+
+// Create a Classifier using sessions of entity
+func New(entity api.PFCPEntityInterface) *Classifier {
+	return &Classifier{sessions: entity.GetPFCPSessions}
+}
+
+// Create a Classifier using sessions returned by the function
+func NewWithSessions(sessions func() []api.PFCPSessionInterface) *Classifier {
+	return &Classifier{sessions: sessions}
+}
+
+// Returns the PDR with the highest precedence among PDRs matching the packet
+func (c *Classifier) Classify(p *Packet) (*Match, error) {
+	var best *Match
+	for _, session := range c.sessions() {
+		m, err := ClassifySession(session, p)
+		if err != nil {
+			continue
+		}
+		if best == nil || m.Precedence < best.Precedence {
+			best = m
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("No PDR matches the packet")
+	}
+	return best, nil
+}
+
+// Returns the PDR of a session with the highest precedence among PDRs matching the packet
+func ClassifySession(session api.PFCPSessionInterface, p *Packet) (*Match, error) {
+	session.RLock()
+	defer session.RUnlock()
+	// PDRs are sorted by precedence: the first matching PDR is the right one
+	for _, id := range session.GetSortedPDRIDs() {
+		pdr, err := session.GetPDR(id)
+		if err != nil {
+			continue
+		}
+		if !MatchPDI(pdr, p) {
+			continue
+		}
+		precedence, err := pdr.Precedence()
+		if err != nil {
+			continue
+		}
+		farid, err := pdr.FARID()
+		if err != nil {
+			return nil, err
+		}
+		far, err := session.GetFAR(farid)
+		if err != nil {
+			return nil, err
+		}
+		return &Match{
+			Session:    session,
+			PDR:        pdr,
+			FAR:        far,
+			Precedence: precedence,
+		}, nil
+	}
+	return nil, fmt.Errorf("No PDR of the session matches the packet")
+}
+
+// Returns true if the packet matches every IE of the PDI of the PDR
+func MatchPDI(pdr api.PDRInterface, p *Packet) bool {
+	pdi, err := pdr.PDIFields()
+	if err != nil || pdi.SourceInterface != p.SourceInterface {
+		return false
+	}
+	if pdi.NetworkInstance != "" && p.NetworkInstance != "" && pdi.NetworkInstance != p.NetworkInstance {
+		return false
+	}
+	if pdi.LocalFTEID != nil && !matchFTEID(pdi.LocalFTEID, p) {
+		return false
+	}
+	assigned := make([]*net.IPNet, 0)
+	if pdi.UEIPAddress != nil {
+		if !matchUEIPAddress(pdi.UEIPAddress, p) {
+			return false
+		}
+		assigned = ueIPNetworks(pdi.UEIPAddress)
+	}
+	if len(pdi.SDFFilters) > 0 && !matchSDFFilters(pdi.SDFFilters, assigned, p) {
+		return false
+	}
+	if len(pdi.QFIs) > 0 {
+		if !p.HasQFI {
+			return false
+		}
+		found := false
+		for _, qfi := range pdi.QFIs {
+			if qfi == p.QFI {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func matchFTEID(fteid *ie.FTEIDFields, p *Packet) bool {
+	if !p.HasTEID || fteid.HasCh() || fteid.TEID != p.TEID {
+		return false
+	}
+	if p.LocalAddress == nil {
+		return true
+	}
+	if ip4 := p.LocalAddress.To4(); ip4 != nil {
+		return fteid.HasIPv4() && fteid.IPv4Address.Equal(ip4)
+	}
+	return fteid.HasIPv6() && fteid.IPv6Address.Equal(p.LocalAddress)
+}
+
+func matchUEIPAddress(ueip *ie.UEIPAddressFields, p *Packet) bool {
+	addr := p.SrcIP
+	if ueip.Flags&api.UEIPFlagSD != 0 {
+		addr = p.DstIP
+	}
+	if addr == nil {
+		return false
+	}
+	if ip4 := addr.To4(); ip4 != nil {
+		return ueip.Flags&api.UEIPFlagV4 != 0 && ueip.IPv4Address.Equal(ip4)
+	}
+	if ueip.Flags&api.UEIPFlagV6 == 0 || ueip.IPv6Address == nil {
+		return false
+	}
+	return ueIPv6Prefix(ueip).Contains(addr)
+}
+
+func ueIPv6Prefix(ueip *ie.UEIPAddressFields) *net.IPNet {
+	prefixLength := defaultIPv6PrefixLength
+	if ueip.Flags&api.UEIPFlagIP6PL != 0 {
+		prefixLength = int(ueip.IPv6PrefixLength)
+	}
+	return &net.IPNet{IP: ueip.IPv6Address, Mask: net.CIDRMask(prefixLength, 128)}
+}
+
+// Returns addresses of the UE, used for the keyword "assigned" of Flow Descriptions
+func ueIPNetworks(ueip *ie.UEIPAddressFields) []*net.IPNet {
+	networks := make([]*net.IPNet, 0)
+	if ueip.Flags&api.UEIPFlagV4 != 0 && ueip.IPv4Address != nil {
+		networks = append(networks, &net.IPNet{IP: ueip.IPv4Address, Mask: net.CIDRMask(32, 32)})
+	}
+	if ueip.Flags&api.UEIPFlagV6 != 0 && ueip.IPv6Address != nil {
+		networks = append(networks, ueIPv6Prefix(ueip))
+	}
+	return networks
+}
+
+// Returns true if the packet matches at least one of the SDF Filters
+func matchSDFFilters(filters []*sdf.Filter, assigned []*net.IPNet, p *Packet) bool {
+	// Packets received from the Access side are uplink packets
+	dir := sdf.DirectionOut
+	if p.SourceInterface == ie.SrcInterfaceAccess {
+		dir = sdf.DirectionIn
+	}
+	flow := sdf.Flow{
+		SrcIP:        p.SrcIP,
+		DstIP:        p.DstIP,
+		Protocol:     p.Protocol,
+		SrcPort:      p.SrcPort,
+		DstPort:      p.DstPort,
+		TrafficClass: p.TrafficClass,
+		HasSPI:       p.HasSPI,
+		SPI:          p.SPI,
+		FlowLabel:    p.FlowLabel,
+	}
+	for _, filter := range filters {
+		if filter.Match(&flow, dir, assigned) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package classifier
+
+import (
+	"net"
+	"testing"
+
+	pfcp_networking "github.com/nextmn/go-pfcp-networking/pfcp"
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// A session holding only PDRs and FARs
+type testSession struct {
+	api.PFCPSessionInterface
+	pdrs *pfcp_networking.PDRMap
+	fars *pfcp_networking.FARMap
+}
+
+func newTestSession(t *testing.T, pdrs ...*ie.IE) *testSession {
+	t.Helper()
+	fars := make([]*ie.IE, 0, len(pdrs))
+	for _, pdr := range pdrs {
+		farid, err := pdr.FARID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fars = append(fars, ie.NewCreateFAR(ie.NewFARID(farid), ie.NewApplyAction(0x02)))
+	}
+	pdrMap, err := pfcp_networking.NewPDRMap(pdrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	farMap, err := pfcp_networking.NewFARMap(fars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSession{pdrs: pdrMap, fars: farMap}
+}
+
+func (s *testSession) RLock()                       {}
+func (s *testSession) RUnlock()                     {}
+func (s *testSession) GetSortedPDRIDs() []api.PDRID { return s.pdrs.GetSortedPDRIDs() }
+func (s *testSession) GetPDR(id api.PDRID) (api.PDRInterface, error) {
+	return s.pdrs.Get(id)
+}
+func (s *testSession) GetFAR(id api.FARID) (api.FARInterface, error) {
+	return s.fars.Get(id)
+}
+
+func newTestPDR(id uint16, precedence uint32, pdi ...*ie.IE) *ie.IE {
+	return ie.NewCreatePDR(ie.NewPDRID(id), ie.NewPrecedence(precedence), ie.NewPDI(pdi...), ie.NewFARID(uint32(id)))
+}
+
+func TestMatchPDI(t *testing.T) {
+	access := ie.NewSourceInterface(ie.SrcInterfaceAccess)
+	core := ie.NewSourceInterface(ie.SrcInterfaceCore)
+	fteid := ie.NewFTEID(0x01, 1, net.ParseIP("10.0.0.2"), nil, 0)
+	ueip := ie.NewUEIPAddress(api.UEIPFlagV4, "10.45.0.1", "", 0, 0)
+	ueipSD := ie.NewUEIPAddress(api.UEIPFlagV4|api.UEIPFlagSD, "10.45.0.1", "", 0, 0)
+	ue, dn := net.ParseIP("10.45.0.1"), net.ParseIP("192.0.2.1")
+	uplink := func(p Packet) *Packet {
+		p.SourceInterface = ie.SrcInterfaceAccess
+		p.HasTEID, p.TEID, p.LocalAddress = true, 1, net.ParseIP("10.0.0.2")
+		p.SrcIP, p.DstIP = ue, dn
+		return &p
+	}
+	downlink := func(p Packet) *Packet {
+		p.SourceInterface = ie.SrcInterfaceCore
+		p.SrcIP, p.DstIP = dn, ue
+		return &p
+	}
+	for _, tc := range []struct {
+		name   string
+		pdi    []*ie.IE
+		packet *Packet
+		match  bool
+	}{
+		{name: "Source Interface", pdi: []*ie.IE{access}, packet: uplink(Packet{}), match: true},
+		{name: "wrong Source Interface", pdi: []*ie.IE{core}, packet: uplink(Packet{}), match: false},
+		{name: "Network Instance", pdi: []*ie.IE{core, ie.NewNetworkInstance("internet")}, packet: downlink(Packet{NetworkInstance: "internet"}), match: true},
+		{name: "wrong Network Instance", pdi: []*ie.IE{core, ie.NewNetworkInstance("internet")}, packet: downlink(Packet{NetworkInstance: "ims"}), match: false},
+		{name: "Network Instance not checked", pdi: []*ie.IE{core, ie.NewNetworkInstance("internet")}, packet: downlink(Packet{}), match: true},
+
+		{name: "F-TEID", pdi: []*ie.IE{access, fteid}, packet: uplink(Packet{}), match: true},
+		{name: "wrong TEID", pdi: []*ie.IE{access, ie.NewFTEID(0x01, 2, net.ParseIP("10.0.0.2"), nil, 0)}, packet: uplink(Packet{}), match: false},
+		{name: "wrong F-TEID address", pdi: []*ie.IE{access, ie.NewFTEID(0x01, 1, net.ParseIP("10.0.0.3"), nil, 0)}, packet: uplink(Packet{}), match: false},
+		{name: "F-TEID address not checked", pdi: []*ie.IE{access, fteid}, packet: &Packet{SourceInterface: ie.SrcInterfaceAccess, HasTEID: true, TEID: 1}, match: true},
+		{name: "F-TEID IPv6", pdi: []*ie.IE{access, ie.NewFTEID(0x02, 1, nil, net.ParseIP("fd00::2"), 0)}, packet: &Packet{SourceInterface: ie.SrcInterfaceAccess, HasTEID: true, TEID: 1, LocalAddress: net.ParseIP("fd00::2")}, match: true},
+		{name: "F-TEID IPv6 and IPv4 address", pdi: []*ie.IE{access, ie.NewFTEID(0x02, 1, nil, net.ParseIP("fd00::2"), 0)}, packet: uplink(Packet{}), match: false},
+		{name: "F-TEID not encapsulated", pdi: []*ie.IE{access, fteid}, packet: &Packet{SourceInterface: ie.SrcInterfaceAccess, SrcIP: ue, DstIP: dn}, match: false},
+		{name: "F-TEID to be allocated", pdi: []*ie.IE{access, ie.NewFTEID(0x05, 0, nil, nil, 0)}, packet: uplink(Packet{}), match: false},
+
+		{name: "UE IP address source", pdi: []*ie.IE{access, fteid, ueip}, packet: uplink(Packet{}), match: true},
+		{name: "UE IP address source on downlink", pdi: []*ie.IE{core, ueip}, packet: downlink(Packet{}), match: false},
+		{name: "UE IP address destination", pdi: []*ie.IE{core, ueipSD}, packet: downlink(Packet{}), match: true},
+		{name: "UE IP address destination on uplink", pdi: []*ie.IE{access, fteid, ueipSD}, packet: uplink(Packet{}), match: false},
+		{name: "UE IPv6 prefix", pdi: []*ie.IE{core, ie.NewUEIPAddress(api.UEIPFlagV6|api.UEIPFlagSD, "", "2001:db8:0:1::", 0, 0)}, packet: &Packet{SourceInterface: ie.SrcInterfaceCore, SrcIP: net.ParseIP("2001:db8:ff::1"), DstIP: net.ParseIP("2001:db8:0:1::5")}, match: true},
+		{name: "UE IPv6 prefix length", pdi: []*ie.IE{core, ie.NewUEIPAddress(api.UEIPFlagV6|api.UEIPFlagSD|api.UEIPFlagIP6PL, "", "2001:db8::", 0, 56)}, packet: &Packet{SourceInterface: ie.SrcInterfaceCore, DstIP: net.ParseIP("2001:db8:0:ff::1")}, match: true},
+		{name: "outside UE IPv6 prefix", pdi: []*ie.IE{core, ie.NewUEIPAddress(api.UEIPFlagV6|api.UEIPFlagSD, "", "2001:db8:0:1::", 0, 0)}, packet: &Packet{SourceInterface: ie.SrcInterfaceCore, DstIP: net.ParseIP("2001:db8:0:2::1")}, match: false},
+
+		// Flow Descriptions are written for downlink packets, and are swapped for uplink packets
+		{name: "SDF Filter downlink", pdi: []*ie.IE{core, ueipSD, ie.NewSDFFilter("permit out 17 from 192.0.2.1 53 to assigned", "", "", "", 0)}, packet: downlink(Packet{Protocol: 17, SrcPort: 53, DstPort: 1024}), match: true},
+		{name: "SDF Filter uplink", pdi: []*ie.IE{access, fteid, ueip, ie.NewSDFFilter("permit out 17 from 192.0.2.1 53 to assigned", "", "", "", 0)}, packet: uplink(Packet{Protocol: 17, SrcPort: 1024, DstPort: 53}), match: true},
+		{name: "SDF Filter uplink not swapped", pdi: []*ie.IE{access, fteid, ueip, ie.NewSDFFilter("permit out 17 from 192.0.2.1 53 to assigned", "", "", "", 0)}, packet: uplink(Packet{Protocol: 17, SrcPort: 53, DstPort: 1024}), match: false},
+		{name: "SDF Filter wrong protocol", pdi: []*ie.IE{core, ueipSD, ie.NewSDFFilter("permit out 17 from 192.0.2.1 to assigned", "", "", "", 0)}, packet: downlink(Packet{Protocol: 6}), match: false},
+		{name: "one of the SDF Filters", pdi: []*ie.IE{core, ueipSD,
+			ie.NewSDFFilter("permit out 6 from any to assigned", "", "", "", 0),
+			ie.NewSDFFilter("permit out 17 from any to assigned", "", "", "", 0),
+		}, packet: downlink(Packet{Protocol: 17}), match: true},
+		{name: "SDF Filter ToS", pdi: []*ie.IE{core, ie.NewSDFFilter("", "\xb8\xfc", "", "", 0)}, packet: downlink(Packet{TrafficClass: 0xb9}), match: true},
+		{name: "SDF Filter wrong ToS", pdi: []*ie.IE{core, ie.NewSDFFilter("", "\xb8\xfc", "", "", 0)}, packet: downlink(Packet{TrafficClass: 0x00}), match: false},
+
+		{name: "QFI", pdi: []*ie.IE{access, fteid, ie.NewQFI(9)}, packet: uplink(Packet{HasQFI: true, QFI: 9}), match: true},
+		{name: "one of the QFIs", pdi: []*ie.IE{access, fteid, ie.NewQFI(5), ie.NewQFI(9)}, packet: uplink(Packet{HasQFI: true, QFI: 9}), match: true},
+		{name: "wrong QFI", pdi: []*ie.IE{access, fteid, ie.NewQFI(5)}, packet: uplink(Packet{HasQFI: true, QFI: 9}), match: false},
+		{name: "missing QFI", pdi: []*ie.IE{access, fteid, ie.NewQFI(9)}, packet: uplink(Packet{}), match: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestSession(t, newTestPDR(1, 1, tc.pdi...))
+			pdr, err := s.GetPDR(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match := MatchPDI(pdr, tc.packet); match != tc.match {
+				t.Errorf("got %t, expected %t", match, tc.match)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	access := ie.NewSourceInterface(ie.SrcInterfaceAccess)
+	fteid := func(teid uint32) *ie.IE {
+		return ie.NewFTEID(0x01, teid, net.ParseIP("10.0.0.2"), nil, 0)
+	}
+	dns := ie.NewSDFFilter("permit out 17 from any 53 to assigned", "", "", "", 0)
+	ueip := ie.NewUEIPAddress(api.UEIPFlagV4, "10.45.0.1", "", 0, 0)
+	packet := func(teid uint32, dstPort uint16) *Packet {
+		return &Packet{
+			SourceInterface: ie.SrcInterfaceAccess,
+			HasTEID:         true,
+			TEID:            teid,
+			SrcIP:           net.ParseIP("10.45.0.1"),
+			DstIP:           net.ParseIP("192.0.2.1"),
+			Protocol:        17,
+			SrcPort:         1024,
+			DstPort:         dstPort,
+		}
+	}
+	sessions := []api.PFCPSessionInterface{
+		// both PDRs match DNS packets
+		newTestSession(t,
+			newTestPDR(1, 10, access, fteid(1)),
+			newTestPDR(2, 5, access, fteid(1), ueip, dns),
+		),
+		// PDRs of different sessions matching the same packet
+		newTestSession(t, newTestPDR(3, 20, access, fteid(2))),
+		newTestSession(t, newTestPDR(4, 3, access, fteid(2), ueip)),
+	}
+	c := NewWithSessions(func() []api.PFCPSessionInterface { return sessions })
+	for _, tc := range []struct {
+		name    string
+		packet  *Packet
+		pdr     api.PDRID
+		session api.PFCPSessionInterface
+		noMatch bool
+	}{
+		{name: "highest precedence", packet: packet(1, 53), pdr: 2, session: sessions[0]},
+		{name: "lowest precedence", packet: packet(1, 80), pdr: 1, session: sessions[0]},
+		{name: "highest precedence among sessions", packet: packet(2, 80), pdr: 4, session: sessions[2]},
+		{name: "no match", packet: packet(3, 53), noMatch: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := c.Classify(tc.packet)
+			if tc.noMatch {
+				if err == nil {
+					t.Fatalf("packet matches PDR %v", m.PDR)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, err := m.PDR.ID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != tc.pdr {
+				t.Errorf("got PDR %d, expected %d", id, tc.pdr)
+			}
+			if m.Session != tc.session {
+				t.Errorf("got PDR of another session")
+			}
+			precedence, _ := m.PDR.Precedence()
+			if m.Precedence != precedence {
+				t.Errorf("got precedence %d, expected %d", m.Precedence, precedence)
+			}
+			farid, err := m.FAR.ID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if farid != api.FARID(tc.pdr) {
+				t.Errorf("got FAR %d, expected %d", farid, tc.pdr)
+			}
+		})
+	}
+}
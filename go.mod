@@ -4,4 +4,7 @@ go 1.21
 
 toolchain go1.21.8
 
-require github.com/wmnsk/go-pfcp v0.0.24
+require (
+	github.com/wmnsk/go-pfcp v0.0.24
+	gopkg.in/yaml.v3 v3.0.1
+)
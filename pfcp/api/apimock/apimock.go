@@ -0,0 +1,63 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Package apimock provides mocks of every interface of package api,
+// to unit test code using PFCP entities without sockets.
+// Behaviour of a mock is configured by setting its Func fields,
+// and every call is recorded.
+//
+// Mocks are generated from package api: run go generate after changing an interface.
+package apimock
+
+//go:generate go run ./gen -src .. -out mocks.go
+
+import "sync"
+
+// A Call is a recorded call to a method of a mock
+type Call struct {
+	Method string
+	Args   []any
+}
+
+// Calls recorded by a mock
+type calls struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (c *calls) record(method string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, Call{Method: method, Args: args})
+}
+
+// Returns every recorded call, in order
+func (c *calls) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := make([]Call, len(c.calls))
+	copy(calls, c.calls)
+	return calls
+}
+
+// Returns recorded calls of a method, in order
+func (c *calls) CallsTo(method string) []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := make([]Call, 0)
+	for _, call := range c.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Forget recorded calls
+func (c *calls) ResetCalls() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = nil
+}
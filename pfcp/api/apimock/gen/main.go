@@ -0,0 +1,375 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Command gen generates mocks of every interface of package api.
+// It is run by go generate in package apimock.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	apiImportPath = "github.com/nextmn/go-pfcp-networking/pfcp/api"
+	header        = `// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Code generated by gen; DO NOT EDIT.
+
+`
+)
+
+type method struct {
+	name    string
+	params  []field
+	results []field
+	imports map[string]string // path of imports used by the method, by name
+}
+
+type field struct {
+	name     string
+	typ      string
+	variadic bool
+}
+
+type iface struct {
+	name     string
+	embedded []string
+	methods  []method
+}
+
+type generator struct {
+	fset       *token.FileSet
+	localTypes map[string]bool
+	interfaces map[string]*iface
+	imports    map[string]string // path of imports used by generated code, by name
+}
+
+func main() {
+	src := flag.String("src", "..", "directory of package api")
+	out := flag.String("out", "mocks.go", "generated file")
+	flag.Parse()
+	g := &generator{
+		fset:       token.NewFileSet(),
+		localTypes: make(map[string]bool),
+		interfaces: make(map[string]*iface),
+		imports:    map[string]string{"api": apiImportPath},
+	}
+	if err := g.parse(*src); err != nil {
+		log.Fatal(err)
+	}
+	b, err := g.generate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, b, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (g *generator) parse(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	files := make([]*ast.File, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(g.fset, path.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+	// first pass: names of types declared in package api
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				for _, spec := range gen.Specs {
+					g.localTypes[spec.(*ast.TypeSpec).Name.Name] = true
+				}
+			}
+		}
+	}
+	// second pass: interfaces
+	for _, f := range files {
+		imports := fileImports(f)
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				it, ok := ts.Type.(*ast.InterfaceType)
+				if !ok || !ts.Name.IsExported() {
+					continue
+				}
+				i := &iface{name: ts.Name.Name}
+				for _, m := range it.Methods.List {
+					switch t := m.Type.(type) {
+					case *ast.FuncType:
+						used := make(map[string]string)
+						for _, name := range m.Names {
+							i.methods = append(i.methods, method{
+								name:    name.Name,
+								params:  g.fields(t.Params, "p", imports, used),
+								results: g.fields(t.Results, "r", imports, used),
+								imports: used,
+							})
+						}
+					case *ast.Ident:
+						i.embedded = append(i.embedded, t.Name)
+					default:
+						return fmt.Errorf("%s: unsupported embedded interface", g.fset.Position(m.Pos()))
+					}
+				}
+				g.interfaces[i.name] = i
+			}
+		}
+	}
+	return nil
+}
+
+// Returns imported packages of a file, by name
+func fileImports(f *ast.File) map[string]string {
+	imports := make(map[string]string)
+	for _, spec := range f.Imports {
+		p, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(p)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = p
+	}
+	return imports
+}
+
+func (g *generator) fields(list *ast.FieldList, prefix string, imports map[string]string, used map[string]string) []field {
+	fields := make([]field, 0)
+	if list == nil {
+		return fields
+	}
+	for _, f := range list.List {
+		typ := f.Type
+		variadic := false
+		if e, ok := typ.(*ast.Ellipsis); ok {
+			typ = e.Elt
+			variadic = true
+		}
+		s := g.typeString(typ, imports, used)
+		if len(f.Names) == 0 {
+			fields = append(fields, field{name: fmt.Sprintf("%s%d", prefix, len(fields)), typ: s, variadic: variadic})
+			continue
+		}
+		for _, name := range f.Names {
+			n := name.Name
+			if n == "_" {
+				n = fmt.Sprintf("%s%d", prefix, len(fields))
+			}
+			fields = append(fields, field{name: n, typ: s, variadic: variadic})
+		}
+	}
+	return fields
+}
+
+// Returns the type as seen from package apimock
+func (g *generator) typeString(expr ast.Expr, imports map[string]string, used map[string]string) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if g.localTypes[t.Name] {
+			return "api." + t.Name
+		}
+		return t.Name
+	case *ast.SelectorExpr:
+		pkg := t.X.(*ast.Ident).Name
+		used[pkg] = imports[pkg]
+		return pkg + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + g.typeString(t.X, imports, used)
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return "[" + t.Len.(*ast.BasicLit).Value + "]" + g.typeString(t.Elt, imports, used)
+		}
+		return "[]" + g.typeString(t.Elt, imports, used)
+	case *ast.MapType:
+		return "map[" + g.typeString(t.Key, imports, used) + "]" + g.typeString(t.Value, imports, used)
+	case *ast.Ellipsis:
+		return "..." + g.typeString(t.Elt, imports, used)
+	case *ast.ChanType:
+		switch t.Dir {
+		case ast.SEND:
+			return "chan<- " + g.typeString(t.Value, imports, used)
+		case ast.RECV:
+			return "<-chan " + g.typeString(t.Value, imports, used)
+		default:
+			return "chan " + g.typeString(t.Value, imports, used)
+		}
+	case *ast.FuncType:
+		params := g.fields(t.Params, "p", imports, used)
+		results := g.fields(t.Results, "r", imports, used)
+		return "func(" + joinTypes(params) + ")" + resultTypes(results)
+	case *ast.InterfaceType:
+		if len(t.Methods.List) == 0 {
+			return "any"
+		}
+	case *ast.StructType:
+		if len(t.Fields.List) == 0 {
+			return "struct{}"
+		}
+	}
+	log.Fatalf("%s: unsupported type", g.fset.Position(expr.Pos()))
+	return ""
+}
+
+func joinTypes(fields []field) string {
+	s := make([]string, len(fields))
+	for i, f := range fields {
+		if f.variadic {
+			s[i] = "..." + f.typ
+		} else {
+			s[i] = f.typ
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+func resultTypes(results []field) string {
+	switch len(results) {
+	case 0:
+		return ""
+	case 1:
+		return " " + results[0].typ
+	default:
+		return " (" + joinTypes(results) + ")"
+	}
+}
+
+// Returns methods of an interface, including methods of embedded interfaces
+func (g *generator) methods(i *iface) ([]method, error) {
+	methods := make([]method, 0)
+	for _, e := range i.embedded {
+		embedded, ok := g.interfaces[e]
+		if !ok {
+			return nil, fmt.Errorf("Interface %s embeds unknown interface %s", i.name, e)
+		}
+		m, err := g.methods(embedded)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, m...)
+	}
+	return append(methods, i.methods...), nil
+}
+
+func mockName(name string) string {
+	return strings.TrimSuffix(name, "Interface") + "Mock"
+}
+
+func (g *generator) generate() ([]byte, error) {
+	names := make([]string, 0, len(g.interfaces))
+	for name := range g.interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var body bytes.Buffer
+	for _, name := range names {
+		methods, err := g.methods(g.interfaces[name])
+		if err != nil {
+			return nil, err
+		}
+		mock := mockName(name)
+		fmt.Fprintf(&body, "\nvar _ api.%s = (*%s)(nil)\n\n", name, mock)
+		fmt.Fprintf(&body, "// %s is a configurable implementation of api.%s.\n", mock, name)
+		fmt.Fprintf(&body, "// Each method calls the corresponding Func field, or returns zero values if it is nil.\n")
+		fmt.Fprintf(&body, "type %s struct {\n\tcalls\n", mock)
+		for _, m := range methods {
+			for n, p := range m.imports {
+				g.imports[n] = p
+			}
+			fmt.Fprintf(&body, "\t%sFunc func(%s)%s\n", m.name, joinTypes(m.params), resultTypes(m.results))
+		}
+		fmt.Fprintf(&body, "}\n")
+		for _, m := range methods {
+			params := make([]string, len(m.params))
+			args := make([]string, len(m.params))
+			for i, p := range m.params {
+				if p.variadic {
+					params[i] = p.name + " ..." + p.typ
+					args[i] = p.name + "..."
+				} else {
+					params[i] = p.name + " " + p.typ
+					args[i] = p.name
+				}
+			}
+			results := make([]string, len(m.results))
+			for i, r := range m.results {
+				results[i] = r.name + " " + r.typ
+			}
+			recorded := make([]string, 0, len(m.params)+1)
+			recorded = append(recorded, strconv.Quote(m.name))
+			for _, p := range m.params {
+				recorded = append(recorded, p.name)
+			}
+			fmt.Fprintf(&body, "\nfunc (mock *%s) %s(%s)", mock, m.name, strings.Join(params, ", "))
+			if len(results) > 0 {
+				fmt.Fprintf(&body, " (%s)", strings.Join(results, ", "))
+			}
+			fmt.Fprintf(&body, " {\n\tmock.record(%s)\n", strings.Join(recorded, ", "))
+			fmt.Fprintf(&body, "\tif mock.%sFunc != nil {\n", m.name)
+			if len(results) > 0 {
+				fmt.Fprintf(&body, "\t\treturn mock.%sFunc(%s)\n\t}\n\treturn\n}\n", m.name, strings.Join(args, ", "))
+			} else {
+				fmt.Fprintf(&body, "\t\tmock.%sFunc(%s)\n\t}\n}\n", m.name, strings.Join(args, ", "))
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString(header)
+	out.WriteString("package apimock\n\nimport (\n")
+	// standard library first, then other packages
+	std := make([]string, 0)
+	other := make([]string, 0)
+	for _, p := range g.imports {
+		if strings.Contains(strings.Split(p, "/")[0], ".") {
+			other = append(other, p)
+		} else {
+			std = append(std, p)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	for _, p := range std {
+		fmt.Fprintf(&out, "\t%q\n", p)
+	}
+	if len(std) > 0 && len(other) > 0 {
+		out.WriteString("\n")
+	}
+	for _, p := range other {
+		fmt.Fprintf(&out, "\t%q\n", p)
+	}
+	out.WriteString(")\n")
+	out.Write(body.Bytes())
+	return format.Source(out.Bytes())
+}
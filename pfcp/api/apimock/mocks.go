@@ -0,0 +1,1400 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Code generated by gen; DO NOT EDIT.
+
+package apimock
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+var _ api.ClockInterface = (*ClockMock)(nil)
+
+// ClockMock is a configurable implementation of api.ClockInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type ClockMock struct {
+	calls
+	NowFunc   func() time.Time
+	AfterFunc func(time.Duration) <-chan time.Time
+}
+
+func (mock *ClockMock) Now() (r0 time.Time) {
+	mock.record("Now")
+	if mock.NowFunc != nil {
+		return mock.NowFunc()
+	}
+	return
+}
+
+func (mock *ClockMock) After(d time.Duration) (r0 <-chan time.Time) {
+	mock.record("After", d)
+	if mock.AfterFunc != nil {
+		return mock.AfterFunc(d)
+	}
+	return
+}
+
+var _ api.DatapathInterface = (*DatapathMock)(nil)
+
+// DatapathMock is a configurable implementation of api.DatapathInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type DatapathMock struct {
+	calls
+	InstallSessionFunc func(api.PFCPSessionInterface, api.PDRMapInterface, api.FARMapInterface) error
+	UpdateSessionFunc  func(api.PFCPSessionInterface, api.PDRMapInterface, api.FARMapInterface, api.PDRMapInterface, api.FARMapInterface) error
+	RemoveSessionFunc  func(api.PFCPSessionInterface) error
+}
+
+func (mock *DatapathMock) InstallSession(session api.PFCPSessionInterface, pdrs api.PDRMapInterface, fars api.FARMapInterface) (r0 error) {
+	mock.record("InstallSession", session, pdrs, fars)
+	if mock.InstallSessionFunc != nil {
+		return mock.InstallSessionFunc(session, pdrs, fars)
+	}
+	return
+}
+
+func (mock *DatapathMock) UpdateSession(session api.PFCPSessionInterface, createpdrs api.PDRMapInterface, createfars api.FARMapInterface, updatepdrs api.PDRMapInterface, updatefars api.FARMapInterface) (r0 error) {
+	mock.record("UpdateSession", session, createpdrs, createfars, updatepdrs, updatefars)
+	if mock.UpdateSessionFunc != nil {
+		return mock.UpdateSessionFunc(session, createpdrs, createfars, updatepdrs, updatefars)
+	}
+	return
+}
+
+func (mock *DatapathMock) RemoveSession(session api.PFCPSessionInterface) (r0 error) {
+	mock.record("RemoveSession", session)
+	if mock.RemoveSessionFunc != nil {
+		return mock.RemoveSessionFunc(session)
+	}
+	return
+}
+
+var _ api.FARInterface = (*FARMock)(nil)
+
+// FARMock is a configurable implementation of api.FARInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type FARMock struct {
+	calls
+	IDFunc                   func() (api.FARID, error)
+	ApplyActionFunc          func() *ie.IE
+	ForwardingParametersFunc func() *ie.IE
+	NewCreateFARFunc         func() *ie.IE
+}
+
+func (mock *FARMock) ID() (r0 api.FARID, r1 error) {
+	mock.record("ID")
+	if mock.IDFunc != nil {
+		return mock.IDFunc()
+	}
+	return
+}
+
+func (mock *FARMock) ApplyAction() (r0 *ie.IE) {
+	mock.record("ApplyAction")
+	if mock.ApplyActionFunc != nil {
+		return mock.ApplyActionFunc()
+	}
+	return
+}
+
+func (mock *FARMock) ForwardingParameters() (r0 *ie.IE) {
+	mock.record("ForwardingParameters")
+	if mock.ForwardingParametersFunc != nil {
+		return mock.ForwardingParametersFunc()
+	}
+	return
+}
+
+func (mock *FARMock) NewCreateFAR() (r0 *ie.IE) {
+	mock.record("NewCreateFAR")
+	if mock.NewCreateFARFunc != nil {
+		return mock.NewCreateFARFunc()
+	}
+	return
+}
+
+var _ api.FARMapInterface = (*FARMapMock)(nil)
+
+// FARMapMock is a configurable implementation of api.FARMapInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type FARMapMock struct {
+	calls
+	GetFunc            func(api.FARID) (api.FARInterface, error)
+	AddFunc            func(api.FARInterface) error
+	UpdateFunc         func(api.FARInterface) error
+	RemoveFunc         func(api.FARID) error
+	SimulateAddFunc    func(api.FARInterface) error
+	SimulateUpdateFunc func(api.FARInterface) error
+	SimulateRemoveFunc func(api.FARID) error
+	ForeachFunc        func(func(api.FARInterface) error) error
+}
+
+func (mock *FARMapMock) Get(key api.FARID) (r0 api.FARInterface, r1 error) {
+	mock.record("Get", key)
+	if mock.GetFunc != nil {
+		return mock.GetFunc(key)
+	}
+	return
+}
+
+func (mock *FARMapMock) Add(far api.FARInterface) (r0 error) {
+	mock.record("Add", far)
+	if mock.AddFunc != nil {
+		return mock.AddFunc(far)
+	}
+	return
+}
+
+func (mock *FARMapMock) Update(far api.FARInterface) (r0 error) {
+	mock.record("Update", far)
+	if mock.UpdateFunc != nil {
+		return mock.UpdateFunc(far)
+	}
+	return
+}
+
+func (mock *FARMapMock) Remove(key api.FARID) (r0 error) {
+	mock.record("Remove", key)
+	if mock.RemoveFunc != nil {
+		return mock.RemoveFunc(key)
+	}
+	return
+}
+
+func (mock *FARMapMock) SimulateAdd(far api.FARInterface) (r0 error) {
+	mock.record("SimulateAdd", far)
+	if mock.SimulateAddFunc != nil {
+		return mock.SimulateAddFunc(far)
+	}
+	return
+}
+
+func (mock *FARMapMock) SimulateUpdate(far api.FARInterface) (r0 error) {
+	mock.record("SimulateUpdate", far)
+	if mock.SimulateUpdateFunc != nil {
+		return mock.SimulateUpdateFunc(far)
+	}
+	return
+}
+
+func (mock *FARMapMock) SimulateRemove(key api.FARID) (r0 error) {
+	mock.record("SimulateRemove", key)
+	if mock.SimulateRemoveFunc != nil {
+		return mock.SimulateRemoveFunc(key)
+	}
+	return
+}
+
+func (mock *FARMapMock) Foreach(p0 func(api.FARInterface) error) (r0 error) {
+	mock.record("Foreach", p0)
+	if mock.ForeachFunc != nil {
+		return mock.ForeachFunc(p0)
+	}
+	return
+}
+
+var _ api.MetricsInterface = (*MetricsMock)(nil)
+
+// MetricsMock is a configurable implementation of api.MetricsInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type MetricsMock struct {
+	calls
+	MessageSentFunc          func(uint8)
+	MessageReceivedFunc      func(uint8)
+	RequestRetransmittedFunc func(uint8)
+	RequestTimedOutFunc      func(uint8)
+	ResponseSentFunc         func(uint8, uint8)
+	ResponseReceivedFunc     func(uint8, uint8)
+	HandlerDurationFunc      func(uint8, time.Duration)
+	AssociationsChangedFunc  func(int)
+	SessionsChangedFunc      func(int)
+	PDRsChangedFunc          func(int)
+	FARsChangedFunc          func(int)
+}
+
+func (mock *MetricsMock) MessageSent(msgType uint8) {
+	mock.record("MessageSent", msgType)
+	if mock.MessageSentFunc != nil {
+		mock.MessageSentFunc(msgType)
+	}
+}
+
+func (mock *MetricsMock) MessageReceived(msgType uint8) {
+	mock.record("MessageReceived", msgType)
+	if mock.MessageReceivedFunc != nil {
+		mock.MessageReceivedFunc(msgType)
+	}
+}
+
+func (mock *MetricsMock) RequestRetransmitted(msgType uint8) {
+	mock.record("RequestRetransmitted", msgType)
+	if mock.RequestRetransmittedFunc != nil {
+		mock.RequestRetransmittedFunc(msgType)
+	}
+}
+
+func (mock *MetricsMock) RequestTimedOut(msgType uint8) {
+	mock.record("RequestTimedOut", msgType)
+	if mock.RequestTimedOutFunc != nil {
+		mock.RequestTimedOutFunc(msgType)
+	}
+}
+
+func (mock *MetricsMock) ResponseSent(msgType uint8, cause uint8) {
+	mock.record("ResponseSent", msgType, cause)
+	if mock.ResponseSentFunc != nil {
+		mock.ResponseSentFunc(msgType, cause)
+	}
+}
+
+func (mock *MetricsMock) ResponseReceived(msgType uint8, cause uint8) {
+	mock.record("ResponseReceived", msgType, cause)
+	if mock.ResponseReceivedFunc != nil {
+		mock.ResponseReceivedFunc(msgType, cause)
+	}
+}
+
+func (mock *MetricsMock) HandlerDuration(msgType uint8, d time.Duration) {
+	mock.record("HandlerDuration", msgType, d)
+	if mock.HandlerDurationFunc != nil {
+		mock.HandlerDurationFunc(msgType, d)
+	}
+}
+
+func (mock *MetricsMock) AssociationsChanged(delta int) {
+	mock.record("AssociationsChanged", delta)
+	if mock.AssociationsChangedFunc != nil {
+		mock.AssociationsChangedFunc(delta)
+	}
+}
+
+func (mock *MetricsMock) SessionsChanged(delta int) {
+	mock.record("SessionsChanged", delta)
+	if mock.SessionsChangedFunc != nil {
+		mock.SessionsChangedFunc(delta)
+	}
+}
+
+func (mock *MetricsMock) PDRsChanged(delta int) {
+	mock.record("PDRsChanged", delta)
+	if mock.PDRsChangedFunc != nil {
+		mock.PDRsChangedFunc(delta)
+	}
+}
+
+func (mock *MetricsMock) FARsChanged(delta int) {
+	mock.record("FARsChanged", delta)
+	if mock.FARsChangedFunc != nil {
+		mock.FARsChangedFunc(delta)
+	}
+}
+
+var _ api.PDRInterface = (*PDRMock)(nil)
+
+// PDRMock is a configurable implementation of api.PDRInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type PDRMock struct {
+	calls
+	IDFunc                 func() (api.PDRID, error)
+	PDIFunc                func() ([]*ie.IE, error)
+	PDIFieldsFunc          func() (*api.PDIFields, error)
+	PrecedenceFunc         func() (uint32, error)
+	FARIDFunc              func() (api.FARID, error)
+	OuterHeaderRemovalFunc func() *ie.IE
+	SourceInterfaceFunc    func() (uint8, error)
+	FTEIDFunc              func() (*ie.FTEIDFields, error)
+	UEIPAddressFunc        func() (*ie.UEIPAddressFields, error)
+	NewCreatePDRFunc       func() *ie.IE
+}
+
+func (mock *PDRMock) ID() (r0 api.PDRID, r1 error) {
+	mock.record("ID")
+	if mock.IDFunc != nil {
+		return mock.IDFunc()
+	}
+	return
+}
+
+func (mock *PDRMock) PDI() (r0 []*ie.IE, r1 error) {
+	mock.record("PDI")
+	if mock.PDIFunc != nil {
+		return mock.PDIFunc()
+	}
+	return
+}
+
+func (mock *PDRMock) PDIFields() (r0 *api.PDIFields, r1 error) {
+	mock.record("PDIFields")
+	if mock.PDIFieldsFunc != nil {
+		return mock.PDIFieldsFunc()
+	}
+	return
+}
+
+func (mock *PDRMock) Precedence() (r0 uint32, r1 error) {
+	mock.record("Precedence")
+	if mock.PrecedenceFunc != nil {
+		return mock.PrecedenceFunc()
+	}
+	return
+}
+
+func (mock *PDRMock) FARID() (r0 api.FARID, r1 error) {
+	mock.record("FARID")
+	if mock.FARIDFunc != nil {
+		return mock.FARIDFunc()
+	}
+	return
+}
+
+func (mock *PDRMock) OuterHeaderRemoval() (r0 *ie.IE) {
+	mock.record("OuterHeaderRemoval")
+	if mock.OuterHeaderRemovalFunc != nil {
+		return mock.OuterHeaderRemovalFunc()
+	}
+	return
+}
+
+func (mock *PDRMock) SourceInterface() (r0 uint8, r1 error) {
+	mock.record("SourceInterface")
+	if mock.SourceInterfaceFunc != nil {
+		return mock.SourceInterfaceFunc()
+	}
+	return
+}
+
+func (mock *PDRMock) FTEID() (r0 *ie.FTEIDFields, r1 error) {
+	mock.record("FTEID")
+	if mock.FTEIDFunc != nil {
+		return mock.FTEIDFunc()
+	}
+	return
+}
+
+func (mock *PDRMock) UEIPAddress() (r0 *ie.UEIPAddressFields, r1 error) {
+	mock.record("UEIPAddress")
+	if mock.UEIPAddressFunc != nil {
+		return mock.UEIPAddressFunc()
+	}
+	return
+}
+
+func (mock *PDRMock) NewCreatePDR() (r0 *ie.IE) {
+	mock.record("NewCreatePDR")
+	if mock.NewCreatePDRFunc != nil {
+		return mock.NewCreatePDRFunc()
+	}
+	return
+}
+
+var _ api.PDRMapInterface = (*PDRMapMock)(nil)
+
+// PDRMapMock is a configurable implementation of api.PDRMapInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type PDRMapMock struct {
+	calls
+	GetFunc             func(api.PDRID) (api.PDRInterface, error)
+	AddFunc             func(api.PDRInterface) error
+	UpdateFunc          func(api.PDRInterface) error
+	RemoveFunc          func(api.PDRID) error
+	SimulateAddFunc     func(api.PDRInterface) error
+	SimulateUpdateFunc  func(api.PDRInterface) error
+	SimulateRemoveFunc  func(api.PDRID) error
+	GetSortedPDRIDsFunc func() []api.PDRID
+	ForeachFunc         func(func(api.PDRInterface) error) error
+}
+
+func (mock *PDRMapMock) Get(key api.PDRID) (r0 api.PDRInterface, r1 error) {
+	mock.record("Get", key)
+	if mock.GetFunc != nil {
+		return mock.GetFunc(key)
+	}
+	return
+}
+
+func (mock *PDRMapMock) Add(pdr api.PDRInterface) (r0 error) {
+	mock.record("Add", pdr)
+	if mock.AddFunc != nil {
+		return mock.AddFunc(pdr)
+	}
+	return
+}
+
+func (mock *PDRMapMock) Update(pdr api.PDRInterface) (r0 error) {
+	mock.record("Update", pdr)
+	if mock.UpdateFunc != nil {
+		return mock.UpdateFunc(pdr)
+	}
+	return
+}
+
+func (mock *PDRMapMock) Remove(key api.PDRID) (r0 error) {
+	mock.record("Remove", key)
+	if mock.RemoveFunc != nil {
+		return mock.RemoveFunc(key)
+	}
+	return
+}
+
+func (mock *PDRMapMock) SimulateAdd(pdr api.PDRInterface) (r0 error) {
+	mock.record("SimulateAdd", pdr)
+	if mock.SimulateAddFunc != nil {
+		return mock.SimulateAddFunc(pdr)
+	}
+	return
+}
+
+func (mock *PDRMapMock) SimulateUpdate(pdr api.PDRInterface) (r0 error) {
+	mock.record("SimulateUpdate", pdr)
+	if mock.SimulateUpdateFunc != nil {
+		return mock.SimulateUpdateFunc(pdr)
+	}
+	return
+}
+
+func (mock *PDRMapMock) SimulateRemove(key api.PDRID) (r0 error) {
+	mock.record("SimulateRemove", key)
+	if mock.SimulateRemoveFunc != nil {
+		return mock.SimulateRemoveFunc(key)
+	}
+	return
+}
+
+func (mock *PDRMapMock) GetSortedPDRIDs() (r0 []api.PDRID) {
+	mock.record("GetSortedPDRIDs")
+	if mock.GetSortedPDRIDsFunc != nil {
+		return mock.GetSortedPDRIDsFunc()
+	}
+	return
+}
+
+func (mock *PDRMapMock) Foreach(p0 func(api.PDRInterface) error) (r0 error) {
+	mock.record("Foreach", p0)
+	if mock.ForeachFunc != nil {
+		return mock.ForeachFunc(p0)
+	}
+	return
+}
+
+var _ api.PFCPAssociationInterface = (*PFCPAssociationMock)(nil)
+
+// PFCPAssociationMock is a configurable implementation of api.PFCPAssociationInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type PFCPAssociationMock struct {
+	calls
+	IsRunningFunc                     func() bool
+	CloseFunc                         func() error
+	SendFunc                          func(message.Message) (message.Message, error)
+	SendAsyncFunc                     func(message.Message) api.PFCPFutureInterface
+	SendContextFunc                   func(context.Context, message.Message) (message.Message, error)
+	SendAsyncContextFunc              func(context.Context, message.Message) api.PFCPFutureInterface
+	SetMaxOutstandingRequestsFunc     func(int) error
+	SetRecorderFunc                   func(api.RecorderInterface)
+	IsAliveFunc                       func() (bool, error)
+	NodeIDFunc                        func() *ie.IE
+	IsUserPlaneFunc                   func() bool
+	IsControlPlaneFunc                func() bool
+	LocalEntityFunc                   func() api.PFCPEntityInterface
+	NewEstablishedPFCPAssociationFunc func() (api.PFCPAssociationInterface, error)
+	SetupInitiatedByCPFunc            func() error
+	GetNextSEIDFunc                   func() api.SEID
+	CreateSessionFunc                 func(*ie.IE, api.PDRMapInterface, api.FARMapInterface) (api.PFCPSessionInterface, error)
+}
+
+func (mock *PFCPAssociationMock) IsRunning() (r0 bool) {
+	mock.record("IsRunning")
+	if mock.IsRunningFunc != nil {
+		return mock.IsRunningFunc()
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) Close() (r0 error) {
+	mock.record("Close")
+	if mock.CloseFunc != nil {
+		return mock.CloseFunc()
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) Send(msg message.Message) (m message.Message, err error) {
+	mock.record("Send", msg)
+	if mock.SendFunc != nil {
+		return mock.SendFunc(msg)
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) SendAsync(msg message.Message) (r0 api.PFCPFutureInterface) {
+	mock.record("SendAsync", msg)
+	if mock.SendAsyncFunc != nil {
+		return mock.SendAsyncFunc(msg)
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) SendContext(ctx context.Context, msg message.Message) (m message.Message, err error) {
+	mock.record("SendContext", ctx, msg)
+	if mock.SendContextFunc != nil {
+		return mock.SendContextFunc(ctx, msg)
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) SendAsyncContext(ctx context.Context, msg message.Message) (r0 api.PFCPFutureInterface) {
+	mock.record("SendAsyncContext", ctx, msg)
+	if mock.SendAsyncContextFunc != nil {
+		return mock.SendAsyncContextFunc(ctx, msg)
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) SetMaxOutstandingRequests(n int) (r0 error) {
+	mock.record("SetMaxOutstandingRequests", n)
+	if mock.SetMaxOutstandingRequestsFunc != nil {
+		return mock.SetMaxOutstandingRequestsFunc(n)
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) SetRecorder(recorder api.RecorderInterface) {
+	mock.record("SetRecorder", recorder)
+	if mock.SetRecorderFunc != nil {
+		mock.SetRecorderFunc(recorder)
+	}
+}
+
+func (mock *PFCPAssociationMock) IsAlive() (res bool, err error) {
+	mock.record("IsAlive")
+	if mock.IsAliveFunc != nil {
+		return mock.IsAliveFunc()
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) NodeID() (r0 *ie.IE) {
+	mock.record("NodeID")
+	if mock.NodeIDFunc != nil {
+		return mock.NodeIDFunc()
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) IsUserPlane() (r0 bool) {
+	mock.record("IsUserPlane")
+	if mock.IsUserPlaneFunc != nil {
+		return mock.IsUserPlaneFunc()
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) IsControlPlane() (r0 bool) {
+	mock.record("IsControlPlane")
+	if mock.IsControlPlaneFunc != nil {
+		return mock.IsControlPlaneFunc()
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) LocalEntity() (r0 api.PFCPEntityInterface) {
+	mock.record("LocalEntity")
+	if mock.LocalEntityFunc != nil {
+		return mock.LocalEntityFunc()
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) NewEstablishedPFCPAssociation() (r0 api.PFCPAssociationInterface, r1 error) {
+	mock.record("NewEstablishedPFCPAssociation")
+	if mock.NewEstablishedPFCPAssociationFunc != nil {
+		return mock.NewEstablishedPFCPAssociationFunc()
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) SetupInitiatedByCP() (r0 error) {
+	mock.record("SetupInitiatedByCP")
+	if mock.SetupInitiatedByCPFunc != nil {
+		return mock.SetupInitiatedByCPFunc()
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) GetNextSEID() (r0 api.SEID) {
+	mock.record("GetNextSEID")
+	if mock.GetNextSEIDFunc != nil {
+		return mock.GetNextSEIDFunc()
+	}
+	return
+}
+
+func (mock *PFCPAssociationMock) CreateSession(remoteFseid *ie.IE, pdrs api.PDRMapInterface, fars api.FARMapInterface) (session api.PFCPSessionInterface, err error) {
+	mock.record("CreateSession", remoteFseid, pdrs, fars)
+	if mock.CreateSessionFunc != nil {
+		return mock.CreateSessionFunc(remoteFseid, pdrs, fars)
+	}
+	return
+}
+
+var _ api.PFCPEntityInterface = (*PFCPEntityMock)(nil)
+
+// PFCPEntityMock is a configurable implementation of api.PFCPEntityInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type PFCPEntityMock struct {
+	calls
+	IsUserPlaneFunc                   func() bool
+	IsControlPlaneFunc                func() bool
+	NodeIDFunc                        func() *ie.IE
+	RecoveryTimeStampFunc             func() *ie.IE
+	NewEstablishedPFCPAssociationFunc func(*ie.IE) (api.PFCPAssociationInterface, error)
+	RemovePFCPAssociationFunc         func(api.PFCPAssociationInterface) error
+	GetPFCPAssociationFunc            func(string) (api.PFCPAssociationInterface, error)
+	SendToFunc                        func([]byte, net.Addr) error
+	GetPFCPSessionsFunc               func() []api.PFCPSessionInterface
+	GetPFCPSessionFunc                func(string, api.SEID) (api.PFCPSessionInterface, error)
+	AddEstablishedPFCPSessionFunc     func(api.PFCPSessionInterface) error
+	RemovePFCPSessionFunc             func(api.PFCPSessionInterface) error
+	PrintPFCPRulesFunc                func()
+	LoggerFunc                        func() *slog.Logger
+	MetricsFunc                       func() api.MetricsInterface
+	TracerFunc                        func() api.TracerInterface
+	RecorderFunc                      func() api.RecorderInterface
+	TransportFunc                     func() api.TransportInterface
+	ClockFunc                         func() api.ClockInterface
+	TEIDAllocatorFunc                 func() api.TEIDAllocatorInterface
+	UEIPAllocatorFunc                 func() api.UEIPAllocatorInterface
+	DatapathFunc                      func() api.DatapathInterface
+}
+
+func (mock *PFCPEntityMock) IsUserPlane() (r0 bool) {
+	mock.record("IsUserPlane")
+	if mock.IsUserPlaneFunc != nil {
+		return mock.IsUserPlaneFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) IsControlPlane() (r0 bool) {
+	mock.record("IsControlPlane")
+	if mock.IsControlPlaneFunc != nil {
+		return mock.IsControlPlaneFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) NodeID() (r0 *ie.IE) {
+	mock.record("NodeID")
+	if mock.NodeIDFunc != nil {
+		return mock.NodeIDFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) RecoveryTimeStamp() (r0 *ie.IE) {
+	mock.record("RecoveryTimeStamp")
+	if mock.RecoveryTimeStampFunc != nil {
+		return mock.RecoveryTimeStampFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) NewEstablishedPFCPAssociation(nodeID *ie.IE) (association api.PFCPAssociationInterface, err error) {
+	mock.record("NewEstablishedPFCPAssociation", nodeID)
+	if mock.NewEstablishedPFCPAssociationFunc != nil {
+		return mock.NewEstablishedPFCPAssociationFunc(nodeID)
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) RemovePFCPAssociation(association api.PFCPAssociationInterface) (r0 error) {
+	mock.record("RemovePFCPAssociation", association)
+	if mock.RemovePFCPAssociationFunc != nil {
+		return mock.RemovePFCPAssociationFunc(association)
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) GetPFCPAssociation(nid string) (association api.PFCPAssociationInterface, err error) {
+	mock.record("GetPFCPAssociation", nid)
+	if mock.GetPFCPAssociationFunc != nil {
+		return mock.GetPFCPAssociationFunc(nid)
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) SendTo(msg []byte, dst net.Addr) (r0 error) {
+	mock.record("SendTo", msg, dst)
+	if mock.SendToFunc != nil {
+		return mock.SendToFunc(msg, dst)
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) GetPFCPSessions() (r0 []api.PFCPSessionInterface) {
+	mock.record("GetPFCPSessions")
+	if mock.GetPFCPSessionsFunc != nil {
+		return mock.GetPFCPSessionsFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) GetPFCPSession(localIP string, seid api.SEID) (r0 api.PFCPSessionInterface, r1 error) {
+	mock.record("GetPFCPSession", localIP, seid)
+	if mock.GetPFCPSessionFunc != nil {
+		return mock.GetPFCPSessionFunc(localIP, seid)
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) AddEstablishedPFCPSession(session api.PFCPSessionInterface) (r0 error) {
+	mock.record("AddEstablishedPFCPSession", session)
+	if mock.AddEstablishedPFCPSessionFunc != nil {
+		return mock.AddEstablishedPFCPSessionFunc(session)
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) RemovePFCPSession(session api.PFCPSessionInterface) (r0 error) {
+	mock.record("RemovePFCPSession", session)
+	if mock.RemovePFCPSessionFunc != nil {
+		return mock.RemovePFCPSessionFunc(session)
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) PrintPFCPRules() {
+	mock.record("PrintPFCPRules")
+	if mock.PrintPFCPRulesFunc != nil {
+		mock.PrintPFCPRulesFunc()
+	}
+}
+
+func (mock *PFCPEntityMock) Logger() (r0 *slog.Logger) {
+	mock.record("Logger")
+	if mock.LoggerFunc != nil {
+		return mock.LoggerFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) Metrics() (r0 api.MetricsInterface) {
+	mock.record("Metrics")
+	if mock.MetricsFunc != nil {
+		return mock.MetricsFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) Tracer() (r0 api.TracerInterface) {
+	mock.record("Tracer")
+	if mock.TracerFunc != nil {
+		return mock.TracerFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) Recorder() (r0 api.RecorderInterface) {
+	mock.record("Recorder")
+	if mock.RecorderFunc != nil {
+		return mock.RecorderFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) Transport() (r0 api.TransportInterface) {
+	mock.record("Transport")
+	if mock.TransportFunc != nil {
+		return mock.TransportFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) Clock() (r0 api.ClockInterface) {
+	mock.record("Clock")
+	if mock.ClockFunc != nil {
+		return mock.ClockFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) TEIDAllocator() (r0 api.TEIDAllocatorInterface) {
+	mock.record("TEIDAllocator")
+	if mock.TEIDAllocatorFunc != nil {
+		return mock.TEIDAllocatorFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) UEIPAllocator() (r0 api.UEIPAllocatorInterface) {
+	mock.record("UEIPAllocator")
+	if mock.UEIPAllocatorFunc != nil {
+		return mock.UEIPAllocatorFunc()
+	}
+	return
+}
+
+func (mock *PFCPEntityMock) Datapath() (r0 api.DatapathInterface) {
+	mock.record("Datapath")
+	if mock.DatapathFunc != nil {
+		return mock.DatapathFunc()
+	}
+	return
+}
+
+var _ api.PFCPFutureInterface = (*PFCPFutureMock)(nil)
+
+// PFCPFutureMock is a configurable implementation of api.PFCPFutureInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type PFCPFutureMock struct {
+	calls
+	DoneFunc func() <-chan struct{}
+	WaitFunc func() (message.Message, error)
+}
+
+func (mock *PFCPFutureMock) Done() (r0 <-chan struct{}) {
+	mock.record("Done")
+	if mock.DoneFunc != nil {
+		return mock.DoneFunc()
+	}
+	return
+}
+
+func (mock *PFCPFutureMock) Wait() (m message.Message, err error) {
+	mock.record("Wait")
+	if mock.WaitFunc != nil {
+		return mock.WaitFunc()
+	}
+	return
+}
+
+var _ api.PFCPPeerInterface = (*PFCPPeerMock)(nil)
+
+// PFCPPeerMock is a configurable implementation of api.PFCPPeerInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type PFCPPeerMock struct {
+	calls
+	IsRunningFunc                     func() bool
+	CloseFunc                         func() error
+	SendFunc                          func(message.Message) (message.Message, error)
+	SendAsyncFunc                     func(message.Message) api.PFCPFutureInterface
+	SendContextFunc                   func(context.Context, message.Message) (message.Message, error)
+	SendAsyncContextFunc              func(context.Context, message.Message) api.PFCPFutureInterface
+	SetMaxOutstandingRequestsFunc     func(int) error
+	SetRecorderFunc                   func(api.RecorderInterface)
+	IsAliveFunc                       func() (bool, error)
+	NodeIDFunc                        func() *ie.IE
+	IsUserPlaneFunc                   func() bool
+	IsControlPlaneFunc                func() bool
+	LocalEntityFunc                   func() api.PFCPEntityInterface
+	NewEstablishedPFCPAssociationFunc func() (api.PFCPAssociationInterface, error)
+}
+
+func (mock *PFCPPeerMock) IsRunning() (r0 bool) {
+	mock.record("IsRunning")
+	if mock.IsRunningFunc != nil {
+		return mock.IsRunningFunc()
+	}
+	return
+}
+
+func (mock *PFCPPeerMock) Close() (r0 error) {
+	mock.record("Close")
+	if mock.CloseFunc != nil {
+		return mock.CloseFunc()
+	}
+	return
+}
+
+func (mock *PFCPPeerMock) Send(msg message.Message) (m message.Message, err error) {
+	mock.record("Send", msg)
+	if mock.SendFunc != nil {
+		return mock.SendFunc(msg)
+	}
+	return
+}
+
+func (mock *PFCPPeerMock) SendAsync(msg message.Message) (r0 api.PFCPFutureInterface) {
+	mock.record("SendAsync", msg)
+	if mock.SendAsyncFunc != nil {
+		return mock.SendAsyncFunc(msg)
+	}
+	return
+}
+
+func (mock *PFCPPeerMock) SendContext(ctx context.Context, msg message.Message) (m message.Message, err error) {
+	mock.record("SendContext", ctx, msg)
+	if mock.SendContextFunc != nil {
+		return mock.SendContextFunc(ctx, msg)
+	}
+	return
+}
+
+func (mock *PFCPPeerMock) SendAsyncContext(ctx context.Context, msg message.Message) (r0 api.PFCPFutureInterface) {
+	mock.record("SendAsyncContext", ctx, msg)
+	if mock.SendAsyncContextFunc != nil {
+		return mock.SendAsyncContextFunc(ctx, msg)
+	}
+	return
+}
+
+func (mock *PFCPPeerMock) SetMaxOutstandingRequests(n int) (r0 error) {
+	mock.record("SetMaxOutstandingRequests", n)
+	if mock.SetMaxOutstandingRequestsFunc != nil {
+		return mock.SetMaxOutstandingRequestsFunc(n)
+	}
+	return
+}
+
+func (mock *PFCPPeerMock) SetRecorder(recorder api.RecorderInterface) {
+	mock.record("SetRecorder", recorder)
+	if mock.SetRecorderFunc != nil {
+		mock.SetRecorderFunc(recorder)
+	}
+}
+
+func (mock *PFCPPeerMock) IsAlive() (res bool, err error) {
+	mock.record("IsAlive")
+	if mock.IsAliveFunc != nil {
+		return mock.IsAliveFunc()
+	}
+	return
+}
+
+func (mock *PFCPPeerMock) NodeID() (r0 *ie.IE) {
+	mock.record("NodeID")
+	if mock.NodeIDFunc != nil {
+		return mock.NodeIDFunc()
+	}
+	return
+}
+
+func (mock *PFCPPeerMock) IsUserPlane() (r0 bool) {
+	mock.record("IsUserPlane")
+	if mock.IsUserPlaneFunc != nil {
+		return mock.IsUserPlaneFunc()
+	}
+	return
+}
+
+func (mock *PFCPPeerMock) IsControlPlane() (r0 bool) {
+	mock.record("IsControlPlane")
+	if mock.IsControlPlaneFunc != nil {
+		return mock.IsControlPlaneFunc()
+	}
+	return
+}
+
+func (mock *PFCPPeerMock) LocalEntity() (r0 api.PFCPEntityInterface) {
+	mock.record("LocalEntity")
+	if mock.LocalEntityFunc != nil {
+		return mock.LocalEntityFunc()
+	}
+	return
+}
+
+func (mock *PFCPPeerMock) NewEstablishedPFCPAssociation() (r0 api.PFCPAssociationInterface, r1 error) {
+	mock.record("NewEstablishedPFCPAssociation")
+	if mock.NewEstablishedPFCPAssociationFunc != nil {
+		return mock.NewEstablishedPFCPAssociationFunc()
+	}
+	return
+}
+
+var _ api.PFCPSessionInterface = (*PFCPSessionMock)(nil)
+
+// PFCPSessionMock is a configurable implementation of api.PFCPSessionInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type PFCPSessionMock struct {
+	calls
+	LocalFSEIDFunc         func() *ie.IE
+	LocalSEIDFunc          func() (api.SEID, error)
+	LocalIPAddressFunc     func() (net.IP, error)
+	RemoteFSEIDFunc        func() *ie.IE
+	RemoteSEIDFunc         func() (api.SEID, error)
+	RemoteIPAddressFunc    func() (net.IP, error)
+	GetSortedPDRIDsFunc    func() []api.PDRID
+	GetPDRFunc             func(api.PDRID) (api.PDRInterface, error)
+	GetFARFunc             func(api.FARID) (api.FARInterface, error)
+	AddUpdatePDRsFARsFunc  func(api.PDRMapInterface, api.FARMapInterface, api.PDRMapInterface, api.FARMapInterface) error
+	SetupFunc              func() error
+	ForeachUnsortedPDRFunc func(func(api.PDRInterface) error) error
+	ForeachUnsortedFARFunc func(func(api.FARInterface) error) error
+	RLockFunc              func()
+	RUnlockFunc            func()
+}
+
+func (mock *PFCPSessionMock) LocalFSEID() (r0 *ie.IE) {
+	mock.record("LocalFSEID")
+	if mock.LocalFSEIDFunc != nil {
+		return mock.LocalFSEIDFunc()
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) LocalSEID() (r0 api.SEID, r1 error) {
+	mock.record("LocalSEID")
+	if mock.LocalSEIDFunc != nil {
+		return mock.LocalSEIDFunc()
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) LocalIPAddress() (r0 net.IP, r1 error) {
+	mock.record("LocalIPAddress")
+	if mock.LocalIPAddressFunc != nil {
+		return mock.LocalIPAddressFunc()
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) RemoteFSEID() (r0 *ie.IE) {
+	mock.record("RemoteFSEID")
+	if mock.RemoteFSEIDFunc != nil {
+		return mock.RemoteFSEIDFunc()
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) RemoteSEID() (r0 api.SEID, r1 error) {
+	mock.record("RemoteSEID")
+	if mock.RemoteSEIDFunc != nil {
+		return mock.RemoteSEIDFunc()
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) RemoteIPAddress() (r0 net.IP, r1 error) {
+	mock.record("RemoteIPAddress")
+	if mock.RemoteIPAddressFunc != nil {
+		return mock.RemoteIPAddressFunc()
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) GetSortedPDRIDs() (r0 []api.PDRID) {
+	mock.record("GetSortedPDRIDs")
+	if mock.GetSortedPDRIDsFunc != nil {
+		return mock.GetSortedPDRIDsFunc()
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) GetPDR(pdrid api.PDRID) (r0 api.PDRInterface, r1 error) {
+	mock.record("GetPDR", pdrid)
+	if mock.GetPDRFunc != nil {
+		return mock.GetPDRFunc(pdrid)
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) GetFAR(farid api.FARID) (r0 api.FARInterface, r1 error) {
+	mock.record("GetFAR", farid)
+	if mock.GetFARFunc != nil {
+		return mock.GetFARFunc(farid)
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) AddUpdatePDRsFARs(createpdrs api.PDRMapInterface, createfars api.FARMapInterface, updatepdr api.PDRMapInterface, updatefars api.FARMapInterface) (r0 error) {
+	mock.record("AddUpdatePDRsFARs", createpdrs, createfars, updatepdr, updatefars)
+	if mock.AddUpdatePDRsFARsFunc != nil {
+		return mock.AddUpdatePDRsFARsFunc(createpdrs, createfars, updatepdr, updatefars)
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) Setup() (r0 error) {
+	mock.record("Setup")
+	if mock.SetupFunc != nil {
+		return mock.SetupFunc()
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) ForeachUnsortedPDR(f func(api.PDRInterface) error) (r0 error) {
+	mock.record("ForeachUnsortedPDR", f)
+	if mock.ForeachUnsortedPDRFunc != nil {
+		return mock.ForeachUnsortedPDRFunc(f)
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) ForeachUnsortedFAR(f func(api.FARInterface) error) (r0 error) {
+	mock.record("ForeachUnsortedFAR", f)
+	if mock.ForeachUnsortedFARFunc != nil {
+		return mock.ForeachUnsortedFARFunc(f)
+	}
+	return
+}
+
+func (mock *PFCPSessionMock) RLock() {
+	mock.record("RLock")
+	if mock.RLockFunc != nil {
+		mock.RLockFunc()
+	}
+}
+
+func (mock *PFCPSessionMock) RUnlock() {
+	mock.record("RUnlock")
+	if mock.RUnlockFunc != nil {
+		mock.RUnlockFunc()
+	}
+}
+
+var _ api.RecorderInterface = (*RecorderMock)(nil)
+
+// RecorderMock is a configurable implementation of api.RecorderInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type RecorderMock struct {
+	calls
+	RecordFunc func(net.Addr, net.Addr, []byte)
+}
+
+func (mock *RecorderMock) Record(src net.Addr, dst net.Addr, payload []byte) {
+	mock.record("Record", src, dst, payload)
+	if mock.RecordFunc != nil {
+		mock.RecordFunc(src, dst, payload)
+	}
+}
+
+var _ api.SessionsMapInterface = (*SessionsMapMock)(nil)
+
+// SessionsMapMock is a configurable implementation of api.SessionsMapInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type SessionsMapMock struct {
+	calls
+	AddFunc             func(api.PFCPSessionInterface) error
+	RemoveFunc          func(api.PFCPSessionInterface) error
+	GetPFCPSessionsFunc func() []api.PFCPSessionInterface
+	GetPFCPSessionFunc  func(string, api.SEID) (api.PFCPSessionInterface, error)
+}
+
+func (mock *SessionsMapMock) Add(session api.PFCPSessionInterface) (r0 error) {
+	mock.record("Add", session)
+	if mock.AddFunc != nil {
+		return mock.AddFunc(session)
+	}
+	return
+}
+
+func (mock *SessionsMapMock) Remove(session api.PFCPSessionInterface) (r0 error) {
+	mock.record("Remove", session)
+	if mock.RemoveFunc != nil {
+		return mock.RemoveFunc(session)
+	}
+	return
+}
+
+func (mock *SessionsMapMock) GetPFCPSessions() (r0 []api.PFCPSessionInterface) {
+	mock.record("GetPFCPSessions")
+	if mock.GetPFCPSessionsFunc != nil {
+		return mock.GetPFCPSessionsFunc()
+	}
+	return
+}
+
+func (mock *SessionsMapMock) GetPFCPSession(localIP string, seid api.SEID) (r0 api.PFCPSessionInterface, r1 error) {
+	mock.record("GetPFCPSession", localIP, seid)
+	if mock.GetPFCPSessionFunc != nil {
+		return mock.GetPFCPSessionFunc(localIP, seid)
+	}
+	return
+}
+
+var _ api.SpanInterface = (*SpanMock)(nil)
+
+// SpanMock is a configurable implementation of api.SpanInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type SpanMock struct {
+	calls
+	SetAttributesFunc func(...api.SpanAttribute)
+	RecordErrorFunc   func(error)
+	EndFunc           func()
+}
+
+func (mock *SpanMock) SetAttributes(attrs ...api.SpanAttribute) {
+	mock.record("SetAttributes", attrs)
+	if mock.SetAttributesFunc != nil {
+		mock.SetAttributesFunc(attrs...)
+	}
+}
+
+func (mock *SpanMock) RecordError(err error) {
+	mock.record("RecordError", err)
+	if mock.RecordErrorFunc != nil {
+		mock.RecordErrorFunc(err)
+	}
+}
+
+func (mock *SpanMock) End() {
+	mock.record("End")
+	if mock.EndFunc != nil {
+		mock.EndFunc()
+	}
+}
+
+var _ api.TEIDAllocatorInterface = (*TEIDAllocatorMock)(nil)
+
+// TEIDAllocatorMock is a configurable implementation of api.TEIDAllocatorInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type TEIDAllocatorMock struct {
+	calls
+	AllocateFunc func(string, uint8, bool, bool) (*ie.FTEIDFields, error)
+	ReleaseFunc  func(string, uint8, uint32) error
+}
+
+func (mock *TEIDAllocatorMock) Allocate(networkInstance string, sourceInterface uint8, ipv4 bool, ipv6 bool) (r0 *ie.FTEIDFields, r1 error) {
+	mock.record("Allocate", networkInstance, sourceInterface, ipv4, ipv6)
+	if mock.AllocateFunc != nil {
+		return mock.AllocateFunc(networkInstance, sourceInterface, ipv4, ipv6)
+	}
+	return
+}
+
+func (mock *TEIDAllocatorMock) Release(networkInstance string, sourceInterface uint8, teid uint32) (r0 error) {
+	mock.record("Release", networkInstance, sourceInterface, teid)
+	if mock.ReleaseFunc != nil {
+		return mock.ReleaseFunc(networkInstance, sourceInterface, teid)
+	}
+	return
+}
+
+var _ api.TEIDReserverInterface = (*TEIDReserverMock)(nil)
+
+// TEIDReserverMock is a configurable implementation of api.TEIDReserverInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type TEIDReserverMock struct {
+	calls
+	ReserveFunc func(string, uint8, uint32) error
+}
+
+func (mock *TEIDReserverMock) Reserve(networkInstance string, sourceInterface uint8, teid uint32) (r0 error) {
+	mock.record("Reserve", networkInstance, sourceInterface, teid)
+	if mock.ReserveFunc != nil {
+		return mock.ReserveFunc(networkInstance, sourceInterface, teid)
+	}
+	return
+}
+
+var _ api.TracerInterface = (*TracerMock)(nil)
+
+// TracerMock is a configurable implementation of api.TracerInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type TracerMock struct {
+	calls
+	StartFunc func(context.Context, string) (context.Context, api.SpanInterface)
+}
+
+func (mock *TracerMock) Start(ctx context.Context, spanName string) (r0 context.Context, r1 api.SpanInterface) {
+	mock.record("Start", ctx, spanName)
+	if mock.StartFunc != nil {
+		return mock.StartFunc(ctx, spanName)
+	}
+	return
+}
+
+var _ api.TransportInterface = (*TransportMock)(nil)
+
+// TransportMock is a configurable implementation of api.TransportInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type TransportMock struct {
+	calls
+	ListenUDPFunc func(*net.UDPAddr) (net.PacketConn, error)
+	DialUDPFunc   func(net.IP, *net.UDPAddr) (net.PacketConn, error)
+}
+
+func (mock *TransportMock) ListenUDP(laddr *net.UDPAddr) (r0 net.PacketConn, r1 error) {
+	mock.record("ListenUDP", laddr)
+	if mock.ListenUDPFunc != nil {
+		return mock.ListenUDPFunc(laddr)
+	}
+	return
+}
+
+func (mock *TransportMock) DialUDP(localIP net.IP, raddr *net.UDPAddr) (r0 net.PacketConn, r1 error) {
+	mock.record("DialUDP", localIP, raddr)
+	if mock.DialUDPFunc != nil {
+		return mock.DialUDPFunc(localIP, raddr)
+	}
+	return
+}
+
+var _ api.UEIPAllocatorInterface = (*UEIPAllocatorMock)(nil)
+
+// UEIPAllocatorMock is a configurable implementation of api.UEIPAllocatorInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type UEIPAllocatorMock struct {
+	calls
+	AllocateIPv4Func       func(string) (net.IP, error)
+	AllocateIPv6PrefixFunc func(string) (*net.IPNet, error)
+	ReleaseIPv4Func        func(string, net.IP) error
+	ReleaseIPv6PrefixFunc  func(string, *net.IPNet) error
+}
+
+func (mock *UEIPAllocatorMock) AllocateIPv4(networkInstance string) (r0 net.IP, r1 error) {
+	mock.record("AllocateIPv4", networkInstance)
+	if mock.AllocateIPv4Func != nil {
+		return mock.AllocateIPv4Func(networkInstance)
+	}
+	return
+}
+
+func (mock *UEIPAllocatorMock) AllocateIPv6Prefix(networkInstance string) (r0 *net.IPNet, r1 error) {
+	mock.record("AllocateIPv6Prefix", networkInstance)
+	if mock.AllocateIPv6PrefixFunc != nil {
+		return mock.AllocateIPv6PrefixFunc(networkInstance)
+	}
+	return
+}
+
+func (mock *UEIPAllocatorMock) ReleaseIPv4(networkInstance string, addr net.IP) (r0 error) {
+	mock.record("ReleaseIPv4", networkInstance, addr)
+	if mock.ReleaseIPv4Func != nil {
+		return mock.ReleaseIPv4Func(networkInstance, addr)
+	}
+	return
+}
+
+func (mock *UEIPAllocatorMock) ReleaseIPv6Prefix(networkInstance string, prefix *net.IPNet) (r0 error) {
+	mock.record("ReleaseIPv6Prefix", networkInstance, prefix)
+	if mock.ReleaseIPv6PrefixFunc != nil {
+		return mock.ReleaseIPv6PrefixFunc(networkInstance, prefix)
+	}
+	return
+}
+
+var _ api.UEIPReserverInterface = (*UEIPReserverMock)(nil)
+
+// UEIPReserverMock is a configurable implementation of api.UEIPReserverInterface.
+// Each method calls the corresponding Func field, or returns zero values if it is nil.
+type UEIPReserverMock struct {
+	calls
+	ReserveIPv4Func       func(string, net.IP) error
+	ReserveIPv6PrefixFunc func(string, *net.IPNet) error
+}
+
+func (mock *UEIPReserverMock) ReserveIPv4(networkInstance string, addr net.IP) (r0 error) {
+	mock.record("ReserveIPv4", networkInstance, addr)
+	if mock.ReserveIPv4Func != nil {
+		return mock.ReserveIPv4Func(networkInstance, addr)
+	}
+	return
+}
+
+func (mock *UEIPReserverMock) ReserveIPv6Prefix(networkInstance string, prefix *net.IPNet) (r0 error) {
+	mock.record("ReserveIPv6Prefix", networkInstance, prefix)
+	if mock.ReserveIPv6PrefixFunc != nil {
+		return mock.ReserveIPv6PrefixFunc(networkInstance, prefix)
+	}
+	return
+}
@@ -0,0 +1,17 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package api
+
+import "time"
+
+// A ClockInterface provides the time to entities, peers and associations
+// (recovery timestamps, retransmission timers, heartbeats).
+// The default clock is the real clock; tests may use a clock advanced manually.
+type ClockInterface interface {
+	Now() time.Time
+	// Returns a channel receiving the current time once d has elapsed
+	After(d time.Duration) <-chan time.Time
+}
@@ -0,0 +1,21 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package api
+
+// A DatapathInterface programs the rules of the sessions of a UP function into a datapath.
+// Every call is a transaction: when an error is returned, the datapath must be left unchanged,
+// and the PFCP Request is rejected with cause Rule creation/modification failure.
+// Calls are made while the session is locked: the datapath must not call RLock on the session.
+// Sessions restored from a snapshot are installed again: a datapath keeping its rules
+// across restarts of the entity should accept a session it already has.
+type DatapathInterface interface {
+	// Install a new session with its PDRs and FARs; the session is not yet visible in the entity
+	InstallSession(session PFCPSessionInterface, pdrs PDRMapInterface, fars FARMapInterface) error
+	// Create and update PDRs and FARs of an installed session
+	UpdateSession(session PFCPSessionInterface, createpdrs PDRMapInterface, createfars FARMapInterface, updatepdrs PDRMapInterface, updatefars FARMapInterface) error
+	// Remove an installed session with all its rules
+	RemoveSession(session PFCPSessionInterface) error
+}
@@ -6,6 +6,7 @@
 package api
 
 import (
+	"log/slog"
 	"net"
 
 	"github.com/wmnsk/go-pfcp/ie"
@@ -23,5 +24,15 @@ type PFCPEntityInterface interface {
 	GetPFCPSessions() []PFCPSessionInterface
 	GetPFCPSession(localIP string, seid SEID) (PFCPSessionInterface, error)
 	AddEstablishedPFCPSession(session PFCPSessionInterface) error
+	RemovePFCPSession(session PFCPSessionInterface) error
 	PrintPFCPRules()
+	Logger() *slog.Logger
+	Metrics() MetricsInterface
+	Tracer() TracerInterface
+	Recorder() RecorderInterface
+	Transport() TransportInterface
+	Clock() ClockInterface
+	TEIDAllocator() TEIDAllocatorInterface
+	UEIPAllocator() UEIPAllocatorInterface
+	Datapath() DatapathInterface
 }
@@ -0,0 +1,27 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package api
+
+import "time"
+
+// A MetricsInterface receives instrumentation events from a PFCP Entity
+// and from its peers. Implementations must be safe for concurrent use.
+type MetricsInterface interface {
+	// PFCP traffic
+	MessageSent(msgType uint8)
+	MessageReceived(msgType uint8)
+	RequestRetransmitted(msgType uint8)
+	RequestTimedOut(msgType uint8)
+	ResponseSent(msgType uint8, cause uint8)
+	ResponseReceived(msgType uint8, cause uint8)
+	HandlerDuration(msgType uint8, d time.Duration)
+
+	// PFCP state (delta is the change of the gauge)
+	AssociationsChanged(delta int)
+	SessionsChanged(delta int)
+	PDRsChanged(delta int)
+	FARsChanged(delta int)
+}
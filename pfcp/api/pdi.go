@@ -0,0 +1,166 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package api
+
+import (
+	"fmt"
+
+	"github.com/nextmn/go-pfcp-networking/sdf"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// Flags of the UE IP Address IE (TS 29.244, section 8.2.62)
+const (
+	UEIPFlagV6    = 0x01
+	UEIPFlagV4    = 0x02
+	UEIPFlagSD    = 0x04 // set: UE IP Address is the destination address of packets
+	UEIPFlagIPv6D = 0x08 // IPv6 Prefix Delegation Bits are present
+	UEIPFlagCHV4  = 0x10
+	UEIPFlagCHV6  = 0x20
+	UEIPFlagIP6PL = 0x40 // IPv6 Prefix Length is present
+)
+
+// PDIFields is the content of a PDI IE (TS 29.244, section 7.5.2.2-2).
+// IEs which are not modelled are kept in Others, so that PDIFields round-trips through IE().
+type PDIFields struct {
+	SourceInterface uint8
+	LocalFTEID      *ie.FTEIDFields       // nil when not present
+	NetworkInstance string                // empty when not present
+	UEIPAddress     *ie.UEIPAddressFields // nil when not present
+	SDFFilters      []*sdf.Filter
+	ApplicationID   string // empty when not present
+
+	HasEthernetPDUSessionInformation bool
+	EthernetPDUSessionInformation    uint8
+
+	QFIs             []uint8
+	FramedRoutes     []string
+	HasFramedRouting bool
+	FramedRouting    uint32
+	FramedIPv6Routes []string
+
+	Others []*ie.IE
+}
+
+// Parse the content of a PDI IE
+func ParsePDIFields(ies []*ie.IE) (*PDIFields, error) {
+	p := PDIFields{}
+	hasSourceInterface := false
+	for _, i := range ies {
+		if i == nil {
+			continue
+		}
+		var err error
+		switch i.Type {
+		case ie.SourceInterface:
+			p.SourceInterface, err = i.SourceInterface()
+			hasSourceInterface = err == nil
+		case ie.FTEID:
+			p.LocalFTEID, err = i.FTEID()
+		case ie.NetworkInstance:
+			p.NetworkInstance, err = i.NetworkInstance()
+		case ie.UEIPAddress:
+			p.UEIPAddress, err = i.UEIPAddress()
+		case ie.SDFFilter:
+			var f *sdf.Filter
+			f, err = sdf.FromIE(i)
+			if err == nil {
+				p.SDFFilters = append(p.SDFFilters, f)
+			}
+		case ie.ApplicationID:
+			p.ApplicationID, err = i.ApplicationID()
+		case ie.EthernetPDUSessionInformation:
+			p.EthernetPDUSessionInformation, err = i.EthernetPDUSessionInformation()
+			p.HasEthernetPDUSessionInformation = err == nil
+		case ie.QFI:
+			var qfi uint8
+			qfi, err = i.QFI()
+			if err == nil {
+				p.QFIs = append(p.QFIs, qfi)
+			}
+		case ie.FramedRoute:
+			var route string
+			route, err = i.FramedRoute()
+			if err == nil {
+				p.FramedRoutes = append(p.FramedRoutes, route)
+			}
+		case ie.FramedRouting:
+			p.FramedRouting, err = i.FramedRouting()
+			p.HasFramedRouting = err == nil
+		case ie.FramedIPv6Route:
+			var route string
+			route, err = i.FramedIPv6Route()
+			if err == nil {
+				p.FramedIPv6Routes = append(p.FramedIPv6Routes, route)
+			}
+		default:
+			p.Others = append(p.Others, i)
+		}
+		if err != nil {
+			return nil, &PDIError{IEType: i.Type, Err: err}
+		}
+	}
+	if !hasSourceInterface {
+		return nil, &PDIError{IEType: ie.SourceInterface, Err: ie.ErrIENotFound}
+	}
+	return &p, nil
+}
+
+// Create a PDI IE
+func (p *PDIFields) IE() *ie.IE {
+	ies := make([]*ie.IE, 0)
+	ies = append(ies, ie.NewSourceInterface(p.SourceInterface))
+	if p.LocalFTEID != nil {
+		if b, err := p.LocalFTEID.Marshal(); err == nil {
+			ies = append(ies, ie.New(ie.FTEID, b))
+		}
+	}
+	if p.NetworkInstance != "" {
+		ies = append(ies, ie.NewNetworkInstance(p.NetworkInstance))
+	}
+	if p.UEIPAddress != nil {
+		if b, err := p.UEIPAddress.Marshal(); err == nil {
+			ies = append(ies, ie.New(ie.UEIPAddress, b))
+		}
+	}
+	for _, f := range p.SDFFilters {
+		ies = append(ies, f.IE())
+	}
+	if p.ApplicationID != "" {
+		ies = append(ies, ie.NewApplicationID(p.ApplicationID))
+	}
+	if p.HasEthernetPDUSessionInformation {
+		ies = append(ies, ie.NewEthernetPDUSessionInformation(p.EthernetPDUSessionInformation))
+	}
+	for _, qfi := range p.QFIs {
+		ies = append(ies, ie.NewQFI(qfi))
+	}
+	for _, route := range p.FramedRoutes {
+		ies = append(ies, ie.NewFramedRoute(route))
+	}
+	if p.HasFramedRouting {
+		ies = append(ies, ie.NewFramedRouting(p.FramedRouting))
+	}
+	for _, route := range p.FramedIPv6Routes {
+		ies = append(ies, ie.NewFramedIPv6Route(route))
+	}
+	ies = append(ies, p.Others...)
+	return ie.NewPDI(ies...)
+}
+
+// A PDIError is returned by ParsePDIFields when an IE of the PDI is missing or malformed
+type PDIError struct {
+	IEType uint16
+	Err    error
+}
+
+func (e *PDIError) Error() string {
+	return fmt.Sprintf("Malformed PDI (IE %d): %s", e.IEType, e.Err)
+}
+
+func (e *PDIError) Unwrap() error {
+	return e.Err
+}
@@ -12,6 +12,7 @@ type PDRInterface interface {
 	ID() (PDRID, error)
 
 	PDI() ([]*ie.IE, error)
+	PDIFields() (*PDIFields, error) // must not be modified
 	Precedence() (uint32, error)
 
 	FARID() (FARID, error)
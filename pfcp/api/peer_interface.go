@@ -6,6 +6,8 @@
 package api
 
 import (
+	"context"
+
 	"github.com/wmnsk/go-pfcp/ie"
 	"github.com/wmnsk/go-pfcp/message"
 )
@@ -13,7 +15,13 @@ import (
 type PFCPPeerInterface interface {
 	IsRunning() bool
 	Close() error
+	// Rejected Requests return the Response together with an error
 	Send(msg message.Message) (m message.Message, err error)
+	SendAsync(msg message.Message) PFCPFutureInterface
+	SendContext(ctx context.Context, msg message.Message) (m message.Message, err error)
+	SendAsyncContext(ctx context.Context, msg message.Message) PFCPFutureInterface
+	SetMaxOutstandingRequests(n int) error
+	SetRecorder(recorder RecorderInterface)
 	IsAlive() (res bool, err error)
 	NodeID() *ie.IE
 	IsUserPlane() bool
@@ -21,3 +29,13 @@ type PFCPPeerInterface interface {
 	LocalEntity() PFCPEntityInterface
 	NewEstablishedPFCPAssociation() (PFCPAssociationInterface, error)
 }
+
+// A PFCPFutureInterface is the result of a Request sent asynchronously
+type PFCPFutureInterface interface {
+	// Done is closed when the transaction is over
+	// (Response received, or Request considered lost)
+	Done() <-chan struct{}
+	// Wait blocks until the transaction is over, and returns the Response
+	// (with an error if the Response rejects the Request)
+	Wait() (m message.Message, err error)
+}
@@ -0,0 +1,15 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package api
+
+import "net"
+
+// A RecorderInterface receives every PFCP datagram sent or received
+// by an entity or a peer. Implementations must be safe for concurrent use,
+// and must not keep payload after returning.
+type RecorderInterface interface {
+	Record(src net.Addr, dst net.Addr, payload []byte)
+}
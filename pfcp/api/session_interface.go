@@ -25,6 +25,7 @@ type PFCPSessionInterface interface {
 	//	SetRemoteFSEID(FSEID *ie.IE)
 	Setup() error
 	ForeachUnsortedPDR(f func(pdr PDRInterface) error) error
+	ForeachUnsortedFAR(f func(far FARInterface) error) error
 
 	// Must be called before getting PDRIDs, PDR, and FARs in one operation
 	// to ensure FARs are up-to-date with PDRs
@@ -7,6 +7,7 @@ package api
 
 type SessionsMapInterface interface {
 	Add(session PFCPSessionInterface) error
+	Remove(session PFCPSessionInterface) error
 	GetPFCPSessions() []PFCPSessionInterface
 	GetPFCPSession(localIP string, seid SEID) (PFCPSessionInterface, error)
 }
@@ -0,0 +1,25 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package api
+
+import "github.com/wmnsk/go-pfcp/ie"
+
+// A TEIDAllocatorInterface allocates local F-TEIDs on UP functions,
+// when the CP function sets the CH flag in the F-TEID of a PDI.
+type TEIDAllocatorInterface interface {
+	// Allocate a F-TEID for the Network Instance and Source Interface of a PDI,
+	// with an IPv4 and/or an IPv6 address as requested by the CP function
+	Allocate(networkInstance string, sourceInterface uint8, ipv4 bool, ipv6 bool) (*ie.FTEIDFields, error)
+	// Release a TEID returned by Allocate
+	Release(networkInstance string, sourceInterface uint8, teid uint32) error
+}
+
+// A TEIDAllocatorInterface can also implement TEIDReserverInterface,
+// so that TEIDs of sessions restored from a snapshot are not allocated again.
+type TEIDReserverInterface interface {
+	// Mark a TEID as allocated
+	Reserve(networkInstance string, sourceInterface uint8, teid uint32) error
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package api
+
+import "context"
+
+// Keys of span attributes
+const (
+	SpanAttrMessageType     = "pfcp.message_type"
+	SpanAttrSequenceNumber  = "pfcp.sequence_number"
+	SpanAttrSEID            = "pfcp.seid"
+	SpanAttrPeerNodeID      = "pfcp.peer.node_id"
+	SpanAttrRetransmissions = "pfcp.retransmissions"
+	SpanAttrCause           = "pfcp.cause"
+)
+
+// A TracerInterface creates spans for PFCP transactions.
+// It mirrors the Tracer of OpenTelemetry, so an adapter is straightforward.
+type TracerInterface interface {
+	// Start a span, the returned context contains the span
+	Start(ctx context.Context, spanName string) (context.Context, SpanInterface)
+}
+
+// A SpanInterface is a span created by a TracerInterface
+type SpanInterface interface {
+	SetAttributes(attrs ...SpanAttribute)
+	RecordError(err error)
+	End()
+}
+
+// A SpanAttribute is a key-value pair attached to a span.
+// Value is a string, a bool, an int64, or a uint64.
+type SpanAttribute struct {
+	Key   string
+	Value any
+}
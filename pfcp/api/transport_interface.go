@@ -0,0 +1,20 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package api
+
+import "net"
+
+// A TransportInterface provides the sockets used by entities and peers.
+// The default transport uses UDP sockets of the operating system.
+type TransportInterface interface {
+	// Open a socket receiving PFCP messages sent to laddr (used by entities)
+	ListenUDP(laddr *net.UDPAddr) (net.PacketConn, error)
+	// Open a socket, bound to an ephemeral port, used to send Requests to raddr
+	// and to receive their Responses (used by peers).
+	// localIP is the IP Address of the local entity, or nil if unknown;
+	// transports may use it as source address.
+	DialUDP(localIP net.IP, raddr *net.UDPAddr) (net.PacketConn, error)
+}
@@ -0,0 +1,30 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package api
+
+import "net"
+
+// A UEIPAllocatorInterface allocates UE IP addresses on UP functions,
+// when the CP function sets the CHV4 or CHV6 flag in the UE IP Address of a PDI.
+type UEIPAllocatorInterface interface {
+	// Allocate an IPv4 address in the Network Instance
+	AllocateIPv4(networkInstance string) (net.IP, error)
+	// Allocate an IPv6 prefix in the Network Instance
+	AllocateIPv6Prefix(networkInstance string) (*net.IPNet, error)
+	// Release an IPv4 address returned by AllocateIPv4
+	ReleaseIPv4(networkInstance string, addr net.IP) error
+	// Release an IPv6 prefix returned by AllocateIPv6Prefix
+	ReleaseIPv6Prefix(networkInstance string, prefix *net.IPNet) error
+}
+
+// A UEIPAllocatorInterface can also implement UEIPReserverInterface,
+// so that addresses of sessions restored from a snapshot are not allocated again.
+type UEIPReserverInterface interface {
+	// Mark an IPv4 address as allocated
+	ReserveIPv4(networkInstance string, addr net.IP) error
+	// Mark an IPv6 prefix as allocated
+	ReserveIPv6Prefix(networkInstance string, prefix *net.IPNet) error
+}
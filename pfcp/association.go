@@ -7,7 +7,7 @@ package pfcp_networking
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"time"
 
@@ -20,6 +20,7 @@ type PFCPAssociation struct {
 	api.PFCPPeerInterface                // connection to remote peer
 	isSetup               bool           // true when session is already set-up
 	sessionIDPool         *SessionIDPool // used to generate SEIDs for this association
+	isStatic              bool           // true for the pseudo-association of provisioned sessions
 }
 
 // Create a new PFCPAssociation, this association is already set-up
@@ -36,6 +37,30 @@ func newEstablishedPFCPAssociation(peer api.PFCPPeerInterface) (api.PFCPAssociat
 	return &association, nil
 }
 
+// Create a PFCPAssociation from a snapshot; this association is already set-up,
+// and nextSEID is the next SEID it allocates
+func restoreEstablishedPFCPAssociation(peer api.PFCPPeerInterface, nextSEID api.SEID) *PFCPAssociation {
+	association := PFCPAssociation{
+		PFCPPeerInterface: peer,
+		isSetup:           true,
+		sessionIDPool:     NewSessionIDPool(),
+	}
+	association.sessionIDPool.setNext(nextSEID)
+	go association.heartMonitoring()
+	return &association
+}
+
+// Create the pseudo-association of sessions provisioned on the UP function:
+// no PFCP message is exchanged with its peer
+func newStaticPFCPAssociation(peer api.PFCPPeerInterface) *PFCPAssociation {
+	return &PFCPAssociation{
+		PFCPPeerInterface: peer,
+		isSetup:           true,
+		sessionIDPool:     NewSessionIDPool(),
+		isStatic:          true,
+	}
+}
+
 // Get next available SEID for this PFCPAssociation.
 // SEID are not globally unique, F-SEID are globally unique.
 // F-SEID are constitued of IPv4 and/or IPv6 address(es) of the peer
@@ -74,19 +99,20 @@ func (association *PFCPAssociation) SetupInitiatedByCP() error {
 		}
 		asres, ok := resp.(*message.AssociationSetupResponse)
 		if !ok {
-			log.Printf("got unexpected message: %s\n", resp.MessageTypeName())
+			association.LocalEntity().Logger().Warn("Got unexpected message", logAttrNodeID(LogKeyPeer, association.NodeID()), slog.String(LogKeyMessageType, resp.MessageTypeName()))
+			return fmt.Errorf("Unexpected response to Association Setup Request")
 		}
-		cause, err := asres.Cause.Cause()
-		if err != nil {
+		// rejections are returned by Send as a CauseError
+		if asres.Cause == nil {
+			return fmt.Errorf("Cause is missing in Association Setup Response")
+		}
+		if _, err := asres.Cause.Cause(); err != nil {
 			// TODO: send missing ie message
 			return err
 		}
-		if cause == ie.CauseRequestAccepted {
-			association.isSetup = true
-			go association.heartMonitoring()
-			return nil
-		}
-		return fmt.Errorf("Associaton setup request rejected")
+		association.isSetup = true
+		go association.heartMonitoring()
+		return nil
 	default:
 		return fmt.Errorf("Local PFCP entity is not a UP function, neither a CP function.")
 	}
@@ -98,9 +124,10 @@ func (association *PFCPAssociation) heartMonitoring() error {
 	checkInterval := 30 * time.Second
 	for {
 		select {
-		case <-time.After(checkInterval):
+		case <-association.LocalEntity().Clock().After(checkInterval):
 			alive, err := association.IsAlive()
 			if !alive {
+				association.LocalEntity().Logger().Info("PFCP Peer is dead", logAttrNodeID(LogKeyPeer, association.NodeID()), slog.Any("error", err))
 				return fmt.Errorf("PFCP Peer is dead")
 			}
 			if err != nil {
@@ -160,7 +187,39 @@ func (association *PFCPAssociation) getFSEID(seid api.SEID) (*ie.IE, error) {
 // remoteFseid can be nil if caller is at CP function side
 func (association *PFCPAssociation) CreateSession(remoteFseid *ie.IE, pdrs api.PDRMapInterface, fars api.FARMapInterface) (session api.PFCPSessionInterface, err error) {
 	// Generation of the F-SEID
-	localSEID := association.GetNextSEID()
+	seid, err := association.nextFreeSEID(nil)
+	if err != nil {
+		return nil, err
+	}
+	return association.createSession(seid, remoteFseid, pdrs, fars)
+}
+
+// Returns the next SEID of the association which is neither reserved, nor used by a session of the local entity.
+// SEIDs are generated per association, but sessions of the entity are identified by their local F-SEID:
+// SEIDs of other associations, and of provisioned sessions, must be skipped.
+func (association *PFCPAssociation) nextFreeSEID(reserved map[api.SEID]struct{}) (api.SEID, error) {
+	for {
+		seid := association.GetNextSEID()
+		if _, isReserved := reserved[seid]; isReserved {
+			continue
+		}
+		fseid, err := association.getFSEID(seid)
+		if err != nil {
+			return 0, err
+		}
+		localIP, err := fseidIPAddress(fseid)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := association.LocalEntity().GetPFCPSession(localIP.String(), seid); err != nil {
+			return seid, nil
+		}
+	}
+}
+
+// Create a session with a given local SEID
+func (association *PFCPAssociation) createSession(localSEID api.SEID, remoteFseid *ie.IE, pdrs api.PDRMapInterface, fars api.FARMapInterface) (session api.PFCPSessionInterface, err error) {
+	association.LocalEntity().Logger().Debug("Creating PFCP Session", logAttrNodeID(LogKeyPeer, association.NodeID()), slog.Uint64(LogKeySEID, localSEID))
 	localFseid, err := association.getFSEID(localSEID)
 	if err != nil {
 		return nil, err
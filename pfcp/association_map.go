@@ -53,6 +53,17 @@ func (a *AssociationsMap) Add(association api.PFCPAssociationInterface) error {
 	return nil
 }
 
+// Returns all associations in an array
+func (a *AssociationsMap) GetPFCPAssociations() []api.PFCPAssociationInterface {
+	a.muAssociations.RLock()
+	defer a.muAssociations.RUnlock()
+	associations := make([]api.PFCPAssociationInterface, 0, len(a.associations))
+	for _, association := range a.associations {
+		associations = append(associations, association)
+	}
+	return associations
+}
+
 // Returns true if the association does not exist
 func (a *AssociationsMap) CheckNonExist(nid string) bool {
 	a.muAssociations.RLock()
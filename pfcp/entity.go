@@ -6,14 +6,16 @@
 package pfcp_networking
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"sync"
 	"time"
 
 	"github.com/nextmn/go-pfcp-networking/pfcp/api"
 	"github.com/nextmn/go-pfcp-networking/pfcputil"
+	"github.com/nextmn/go-pfcp-networking/sdf"
 	"github.com/wmnsk/go-pfcp/ie"
 	"github.com/wmnsk/go-pfcp/message"
 )
@@ -22,7 +24,10 @@ type PFCPEntity struct {
 	nodeID            *ie.IE
 	recoveryTimeStamp *ie.IE
 	handlers          map[pfcputil.MessageType]PFCPMessageHandler
-	conn              *net.UDPConn
+	middlewares       []PFCPMessageMiddleware
+	typeMiddlewares   map[pfcputil.MessageType][]PFCPMessageMiddleware
+	handlersMu        sync.RWMutex // applies on handlers, middlewares, and typeMiddlewares
+	conn              net.PacketConn
 	connMu            sync.Mutex
 	associationsMap   AssociationsMap
 	// each session is associated with a specific PFCPAssociation
@@ -31,11 +36,60 @@ type PFCPEntity struct {
 	// CP function send them to UP functions
 	sessionsMap api.SessionsMapInterface
 	kind        string // "CP" or "UP"
+	logger      *slog.Logger
+	metrics     api.MetricsInterface
+	tracer      api.TracerInterface
+	recorder    api.RecorderInterface // nil when traffic is not recorded
+	transport   api.TransportInterface
+	clock       api.ClockInterface
+	responses   *responseCache // Responses sent, to answer retransmitted Requests
+	// how long Responses are kept
+	responseLifetime time.Duration
+	// UP functions allocate F-TEIDs and UE IP addresses when requested by the CP function
+	teidAllocator  api.TEIDAllocatorInterface // nil when F-TEIDs are not allocated locally
+	ueipAllocator  api.UEIPAllocatorInterface // nil when UE IP addresses are not allocated locally
+	localResources *localResources
+	datapath       api.DatapathInterface // nil when rules are not programmed into a datapath
+	restored       *snapshot             // state restored when the entity starts
 }
 
 // Add an Established PFCP Session
 func (e *PFCPEntity) AddEstablishedPFCPSession(session api.PFCPSessionInterface) error {
-	return e.sessionsMap.Add(session)
+	if err := e.sessionsMap.Add(session); err != nil {
+		return err
+	}
+	pdrs, fars := countPDRsFARs(session)
+	e.metrics.SessionsChanged(1)
+	e.metrics.PDRsChanged(pdrs)
+	e.metrics.FARsChanged(fars)
+	return nil
+}
+
+// Remove a PFCP Session
+func (e *PFCPEntity) RemovePFCPSession(session api.PFCPSessionInterface) error {
+	if err := e.unregisterPFCPSession(session); err != nil {
+		return err
+	}
+	e.localResources.release(session)
+	return nil
+}
+
+// Remove a PFCP Session from the datapath and the sessions map,
+// without releasing resources allocated for it
+func (e *PFCPEntity) unregisterPFCPSession(session api.PFCPSessionInterface) error {
+	if e.datapath != nil {
+		if err := e.datapath.RemoveSession(session); err != nil {
+			return newDatapathError(err)
+		}
+	}
+	if err := e.sessionsMap.Remove(session); err != nil {
+		return err
+	}
+	pdrs, fars := countPDRsFARs(session)
+	e.metrics.SessionsChanged(-1)
+	e.metrics.PDRsChanged(-pdrs)
+	e.metrics.FARsChanged(-fars)
+	return nil
 }
 
 func (e *PFCPEntity) GetPFCPSessions() []api.PFCPSessionInterface {
@@ -52,6 +106,9 @@ func (e *PFCPEntity) SendTo(msg []byte, dst net.Addr) error {
 	if _, err := e.conn.WriteTo(msg, dst); err != nil {
 		return err
 	}
+	if e.recorder != nil {
+		e.recorder.Record(e.conn.LocalAddr(), dst, msg)
+	}
 	return nil
 }
 
@@ -69,20 +126,138 @@ func newDefaultPFCPEntityHandlers() map[pfcputil.MessageType]PFCPMessageHandler
 }
 
 func NewPFCPEntity(nodeID string, kind string) PFCPEntity {
+	nid := ie.NewNodeIDHeuristic(nodeID)
 	return PFCPEntity{
-		nodeID:            ie.NewNodeIDHeuristic(nodeID),
+		nodeID:            nid,
 		recoveryTimeStamp: nil,
 		handlers:          newDefaultPFCPEntityHandlers(),
+		middlewares:       make([]PFCPMessageMiddleware, 0),
+		typeMiddlewares:   make(map[pfcputil.MessageType][]PFCPMessageMiddleware),
+		handlersMu:        sync.RWMutex{},
 		conn:              nil,
 		connMu:            sync.Mutex{},
 		associationsMap:   NewAssociationsMap(),
 		sessionsMap:       NewSessionsMap(),
 		kind:              kind,
+		logger:            slog.Default().With(logAttrNodeID(LogKeyNodeID, nid)),
+		metrics:           noopMetrics{},
+		tracer:            noopTracer{},
+		recorder:          nil,
+		transport:         udpTransport{},
+		clock:             realClock{},
+		responseLifetime:  pfcputil.DEFAULT_RESPONSE_CACHE_LIFETIME,
+		teidAllocator:     nil,
+		ueipAllocator:     nil,
+		localResources:    newLocalResources(),
+		datapath:          nil,
+		restored:          nil,
+	}
+}
+
+// Set the logger used by the entity, and by its peers and associations.
+// This should be called before starting the entity.
+func (e *PFCPEntity) SetLogger(logger *slog.Logger) {
+	e.logger = logger.With(logAttrNodeID(LogKeyNodeID, e.nodeID))
+}
+
+func (e *PFCPEntity) Logger() *slog.Logger {
+	return e.logger
+}
+
+// Set the metrics used by the entity, and by its peers and associations.
+// This should be called before starting the entity.
+func (e *PFCPEntity) SetMetrics(metrics api.MetricsInterface) {
+	e.metrics = metrics
+}
+
+func (e *PFCPEntity) Metrics() api.MetricsInterface {
+	return e.metrics
+}
+
+// Set the tracer used by the entity, and by its peers.
+// This should be called before starting the entity.
+func (e *PFCPEntity) SetTracer(tracer api.TracerInterface) {
+	e.tracer = tracer
+}
+
+func (e *PFCPEntity) Tracer() api.TracerInterface {
+	return e.tracer
+}
+
+// Set the recorder receiving every datagram sent or received by the entity
+// and by its peers (unless a peer has its own recorder).
+// This should be called before starting the entity.
+func (e *PFCPEntity) SetRecorder(recorder api.RecorderInterface) {
+	e.recorder = recorder
+}
+
+func (e *PFCPEntity) Recorder() api.RecorderInterface {
+	return e.recorder
+}
+
+// Set the transport providing sockets to the entity and to its peers.
+// This must be called before starting the entity.
+func (e *PFCPEntity) SetTransport(transport api.TransportInterface) error {
+	if e.RecoveryTimeStamp() != nil {
+		return fmt.Errorf("Cannot change transport of already started PFCP Entity")
+	}
+	e.transport = transport
+	return nil
+}
+
+func (e *PFCPEntity) Transport() api.TransportInterface {
+	return e.transport
+}
+
+// Set the clock used by the entity, its peers and its associations.
+// This must be called before starting the entity.
+func (e *PFCPEntity) SetClock(clock api.ClockInterface) error {
+	if e.RecoveryTimeStamp() != nil {
+		return fmt.Errorf("Cannot change clock of already started PFCP Entity")
+	}
+	e.clock = clock
+	return nil
+}
+
+func (e *PFCPEntity) Clock() api.ClockInterface {
+	return e.clock
+}
+
+// Set how long Responses are kept to answer retransmitted Requests.
+// It must cover the retransmissions of peers: T1 * (N1 + 1) when they use timers
+// longer than the ones of this library.
+// This must be called before starting the entity.
+func (e *PFCPEntity) SetResponseCacheLifetime(lifetime time.Duration) error {
+	if e.RecoveryTimeStamp() != nil {
+		return fmt.Errorf("Cannot change response cache lifetime of already started PFCP Entity")
 	}
+	if lifetime <= 0 {
+		return fmt.Errorf("Response cache lifetime must be positive")
+	}
+	e.responseLifetime = lifetime
+	return nil
+}
+
+func (e *PFCPEntity) TEIDAllocator() api.TEIDAllocatorInterface {
+	return e.teidAllocator
+}
+
+func (e *PFCPEntity) UEIPAllocator() api.UEIPAllocatorInterface {
+	return e.ueipAllocator
+}
+
+func (e *PFCPEntity) Datapath() api.DatapathInterface {
+	return e.datapath
 }
 
 func (e *PFCPEntity) listen() error {
-	e.recoveryTimeStamp = ie.NewRecoveryTimeStamp(time.Now())
+	if e.restored != nil {
+		// peers must not detect a restart
+		e.recoveryTimeStamp = ie.NewRecoveryTimeStamp(e.restored.RecoveryTimeStamp)
+	} else {
+		e.recoveryTimeStamp = ie.NewRecoveryTimeStamp(e.clock.Now())
+	}
+	e.responses = newResponseCache(e.clock, e.responseLifetime)
 	// TODO: if NodeID is a FQDN, we can expose multiple ip addresses
 	ipAddr, err := e.NodeID().NodeID()
 	if err != nil {
@@ -93,7 +268,7 @@ func (e *PFCPEntity) listen() error {
 	if err != nil {
 		return err
 	}
-	e.conn, err = net.ListenUDP("udp", laddr)
+	e.conn, err = e.transport.ListenUDP(laddr)
 	if err != nil {
 		return err
 	}
@@ -101,43 +276,119 @@ func (e *PFCPEntity) listen() error {
 	return nil
 }
 
+// Undo listen: the entity is not started anymore
+func (e *PFCPEntity) stopListening() {
+	e.connMu.Lock()
+	defer e.connMu.Unlock()
+	if e.conn != nil {
+		e.conn.Close()
+		e.conn = nil
+	}
+	e.recoveryTimeStamp = nil
+}
+
 func (e *PFCPEntity) GetHandler(t pfcputil.MessageType) (h PFCPMessageHandler, err error) {
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
 	if f, exists := e.handlers[t]; exists {
 		return f, nil
 	}
 	return nil, fmt.Errorf("Received unexpected PFCP message type")
 }
 
-func (e *PFCPEntity) AddHandler(t pfcputil.MessageType, h PFCPMessageHandler) error {
-	if e.RecoveryTimeStamp() != nil {
-		return fmt.Errorf("Cannot add handler to already started PFCP Entity")
+// Returns the handler for this message type wrapped by registered middlewares.
+// Global middlewares are the outermost ones, then come middlewares
+// registered for this message type. For each of these lists,
+// the first registered middleware is the outermost one.
+func (e *PFCPEntity) getWrappedHandler(t pfcputil.MessageType) (h PFCPMessageHandler, err error) {
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	h, exists := e.handlers[t]
+	if !exists {
+		return nil, fmt.Errorf("Received unexpected PFCP message type")
 	}
+	typeMiddlewares := e.typeMiddlewares[t]
+	for i := len(typeMiddlewares) - 1; i >= 0; i-- {
+		h = typeMiddlewares[i](h)
+	}
+	for i := len(e.middlewares) - 1; i >= 0; i-- {
+		h = e.middlewares[i](h)
+	}
+	return h, nil
+}
+
+// Set the handler for a message type, replacing the previous one.
+// Handlers can be added before or after the entity is started.
+func (e *PFCPEntity) AddHandler(t pfcputil.MessageType, h PFCPMessageHandler) error {
 	if !pfcputil.IsMessageTypeRequest(t) {
 		return fmt.Errorf("Only request messages can have a handler")
 	}
+	e.handlersMu.Lock()
+	defer e.handlersMu.Unlock()
 	e.handlers[t] = h
 	return nil
 }
 
 func (e *PFCPEntity) AddHandlers(funcs map[pfcputil.MessageType]PFCPMessageHandler) error {
-	if e.RecoveryTimeStamp() != nil {
-		return fmt.Errorf("Cannot add handler to already started PFCP Entity")
-	}
 	for t, _ := range funcs {
 		if !pfcputil.IsMessageTypeRequest(t) {
 			return fmt.Errorf("Only request messages can have a handler")
 		}
 	}
 
+	e.handlersMu.Lock()
+	defer e.handlersMu.Unlock()
 	for t, h := range funcs {
 		e.handlers[t] = h
 	}
 	return nil
 }
 
-// Remove an association from the association table
+// Add a middleware applied to handlers of every message type
+func (e *PFCPEntity) AddMiddleware(m PFCPMessageMiddleware) {
+	e.handlersMu.Lock()
+	defer e.handlersMu.Unlock()
+	e.middlewares = append(e.middlewares, m)
+}
+
+// Add a middleware applied to the handler of a specific message type
+func (e *PFCPEntity) AddMessageTypeMiddleware(t pfcputil.MessageType, m PFCPMessageMiddleware) error {
+	if !pfcputil.IsMessageTypeRequest(t) {
+		return fmt.Errorf("Only request messages can have a middleware")
+	}
+	e.handlersMu.Lock()
+	defer e.handlersMu.Unlock()
+	e.typeMiddlewares[t] = append(e.typeMiddlewares[t], m)
+	return nil
+}
+
+// Remove an association from the association table,
+// with its sessions and the resources allocated for them
 func (e *PFCPEntity) RemovePFCPAssociation(association api.PFCPAssociationInterface) error {
-	return e.associationsMap.Remove(association)
+	nid, err := association.NodeID().NodeID()
+	if err != nil {
+		return err
+	}
+	if e.associationsMap.CheckNonExist(nid) {
+		return nil
+	}
+	for _, session := range e.GetPFCPSessions() {
+		s, ok := session.(*PFCPSession)
+		if !ok {
+			continue
+		}
+		if snid, err := s.association.NodeID().NodeID(); err != nil || snid != nid {
+			continue
+		}
+		if err := e.RemovePFCPSession(session); err != nil {
+			return err
+		}
+	}
+	if err := e.associationsMap.Remove(association); err != nil {
+		return err
+	}
+	e.metrics.AssociationsChanged(-1)
+	return nil
 }
 
 // Returns an existing PFCP Association
@@ -167,40 +418,171 @@ func (e *PFCPEntity) NewEstablishedPFCPAssociation(nodeID *ie.IE) (association a
 	if err := e.associationsMap.Add(a); err != nil {
 		return nil, err
 	}
+	e.metrics.AssociationsChanged(1)
 	return a, nil
 
 }
 
+// Start the entity. If it fails, the entity is left stopped, and Start can be called again.
 func (e *PFCPEntity) Start() error {
 	if err := e.listen(); err != nil {
+		e.stopListening()
 		return err
 	}
-	buf := make([]byte, pfcputil.DEFAULT_MTU) // TODO: get MTU of interface instead of using DEFAULT_MTU
+	if e.restored != nil {
+		if err := e.applySnapshot(e.restored); err != nil {
+			// datapath, allocators and socket are left as before Start
+			e.undoSnapshot()
+			e.stopListening()
+			return err
+		}
+		e.restored = nil
+	}
 	go func() error {
 		for {
+			// a new buffer is required for each message: parsed IEs are not copied
+			buf := make([]byte, pfcputil.DEFAULT_MTU) // TODO: get MTU of interface instead of using DEFAULT_MTU
 			n, addr, err := e.conn.ReadFrom(buf)
 			if err != nil {
 				return err
 			}
+			if e.recorder != nil {
+				e.recorder.Record(addr, e.conn.LocalAddr(), buf[:n])
+			}
 			msg, err := message.Parse(buf[:n])
 			if err != nil {
 				// undecodable pfcp message
+				e.logger.Debug("Undecodable PFCP message", slog.Any(LogKeyPeer, addr), slog.Any("error", err))
 				continue
 			}
-			f, err := e.GetHandler(msg.MessageType())
+			logger := e.logger.With(slog.Any(LogKeyPeer, addr)).With(logAttrsMessage(msg)...)
+			logger.Debug("Received PFCP message")
+			e.metrics.MessageReceived(msg.MessageType())
+			f, err := e.getWrappedHandler(msg.MessageType())
 			if err != nil {
-				log.Println("No Handler for message of this type:", err)
+				logger.Debug("No handler for message of this type", slog.Any("error", err))
 				continue
 			}
-			err = f(ReceivedMessage{Message: msg, SenderAddr: addr, Entity: e})
+			if pfcputil.IsMessageTypeRequest(msg.MessageType()) {
+				if duplicate, res := e.responses.lookupOrAdd(addr, msg.Sequence(), buf[:n]); duplicate {
+					if res == nil {
+						logger.Debug("Retransmitted PFCP Request is still being handled")
+						continue
+					}
+					logger.Debug("Retransmitted PFCP Request, sending Response again")
+					if err := e.SendTo(res, addr); err != nil {
+						logger.Warn("Could not send Response again", slog.Any("error", err))
+						continue
+					}
+					e.metrics.MessageSent(res[1])
+					continue
+				}
+			}
+			ctx, span := startSpan(context.Background(), e.tracer, msg)
+			span.SetAttributes(spanAttrsMessage(msg.MessageType(), msg.Sequence(), msg.SEID())...)
+			rm := e.newReceivedMessage(ctx, msg, addr)
+			if rm.Association != nil {
+				if nid, err := rm.Association.NodeID().NodeID(); err == nil {
+					span.SetAttributes(api.SpanAttribute{Key: api.SpanAttrPeerNodeID, Value: nid})
+				}
+			}
+			begin := e.clock.Now()
+			err = f(rm)
+			e.metrics.HandlerDuration(msg.MessageType(), e.clock.Now().Sub(begin))
+			if pfcputil.IsMessageTypeRequest(msg.MessageType()) {
+				// a retransmission of an unanswered Request is handled again
+				e.responses.forgetUnanswered(addr, msg.Sequence())
+			}
 			if err != nil {
-				log.Println(err)
+				logger.Warn("Error while handling PFCP message", slog.Any("error", err))
+				span.RecordError(err)
 			}
+			span.End()
 		}
 	}()
 	return nil
 }
 
+// Stop the entity: its socket and its associations are closed
+func (e *PFCPEntity) Close() error {
+	for _, association := range e.associationsMap.GetPFCPAssociations() {
+		association.Close()
+		if err := e.RemovePFCPAssociation(association); err != nil {
+			return err
+		}
+	}
+	e.connMu.Lock()
+	defer e.connMu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	return e.conn.Close()
+}
+
+// Create a ReceivedMessage, resolving the association with the sender
+// and, for session related messages, the session identified by the header SEID
+func (e *PFCPEntity) newReceivedMessage(ctx context.Context, msg message.Message, senderAddr net.Addr) ReceivedMessage {
+	rm := ReceivedMessage{
+		Message:        msg,
+		SenderAddr:     senderAddr,
+		Entity:         e,
+		Association:    nil,
+		Session:        nil,
+		ctx:            ctx,
+		responses:      e.responses,
+		localResources: e.localResources,
+	}
+	if association, err := checkSenderAssociation(e, senderAddr); err == nil {
+		rm.Association = association
+	}
+	// PFCP session related messages for sessions that are already established are sent to the IP address received
+	// in the F-SEID allocated by the peer function or to the IP address of an alternative SMF in the SMF set
+	// (see clause 5.22). The former IP address needs not be configured in the look up information.
+	// Therefore the session is found using its local F-SEID, not using the association.
+	if pfcputil.IsMessageTypeSessionRelated(msg.MessageType()) && msg.SEID() != 0 {
+		if localIP, err := e.localIPAddress(); err == nil {
+			if session, err := e.GetPFCPSession(localIP, msg.SEID()); err == nil {
+				rm.Session = session
+			}
+		}
+	}
+	return rm
+}
+
+// Returns the IP Address used in local F-SEIDs
+func (e *PFCPEntity) localIPAddress() (string, error) {
+	ielocalnodeid := e.NodeID()
+	localnodeid, err := ielocalnodeid.NodeID()
+	if err != nil {
+		return "", err
+	}
+	switch ielocalnodeid.Payload[0] {
+	case ie.NodeIDIPv4Address:
+		ip4, err := net.ResolveIPAddr("ip4", localnodeid)
+		if err != nil {
+			return "", err
+		}
+		return ip4.String(), nil
+	case ie.NodeIDIPv6Address:
+		ip6, err := net.ResolveIPAddr("ip6", localnodeid)
+		if err != nil {
+			return "", err
+		}
+		return ip6.String(), nil
+	case ie.NodeIDFQDN:
+		ip4, _ := net.ResolveIPAddr("ip4", localnodeid)
+		ip6, _ := net.ResolveIPAddr("ip6", localnodeid)
+		// XXX handle localip in fseid sessions.go session_map.go when ip4 and ip6 are set
+		switch {
+		case ip6 != nil:
+			return ip6.String(), nil
+		case ip4 != nil:
+			return ip4.String(), nil
+		}
+	}
+	return "", fmt.Errorf("Cannot resolve NodeID")
+}
+
 func (e *PFCPEntity) IsUserPlane() bool {
 	return e.kind == "UP"
 }
@@ -209,166 +591,186 @@ func (e *PFCPEntity) IsControlPlane() bool {
 	return e.kind == "CP"
 }
 
+// Log PFCP Rules of every session (at debug level)
 func (e *PFCPEntity) PrintPFCPRules() {
 	for _, session := range e.GetPFCPSessions() {
-		localIPAddress, err := session.LocalIPAddress()
+		e.printPFCPSessionRules(session)
+	}
+}
+
+func (e *PFCPEntity) printPFCPSessionRules(session api.PFCPSessionInterface) {
+	localIPAddress, err := session.LocalIPAddress()
+	if err != nil {
+		e.logger.Debug("Cannot print PFCP Session", slog.Any("error", err))
+		return
+	}
+	localSEID, err := session.LocalSEID()
+	if err != nil {
+		e.logger.Debug("Cannot print PFCP Session", slog.Any("error", err))
+		return
+	}
+	remoteIPAddress, err := session.RemoteIPAddress()
+	if err != nil {
+		e.logger.Debug("Cannot print PFCP Session", slog.Any("error", err))
+		return
+	}
+	remoteSEID, err := session.RemoteSEID()
+	if err != nil {
+		e.logger.Debug("Cannot print PFCP Session", slog.Any("error", err))
+		return
+	}
+
+	logger := e.logger.With(slog.Uint64(LogKeySEID, localSEID))
+	logger.Debug("PFCP Session",
+		slog.String("local_fseid", fmt.Sprintf("%s (%d)", localIPAddress.String(), localSEID)),
+		slog.String("remote_fseid", fmt.Sprintf("%s (%d)", remoteIPAddress.String(), remoteSEID)))
+	session.RLock()
+	defer session.RUnlock()
+	for _, pdrid := range session.GetSortedPDRIDs() {
+		pdr, err := session.GetPDR(pdrid)
 		if err != nil {
-			log.Println(err)
+			logger.Debug("Cannot print PDR", slog.Uint64("pdr_id", uint64(pdrid)), slog.Any("error", err))
 			continue
 		}
-		localSEID, err := session.LocalSEID()
+		precedence, err := pdr.Precedence()
 		if err != nil {
-			log.Println(err)
+			logger.Debug("Cannot print PDR", slog.Uint64("pdr_id", uint64(pdrid)), slog.Any("error", err))
 			continue
 		}
-		remoteIPAddress, err := session.RemoteIPAddress()
+		farid, err := pdr.FARID()
 		if err != nil {
-			log.Println(err)
+			logger.Debug("Cannot print PDR", slog.Uint64("pdr_id", uint64(pdrid)), slog.Any("error", err))
 			continue
 		}
-		remoteSEID, err := session.RemoteSEID()
+		pdicontent, err := pdr.PDI()
 		if err != nil {
-			log.Println(err)
+			logger.Debug("Cannot print PDR", slog.Uint64("pdr_id", uint64(pdrid)), slog.Any("error", err))
 			continue
 		}
-
-		log.Printf("PFCP Session: Local F-SEID [%s (%d)], Remote F-SEID [%s (%d)]\n",
-			localIPAddress.String(), localSEID,
-			remoteIPAddress.String(), remoteSEID)
-		session.RLock()
-		defer session.RUnlock()
-		for _, pdrid := range session.GetSortedPDRIDs() {
-			pdr, err := session.GetPDR(pdrid)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			precedence, err := pdr.Precedence()
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			farid, err := pdr.FARID()
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			pdicontent, err := pdr.PDI()
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			far, err := session.GetFAR(farid)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			pdi := ie.NewPDI(pdicontent...)
-			sourceInterfaceLabel := "Not defined"
-			if sourceInterface, err := pdi.SourceInterface(); err == nil {
-				switch sourceInterface {
-				case ie.SrcInterfaceAccess:
-					sourceInterfaceLabel = "Access"
-				case ie.SrcInterfaceCore:
-					sourceInterfaceLabel = "Core"
-				case ie.SrcInterfaceSGiLANN6LAN:
-					sourceInterfaceLabel = "SGi-LAN/N6-LAN"
-				case ie.SrcInterfaceCPFunction:
-					sourceInterfaceLabel = "CP Function"
-				case ie.SrcInterface5GVNInternal:
-					sourceInterfaceLabel = "5G VN Internal"
-				}
+		far, err := session.GetFAR(farid)
+		if err != nil {
+			logger.Debug("Cannot print PDR", slog.Uint64("pdr_id", uint64(pdrid)), slog.Any("error", err))
+			continue
+		}
+		pdi := ie.NewPDI(pdicontent...)
+		sourceInterfaceLabel := "Not defined"
+		if sourceInterface, err := pdi.SourceInterface(); err == nil {
+			switch sourceInterface {
+			case ie.SrcInterfaceAccess:
+				sourceInterfaceLabel = "Access"
+			case ie.SrcInterfaceCore:
+				sourceInterfaceLabel = "Core"
+			case ie.SrcInterfaceSGiLANN6LAN:
+				sourceInterfaceLabel = "SGi-LAN/N6-LAN"
+			case ie.SrcInterfaceCPFunction:
+				sourceInterfaceLabel = "CP Function"
+			case ie.SrcInterface5GVNInternal:
+				sourceInterfaceLabel = "5G VN Internal"
 			}
-			ueIpAddressLabel := "Any"
-			if ueipaddress, err := pdi.UEIPAddress(); err == nil {
-				ueIpAddressIE := ie.NewUEIPAddress(ueipaddress.Flags, ueipaddress.IPv4Address.String(), ueipaddress.IPv6Address.String(), ueipaddress.IPv6PrefixDelegationBits, ueipaddress.IPv6PrefixLength)
-				switch {
-				case ueIpAddressIE.HasIPv4():
-					ueIpAddressLabel = ueipaddress.IPv4Address.String()
-				case ueIpAddressIE.HasIPv6():
-					ueIpAddressLabel = ueipaddress.IPv6Address.String()
-				}
+		}
+		ueIpAddressLabel := "Any"
+		if ueipaddress, err := pdi.UEIPAddress(); err == nil {
+			ueIpAddressIE := ie.NewUEIPAddress(ueipaddress.Flags, ueipaddress.IPv4Address.String(), ueipaddress.IPv6Address.String(), ueipaddress.IPv6PrefixDelegationBits, ueipaddress.IPv6PrefixLength)
+			switch {
+			case ueIpAddressIE.HasIPv4():
+				ueIpAddressLabel = ueipaddress.IPv4Address.String()
+			case ueIpAddressIE.HasIPv6():
+				ueIpAddressLabel = ueipaddress.IPv6Address.String()
 			}
-			fteidLabel := "Not defined"
-			if fteid, err := pdi.FTEID(); err == nil {
-				fteidIE := ie.NewFTEID(fteid.Flags, fteid.TEID, fteid.IPv4Address, fteid.IPv6Address, fteid.ChooseID)
-				switch {
-				case fteidIE.HasIPv4() && fteidIE.HasIPv6():
-					fteidLabel = fmt.Sprintf("[%s/%s (%d)]", fteid.IPv4Address, fteid.IPv6Address, fteid.TEID)
-				case fteidIE.HasIPv4():
-					fteidLabel = fmt.Sprintf("[%s (%d)]", fteid.IPv4Address, fteid.TEID)
-				case fteidIE.HasIPv6():
-					fteidLabel = fmt.Sprintf("[%s (%d)]", fteid.IPv6Address, fteid.TEID)
-				}
+		}
+		fteidLabel := "Not defined"
+		if fteid, err := pdi.FTEID(); err == nil {
+			fteidIE := ie.NewFTEID(fteid.Flags, fteid.TEID, fteid.IPv4Address, fteid.IPv6Address, fteid.ChooseID)
+			switch {
+			case fteidIE.HasIPv4() && fteidIE.HasIPv6():
+				fteidLabel = fmt.Sprintf("[%s/%s (%d)]", fteid.IPv4Address, fteid.IPv6Address, fteid.TEID)
+			case fteidIE.HasIPv4():
+				fteidLabel = fmt.Sprintf("[%s (%d)]", fteid.IPv4Address, fteid.TEID)
+			case fteidIE.HasIPv6():
+				fteidLabel = fmt.Sprintf("[%s (%d)]", fteid.IPv6Address, fteid.TEID)
 			}
+		}
 
-			OuterHeaderRemovalLabel := "No"
-			if ohrIe := pdr.OuterHeaderRemoval(); ohrIe != nil {
-				if ohr, err := ohrIe.OuterHeaderRemovalDescription(); err == nil {
-					if ohr == 0 || ohr == 1 || ohr == 6 {
-						OuterHeaderRemovalLabel = "GTP"
-					} else {
-						OuterHeaderRemovalLabel = "Yes (but no GTP)"
-					}
+		OuterHeaderRemovalLabel := "No"
+		if ohrIe := pdr.OuterHeaderRemoval(); ohrIe != nil {
+			if ohr, err := ohrIe.OuterHeaderRemovalDescription(); err == nil {
+				if ohr == 0 || ohr == 1 || ohr == 6 {
+					OuterHeaderRemovalLabel = "GTP"
+				} else {
+					OuterHeaderRemovalLabel = "Yes (but no GTP)"
 				}
 			}
+		}
 
-			SDFFilterLabel := ""
-			if SDFFilter, err := pdi.SDFFilter(); err == nil {
-				SDFFilterLabel = fmt.Sprintf("SDF Filter: %s", SDFFilter.FlowDescription)
+		SDFFilterLabels := make([]string, 0)
+		for _, i := range pdicontent {
+			if i.Type != ie.SDFFilter {
+				continue
 			}
-
-			ApplyActionLabel := "No"
-			if ApplyActionIE := far.ApplyAction(); ApplyActionIE != nil {
-				switch {
-				case ApplyActionIE.HasDROP():
-					ApplyActionLabel = "DROP"
-				case ApplyActionIE.HasFORW():
-					ApplyActionLabel = "FORW"
-				default:
-					ApplyActionLabel = "Other"
-				}
+			if filter, err := sdf.FromIE(i); err == nil {
+				SDFFilterLabels = append(SDFFilterLabels, filter.String())
+			} else {
+				SDFFilterLabels = append(SDFFilterLabels, "Malformed")
 			}
+		}
 
-			ForwardingParametersIe := far.ForwardingParameters()
-			OuterHeaderCreationLabel := "No"
-			if ohc, err := ForwardingParametersIe.OuterHeaderCreation(); err == nil {
-				ohcb, _ := ohc.Marshal()
-				ohcIe := ie.New(ie.OuterHeaderCreation, ohcb)
-				switch {
-				case ohcIe.HasTEID() && ohcIe.HasIPv4():
-					OuterHeaderCreationLabel = fmt.Sprintf("[%s (%d)]", ohc.IPv4Address.String(), ohc.TEID)
-				case ohcIe.HasTEID() && ohcIe.HasIPv6():
-					OuterHeaderCreationLabel = fmt.Sprintf("[%s (%d)]", ohc.IPv6Address.String(), ohc.TEID)
-				default:
-					OuterHeaderCreationLabel = "Other"
-				}
+		ApplyActionLabel := "No"
+		if ApplyActionIE := far.ApplyAction(); ApplyActionIE != nil {
+			switch {
+			case ApplyActionIE.HasDROP():
+				ApplyActionLabel = "DROP"
+			case ApplyActionIE.HasFORW():
+				ApplyActionLabel = "FORW"
+			default:
+				ApplyActionLabel = "Other"
 			}
+		}
 
-			DestinationInterfaceLabel := "Not defined"
-			if destination, err := ForwardingParametersIe.DestinationInterface(); err == nil {
-				switch destination {
-				case ie.DstInterfaceAccess:
-					DestinationInterfaceLabel = "Access"
-				case ie.DstInterfaceCore:
-					DestinationInterfaceLabel = "Core"
-				case ie.DstInterfaceSGiLANN6LAN:
-					DestinationInterfaceLabel = "SGi-LAN/N6-LAN"
-				case ie.DstInterfaceCPFunction:
-					DestinationInterfaceLabel = "CP Function"
-				case ie.DstInterfaceLIFunction:
-					DestinationInterfaceLabel = "LI Function"
-				case ie.DstInterface5GVNInternal:
-					DestinationInterfaceLabel = "5G VN Internal"
-				}
+		ForwardingParametersIe := far.ForwardingParameters()
+		OuterHeaderCreationLabel := "No"
+		if ohc, err := ForwardingParametersIe.OuterHeaderCreation(); err == nil {
+			ohcb, _ := ohc.Marshal()
+			ohcIe := ie.New(ie.OuterHeaderCreation, ohcb)
+			switch {
+			case ohcIe.HasTEID() && ohcIe.HasIPv4():
+				OuterHeaderCreationLabel = fmt.Sprintf("[%s (%d)]", ohc.IPv4Address.String(), ohc.TEID)
+			case ohcIe.HasTEID() && ohcIe.HasIPv6():
+				OuterHeaderCreationLabel = fmt.Sprintf("[%s (%d)]", ohc.IPv6Address.String(), ohc.TEID)
+			default:
+				OuterHeaderCreationLabel = "Other"
 			}
+		}
 
-			log.Printf("  ↦ [PDR %d] (%d) Source interface: %s, OHR: %s, F-TEID: %s, UE IP: %s\n", pdrid, precedence, sourceInterfaceLabel, OuterHeaderRemovalLabel, fteidLabel, ueIpAddressLabel)
-			if SDFFilterLabel != "" {
-				log.Printf("    ↪ %s\n", SDFFilterLabel)
+		DestinationInterfaceLabel := "Not defined"
+		if destination, err := ForwardingParametersIe.DestinationInterface(); err == nil {
+			switch destination {
+			case ie.DstInterfaceAccess:
+				DestinationInterfaceLabel = "Access"
+			case ie.DstInterfaceCore:
+				DestinationInterfaceLabel = "Core"
+			case ie.DstInterfaceSGiLANN6LAN:
+				DestinationInterfaceLabel = "SGi-LAN/N6-LAN"
+			case ie.DstInterfaceCPFunction:
+				DestinationInterfaceLabel = "CP Function"
+			case ie.DstInterfaceLIFunction:
+				DestinationInterfaceLabel = "LI Function"
+			case ie.DstInterface5GVNInternal:
+				DestinationInterfaceLabel = "5G VN Internal"
 			}
-			log.Printf("    ↪ [FAR %d] OHC: %s, ApplyAction: %s, Destination interface: %s\n", farid, OuterHeaderCreationLabel, ApplyActionLabel, DestinationInterfaceLabel)
 		}
-		log.Printf("\n")
+
+		logger.Debug("PDR",
+			slog.Uint64("pdr_id", uint64(pdrid)),
+			slog.Uint64("precedence", uint64(precedence)),
+			slog.String("source_interface", sourceInterfaceLabel),
+			slog.String("outer_header_removal", OuterHeaderRemovalLabel),
+			slog.String("fteid", fteidLabel),
+			slog.String("ue_ip_address", ueIpAddressLabel),
+			slog.Any("sdf_filters", SDFFilterLabels),
+			slog.Uint64("far_id", uint64(farid)),
+			slog.String("outer_header_creation", OuterHeaderCreationLabel),
+			slog.String("apply_action", ApplyActionLabel),
+			slog.String("destination_interface", DestinationInterfaceLabel),
+		)
 	}
 }
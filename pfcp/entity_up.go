@@ -6,20 +6,27 @@
 package pfcp_networking
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
+	"sync"
 
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
 	"github.com/wmnsk/go-pfcp/message"
 )
 
 type PFCPEntityUP struct {
 	PFCPEntity
+	provisioning   *PFCPAssociation // pseudo-association of provisioned sessions, nil if none
+	provisioningMu sync.Mutex
 }
 
 func NewPFCPEntityUP(nodeID string) *PFCPEntityUP {
 	e := PFCPEntityUP{PFCPEntity: NewPFCPEntity(nodeID, "UP")}
+	e.teidAllocator = NewTEIDPools()
+	e.ueipAllocator = NewUEIPPools()
 	err := e.initDefaultHandlers()
 	if err != nil {
-		log.Println(err)
+		e.Logger().Error("Cannot add default handlers", slog.Any("error", err))
 	}
 	return &e
 }
@@ -34,5 +41,68 @@ func (e *PFCPEntityUP) initDefaultHandlers() error {
 	if err := e.AddHandler(message.MsgTypeSessionModificationRequest, DefaultSessionModificationRequestHandler); err != nil {
 		return err
 	}
+	if err := e.AddHandler(message.MsgTypeSessionDeletionRequest, DefaultSessionDeletionRequestHandler); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Set the allocator of local F-TEIDs, used when the CP function sets the CH flag.
+// The default allocator is a TEIDPools without any pool.
+// This must be called before starting the entity.
+func (e *PFCPEntityUP) SetTEIDAllocator(allocator api.TEIDAllocatorInterface) error {
+	if e.RecoveryTimeStamp() != nil {
+		return fmt.Errorf("Cannot change TEID allocator of already started PFCP Entity")
+	}
+	e.teidAllocator = allocator
+	return nil
+}
+
+// Add a TEID pool to the default allocator, for PDIs with this Network Instance and Source Interface
+func (e *PFCPEntityUP) AddTEIDPool(networkInstance string, sourceInterface uint8, pool *TEIDPool) error {
+	pools, ok := e.teidAllocator.(*TEIDPools)
+	if !ok {
+		return fmt.Errorf("TEID allocator is not the default one")
+	}
+	return pools.Add(networkInstance, sourceInterface, pool)
+}
+
+// Set the allocator of UE IP addresses, used when the CP function sets the CHV4 or CHV6 flag.
+// The default allocator is a UEIPPools without any pool.
+// This must be called before starting the entity.
+func (e *PFCPEntityUP) SetUEIPAllocator(allocator api.UEIPAllocatorInterface) error {
+	if e.RecoveryTimeStamp() != nil {
+		return fmt.Errorf("Cannot change UE IP allocator of already started PFCP Entity")
+	}
+	e.ueipAllocator = allocator
+	return nil
+}
+
+// Add an UE IPv4 pool to the default allocator, for PDIs with this Network Instance
+func (e *PFCPEntityUP) AddUEIPv4Pool(networkInstance string, pool *UEIPv4Pool) error {
+	pools, ok := e.ueipAllocator.(*UEIPPools)
+	if !ok {
+		return fmt.Errorf("UE IP allocator is not the default one")
+	}
+	return pools.AddIPv4Pool(networkInstance, pool)
+}
+
+// Add an UE IPv6 prefix pool to the default allocator, for PDIs with this Network Instance
+func (e *PFCPEntityUP) AddUEIPv6PrefixPool(networkInstance string, pool *UEIPv6PrefixPool) error {
+	pools, ok := e.ueipAllocator.(*UEIPPools)
+	if !ok {
+		return fmt.Errorf("UE IP allocator is not the default one")
+	}
+	return pools.AddIPv6PrefixPool(networkInstance, pool)
+}
+
+// Set the datapath into which rules of sessions are programmed.
+// By default, rules are only kept in memory.
+// This must be called before starting the entity.
+func (e *PFCPEntityUP) SetDatapath(datapath api.DatapathInterface) error {
+	if e.RecoveryTimeStamp() != nil {
+		return fmt.Errorf("Cannot change datapath of already started PFCP Entity")
+	}
+	e.datapath = datapath
 	return nil
 }
@@ -0,0 +1,158 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nextmn/go-pfcp-networking/pfcputil"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Cause values below this one are acceptance causes,
+// other cause values are rejection causes (TS 29.244, section 8.2.1)
+const firstRejectionCause = 64
+
+// A CauseError is a PFCP Cause: either a Request rejected by a peer,
+// or a local validation failure to be reported to a peer.
+// Use errors.As to retrieve it.
+type CauseError struct {
+	Cause       uint8
+	OffendingIE uint16 // type of the Offending IE, 0 if none
+	Peer        *ie.IE // NodeID of the peer rejecting the Request, nil for local validation failures
+	MessageType uint8  // type of the rejected Request, 0 for local validation failures
+	Err         error  // underlying error, may be nil
+}
+
+func (e *CauseError) Error() string {
+	s := fmt.Sprintf("cause %d", e.Cause)
+	if e.OffendingIE != 0 {
+		s = fmt.Sprintf("%s, offending IE %d", s, e.OffendingIE)
+	}
+	if e.MessageType != 0 {
+		peer := ""
+		if e.Peer != nil {
+			if nid, err := e.Peer.NodeID(); err == nil {
+				peer = " by " + nid
+			}
+		}
+		s = fmt.Sprintf("%s rejected%s (%s)", pfcputil.MessageTypeName(e.MessageType), peer, s)
+	} else {
+		s = fmt.Sprintf("Validation failed (%s)", s)
+	}
+	if e.Err != nil {
+		s = fmt.Sprintf("%s: %s", s, e.Err)
+	}
+	return s
+}
+
+func (e *CauseError) Unwrap() error {
+	return e.Err
+}
+
+// Create a CauseError for a local validation failure
+func newValidationError(err error, cause uint8, offendingIE uint16) *CauseError {
+	return &CauseError{
+		Cause:       cause,
+		OffendingIE: offendingIE,
+		Err:         err,
+	}
+}
+
+// Create a CauseError for a failure of the datapath
+func newDatapathError(err error) *CauseError {
+	return newValidationError(fmt.Errorf("Datapath failure: %w", err), ie.CauseRuleCreationModificationFailure, 0)
+}
+
+// Returns err if it is a CauseError, or a CauseError with defaultCause wrapping it
+func withDefaultCause(err error, defaultCause uint8) error {
+	var cerr *CauseError
+	if errors.As(err, &cerr) {
+		return err
+	}
+	return newValidationError(err, defaultCause, 0)
+}
+
+// Returns cause and offending IE of an error, to be sent in a Response.
+// Errors which are not a CauseError are reported with defaultCause.
+func causeOfError(err error, defaultCause uint8) (cause uint8, offendingIE uint16) {
+	var cerr *CauseError
+	if errors.As(err, &cerr) {
+		return cerr.Cause, cerr.OffendingIE
+	}
+	return defaultCause, 0
+}
+
+// Returns the Cause IE of an error, followed by its Offending IE when there is one
+func causeIEsOfError(err error, defaultCause uint8) []*ie.IE {
+	cause, offendingIE := causeOfError(err, defaultCause)
+	if offendingIE == 0 {
+		return []*ie.IE{ie.NewCause(cause)}
+	}
+	return []*ie.IE{ie.NewCause(cause), ie.NewOffendingIE(offendingIE)}
+}
+
+// Returns a CauseError if the Response has a rejection cause, nil otherwise
+func rejectionFromResponse(peer *ie.IE, requestType uint8, res message.Message) *CauseError {
+	b := make([]byte, res.MarshalLen())
+	if err := res.MarshalTo(b); err != nil {
+		return nil
+	}
+	h, err := message.ParseHeader(b)
+	if err != nil {
+		return nil
+	}
+	ies, err := ie.ParseMultiIEs(h.Payload)
+	if err != nil {
+		return nil
+	}
+	rejection := &CauseError{
+		Peer:        peer,
+		MessageType: requestType,
+	}
+	hasCause := false
+	for _, i := range ies {
+		switch i.Type {
+		case ie.Cause:
+			if rejection.Cause, err = i.Cause(); err == nil {
+				hasCause = true
+			}
+		case ie.OffendingIE:
+			if o, err := i.OffendingIE(); err == nil {
+				rejection.OffendingIE = o
+			}
+		}
+	}
+	if !hasCause || rejection.Cause < firstRejectionCause {
+		return nil
+	}
+	return rejection
+}
+
+// A TimeoutError is a Request for which no Response has been received,
+// after all retransmissions
+type TimeoutError struct {
+	Peer            *ie.IE // NodeID of the peer
+	MessageType     uint8  // type of the Request
+	Retransmissions int
+}
+
+func (e *TimeoutError) Error() string {
+	peer := ""
+	if e.Peer != nil {
+		if nid, err := e.Peer.NodeID(); err == nil {
+			peer = " to " + nid
+		}
+	}
+	return fmt.Sprintf("Unsuccessfull transfer of %s%s after %d retransmissions", pfcputil.MessageTypeName(e.MessageType), peer, e.Retransmissions)
+}
+
+// Timeout errors are reported as such by net.Error-like checks
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
@@ -125,7 +125,7 @@ func (m *FARMap) NewCreateFARs() []*ie.IE {
 	return f
 }
 
-func NewFARMap(fars []*ie.IE) (farmap *FARMap, err error, cause uint8, offendingIE uint16) {
+func NewFARMap(fars []*ie.IE) (farmap *FARMap, err error) {
 	f := FARMap{
 		farmap: make(farmapInternal),
 		mu:     sync.RWMutex{},
@@ -135,22 +135,22 @@ func NewFARMap(fars []*ie.IE) (farmap *FARMap, err error, cause uint8, offending
 		if err != nil {
 			switch err {
 			case io.ErrUnexpectedEOF:
-				return nil, err, ie.CauseInvalidLength, ie.FARID
+				return nil, newValidationError(err, ie.CauseInvalidLength, ie.FARID)
 			case ie.ErrIENotFound:
-				return nil, err, ie.CauseMandatoryIEMissing, ie.FARID
+				return nil, newValidationError(err, ie.CauseMandatoryIEMissing, ie.FARID)
 			default:
-				return nil, err, ie.CauseMandatoryIEIncorrect, ie.CreateFAR
+				return nil, newValidationError(err, ie.CauseMandatoryIEIncorrect, far.Type)
 			}
 		}
 		aa, err := far.ApplyAction()
 		if err != nil {
 			switch err {
 			case io.ErrUnexpectedEOF:
-				return nil, err, ie.CauseInvalidLength, ie.ApplyAction
+				return nil, newValidationError(err, ie.CauseInvalidLength, ie.ApplyAction)
 			case ie.ErrIENotFound:
-				return nil, err, ie.CauseMandatoryIEMissing, ie.ApplyAction
+				return nil, newValidationError(err, ie.CauseMandatoryIEMissing, ie.ApplyAction)
 			default:
-				return nil, err, ie.CauseMandatoryIEIncorrect, ie.CreateFAR
+				return nil, newValidationError(err, ie.CauseMandatoryIEIncorrect, far.Type)
 			}
 		}
 
@@ -165,19 +165,19 @@ func NewFARMap(fars []*ie.IE) (farmap *FARMap, err error, cause uint8, offending
 				//XXX:  workaround for a free5gc-smf bug: Forwarding Parameters are missing sometimes
 				fp = make([]*ie.IE, 0)
 				//			if err == io.ErrUnexpectedEOF {
-				//				return nil, err, ie.CauseInvalidLength, ie.ForwardingParameters
+				//				return nil, newValidationError(err, ie.CauseInvalidLength, ie.ForwardingParameters)
 				//			}
 				//			if ie.NewApplyAction(aa).HasFORW() && err == ie.ErrIENotFound {
-				//				return nil, err, ie.CauseConditionalIEMissing, ie.ForwardingParameters
+				//				return nil, newValidationError(err, ie.CauseConditionalIEMissing, ie.ForwardingParameters)
 				//			}
 			}
 		}
 
 		err = f.Add(NewFAR(ie.NewFARID(id), ie.NewApplyAction(aa...), ie.NewForwardingParameters(fp...)))
 		if err != nil {
-			return nil, err, ie.CauseMandatoryIEIncorrect, ie.CreateFAR
+			return nil, newValidationError(err, ie.CauseMandatoryIEIncorrect, far.Type)
 		}
 	}
-	return &f, nil, 0, 0
+	return &f, nil
 
 }
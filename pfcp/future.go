@@ -0,0 +1,50 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// PFCPFuture holds the Response to a Request sent with PFCPPeer.SendAsync
+type PFCPFuture struct {
+	done chan struct{}
+	msg  message.Message
+	err  error
+}
+
+func newPFCPFuture() *PFCPFuture {
+	return &PFCPFuture{
+		done: make(chan struct{}),
+		msg:  nil,
+		err:  nil,
+	}
+}
+
+// Create a future that is already completed with an error
+func newFailedPFCPFuture(err error) *PFCPFuture {
+	f := newPFCPFuture()
+	f.complete(nil, err)
+	return f
+}
+
+// Must be called exactly once
+func (f *PFCPFuture) complete(msg message.Message, err error) {
+	f.msg = msg
+	f.err = err
+	close(f.done)
+}
+
+// Done is closed when the transaction is over
+func (f *PFCPFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the transaction is over, and returns the Response
+func (f *PFCPFuture) Wait() (m message.Message, err error) {
+	<-f.done
+	return f.msg, f.err
+}
@@ -8,7 +8,7 @@ package pfcp_networking
 import (
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 
 	"github.com/nextmn/go-pfcp-networking/pfcp/api"
@@ -18,14 +18,30 @@ import (
 
 type PFCPMessageHandler = func(receivedMessage ReceivedMessage) error
 
+// A PFCPMessageMiddleware wraps a PFCPMessageHandler.
+// It can perform actions before and after calling next,
+// or short-circuit next entirely (for example by replying directly
+// to the received message with ReplyTo).
+type PFCPMessageMiddleware = func(next PFCPMessageHandler) PFCPMessageHandler
+
 func DefaultHeartbeatRequestHandler(msg ReceivedMessage) error {
-	log.Println("Received Heartbeat Request")
+	msg.Logger().Debug("Received Heartbeat Request")
+	m, ok := msg.Message.(*message.HeartbeatRequest)
+	if !ok {
+		return fmt.Errorf("Issue with Heartbeat Request")
+	}
+	// Recovery Time Stamp is a mandatory IE, and Heartbeat Response has no Cause:
+	// the Request is discarded
+	if m.RecoveryTimeStamp == nil {
+		msg.Logger().Info("Discarding Heartbeat Request without Recovery Time Stamp")
+		return nil
+	}
 	res := message.NewHeartbeatResponse(msg.Sequence(), msg.Entity.RecoveryTimeStamp())
 	return msg.ReplyTo(res)
 }
 
 func DefaultAssociationSetupRequestHandler(msg ReceivedMessage) error {
-	log.Println("Received Association Setup Request")
+	msg.Logger().Debug("Received Association Setup Request")
 	m, ok := msg.Message.(*message.AssociationSetupRequest)
 	if !ok {
 		return fmt.Errorf("Issue with Association Setup Request")
@@ -41,19 +57,34 @@ func DefaultAssociationSetupRequestHandler(msg ReceivedMessage) error {
 		return fmt.Errorf("entity.RecoveryTimeStamp() is nil")
 	}
 
+	// NodeID and Recovery Time Stamp are mandatory IEs
+	// (Association Setup Response has no Offending IE)
+	if m.NodeID == nil || m.RecoveryTimeStamp == nil {
+		res := message.NewAssociationSetupResponse(msg.Sequence(), msg.Entity.NodeID(), ie.NewCause(ie.CauseMandatoryIEMissing), msg.Entity.RecoveryTimeStamp())
+		return msg.ReplyTo(res)
+	}
+	if _, err := m.NodeID.NodeID(); err != nil {
+		cause := ie.CauseMandatoryIEIncorrect
+		if err == io.ErrUnexpectedEOF {
+			cause = ie.CauseInvalidLength
+		}
+		res := message.NewAssociationSetupResponse(msg.Sequence(), msg.Entity.NodeID(), ie.NewCause(cause), msg.Entity.RecoveryTimeStamp())
+		return msg.ReplyTo(res)
+	}
+
 	if _, err := msg.Entity.NewEstablishedPFCPAssociation(m.NodeID); err != nil {
-		log.Println("Rejected Association:", err)
+		msg.Logger().Info("Rejected Association", logAttrNodeID(LogKeyPeer, m.NodeID), slog.Any("error", err))
 		res := message.NewAssociationSetupResponse(msg.Sequence(), msg.Entity.NodeID(), ie.NewCause(ie.CauseRequestRejected), msg.Entity.RecoveryTimeStamp())
 		return msg.ReplyTo(res)
 	}
 
-	log.Println("Association Accepted")
+	msg.Logger().Info("Association Accepted", logAttrNodeID(LogKeyPeer, m.NodeID))
 	res := message.NewAssociationSetupResponse(msg.Sequence(), msg.Entity.NodeID(), ie.NewCause(ie.CauseRequestAccepted), msg.Entity.RecoveryTimeStamp())
 	return msg.ReplyTo(res)
 }
 
 func DefaultSessionEstablishmentRequestHandler(msg ReceivedMessage) error {
-	log.Println("Received Session Establishment Request")
+	msg.Logger().Debug("Received Session Establishment Request")
 	m, ok := msg.Message.(*message.SessionEstablishmentRequest)
 	if !ok {
 		return fmt.Errorf("Issue with Session Establishment Request")
@@ -77,13 +108,12 @@ func DefaultSessionEstablishmentRequestHandler(msg ReceivedMessage) error {
 		}
 		res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, msg.Entity.NodeID(), ie.NewCause(cause), ie.NewOffendingIE(ie.FSEID))
 		return msg.ReplyTo(res)
-		return err
 	}
 	rseid = fseid.SEID
 
 	// Sender must have established a PFCP Association with the Receiver Node
-	if _, err := checkSenderAssociation(msg.Entity, msg.SenderAddr); err != nil {
-		log.Println(err)
+	if msg.Association == nil {
+		msg.Logger().Info("Sender has no active association")
 		res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, msg.Entity.NodeID(), ie.NewCause(ie.CauseNoEstablishedPFCPAssociation))
 		return msg.ReplyTo(res)
 	}
@@ -114,94 +144,77 @@ func DefaultSessionEstablishmentRequestHandler(msg ReceivedMessage) error {
 		return msg.ReplyTo(res)
 	}
 
-	// CreatePDR is a Mandatory IE
-	if m.CreatePDR == nil || len(m.CreatePDR) == 0 {
-		res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, msg.Entity.NodeID(), ie.NewCause(ie.CauseMandatoryIEMissing), ie.NewOffendingIE(ie.CreatePDR))
+	session, createdpdrs, err := establishSession(msg.Entity, msg.localResources, m.CreatePDR, m.CreateFAR, func(pdrs api.PDRMapInterface, fars api.FARMapInterface) (api.PFCPSessionInterface, error) {
+		return association.CreateSession(m.CPFSEID, pdrs, fars)
+	})
+	if err != nil {
+		msg.Logger().Info("Cannot create session", slog.Any("error", err))
+		ies := append([]*ie.IE{msg.Entity.NodeID()}, causeIEsOfError(err, ie.CauseRuleCreationModificationFailure)...)
+		res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, ies...)
 		return msg.ReplyTo(res)
 	}
+	// TODO: Create other type IEs
+	// XXX: QER ie are ignored for the moment
+	// send response: session creation accepted
+	ies := append([]*ie.IE{msg.Entity.NodeID(), ie.NewCause(ie.CauseRequestAccepted), session.LocalFSEID()}, createdpdrs...)
+	res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, ies...)
+	return msg.ReplyTo(res)
+}
+
+// Validate Create PDR and Create FAR IEs of a new session, allocate F-TEIDs and UE IP addresses
+// they request, then create the session with create.
+// Errors are CauseErrors to be reported to the CP function;
+// Created PDR IEs are returned to be sent to the CP function.
+func establishSession(entity api.PFCPEntityInterface, resourcesMap *localResources, createPDRs []*ie.IE, createFARs []*ie.IE, create func(pdrs api.PDRMapInterface, fars api.FARMapInterface) (api.PFCPSessionInterface, error)) (session api.PFCPSessionInterface, createdpdrs []*ie.IE, err error) {
+	// CreatePDR is a Mandatory IE
+	if len(createPDRs) == 0 {
+		return nil, nil, newValidationError(fmt.Errorf("Create PDR is missing"), ie.CauseMandatoryIEMissing, ie.CreatePDR)
+	}
 
 	// CreateFAR is a Mandatory IE
-	if m.CreateFAR == nil || len(m.CreateFAR) == 0 {
-		res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, msg.Entity.NodeID(), ie.NewCause(ie.CauseMandatoryIEMissing), ie.NewOffendingIE(ie.CreateFAR))
-		return msg.ReplyTo(res)
+	if len(createFARs) == 0 {
+		return nil, nil, newValidationError(fmt.Errorf("Create FAR is missing"), ie.CauseMandatoryIEMissing, ie.CreateFAR)
 	}
 
 	// create PDRs
-	pdrs, err, cause, offendingie := NewPDRMap(m.CreatePDR)
+	pdrs, err := NewPDRMap(createPDRs)
 	if err != nil {
-		res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, msg.Entity.NodeID(), ie.NewCause(cause), ie.NewOffendingIE(offendingie))
-		return msg.ReplyTo(res)
+		return nil, nil, withDefaultCause(err, ie.CauseMandatoryIEIncorrect)
 	}
 
 	// create FARs
-	fars, err, cause, offendingie := NewFARMap(m.CreateFAR)
+	fars, err := NewFARMap(createFARs)
 	if err != nil {
-		res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, msg.Entity.NodeID(), ie.NewCause(cause), ie.NewOffendingIE(offendingie))
-		return msg.ReplyTo(res)
+		return nil, nil, withDefaultCause(err, ie.CauseMandatoryIEIncorrect)
+	}
+
+	// allocate F-TEIDs and UE IP addresses requested with the CH, CHV4 and CHV6 flags
+	resources := newSessionResources(entity.TEIDAllocator(), entity.UEIPAllocator())
+	createdpdrs, undoAllocations, err := resources.allocate(pdrs, ie.NewCreatedPDR)
+	if err != nil {
+		return nil, nil, withDefaultCause(err, ie.CauseRuleCreationModificationFailure)
 	}
 
 	// create session with PDRs and FARs
-	session, err := association.CreateSession(m.CPFSEID, pdrs, fars)
+	session, err = create(pdrs, fars)
 	if err != nil {
-		// Send cause(Rule creation/modification failure)
-		res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, msg.Entity.NodeID(), ie.NewCause(ie.CauseRuleCreationModificationFailure))
-		return msg.ReplyTo(res)
+		undoAllocations()
+		return nil, nil, withDefaultCause(err, ie.CauseRuleCreationModificationFailure)
 	}
-	// TODO: Create other type IEs
-	// XXX: QER ie are ignored for the moment
-	// send response: session creation accepted
-	res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, msg.Entity.NodeID(), ie.NewCause(ie.CauseRequestAccepted), session.LocalFSEID())
-	return msg.ReplyTo(res)
+	resourcesMap.set(session, resources)
+	return session, createdpdrs, nil
 }
 
 func DefaultSessionModificationRequestHandler(msg ReceivedMessage) error {
-	log.Println("Received Session Modification Request")
+	msg.Logger().Debug("Received Session Modification Request")
 	m, ok := msg.Message.(*message.SessionModificationRequest)
 	if !ok {
 		return fmt.Errorf("Issue with Session Modification Request")
 	}
-	// PFCP session related messages for sessions that are already established are sent to the IP address received
-	// in the F-SEID allocated by the peer function or to the IP address of an alternative SMF in the SMF set
-	// (see clause 5.22). The former IP address needs not be configured in the look up information.
-
-	// Thereforce, use of checkSenderAssociation is prohibed when receiving Session Modification Request
-
-	// Find the Session by its F-SEID
-	ielocalnodeid := msg.Entity.NodeID()
-	localnodeid, err := ielocalnodeid.NodeID()
-	if err != nil {
-		return err
-	}
-	var localip string
-	switch ielocalnodeid.Payload[0] {
-	case ie.NodeIDIPv4Address:
-		ip4, err := net.ResolveIPAddr("ip4", localnodeid)
-		if err != nil {
-			return err
-		}
-		localip = ip4.String()
-	case ie.NodeIDIPv6Address:
-		ip6, err := net.ResolveIPAddr("ip6", localnodeid)
-		if err != nil {
-			return err
-		}
-		localip = ip6.String()
-	case ie.NodeIDFQDN:
-		ip4, _ := net.ResolveIPAddr("ip4", localnodeid)
-		ip6, _ := net.ResolveIPAddr("ip6", localnodeid)
-		// XXX handle localip in fseid sessions.go session_map.go when ip4 and ip6 are set
-		switch {
-		case ip6 != nil:
-			localip = ip6.String()
-		case ip4 != nil:
-			localip = ip4.String()
-		default:
-			return fmt.Errorf("Cannot resolve NodeID")
-		}
-	}
-	localseid := msg.SEID()
-	session, err := msg.Entity.GetPFCPSession(localip, localseid)
-	if err != nil {
+	// Session is found by its F-SEID (use of the association of the sender is prohibed,
+	// see ReceivedMessage)
+	session := msg.Session
+	if session == nil {
 		res := message.NewSessionModificationResponse(0, 0, 0, msg.Sequence(), 0, ie.NewCause(ie.CauseSessionContextNotFound))
 		return msg.ReplyTo(res)
 	}
@@ -219,46 +232,101 @@ func DefaultSessionModificationRequestHandler(msg ReceivedMessage) error {
 	//XXX: CP F-SEID is ignored for the moment
 
 	// create PDRs
-	createpdrs, err, cause, offendingie := NewPDRMap(m.CreatePDR)
+	createpdrs, err := NewPDRMap(m.CreatePDR)
 	if err != nil {
-		res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, msg.Entity.NodeID(), ie.NewCause(cause), ie.NewOffendingIE(offendingie))
+		res := message.NewSessionModificationResponse(0, 0, rseid, msg.Sequence(), 0, causeIEsOfError(err, ie.CauseMandatoryIEIncorrect)...)
 		return msg.ReplyTo(res)
 	}
 
 	// create FARs
-	createfars, err, cause, offendingie := NewFARMap(m.CreateFAR)
+	createfars, err := NewFARMap(m.CreateFAR)
 	if err != nil {
-		res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, msg.Entity.NodeID(), ie.NewCause(cause), ie.NewOffendingIE(offendingie))
+		res := message.NewSessionModificationResponse(0, 0, rseid, msg.Sequence(), 0, causeIEsOfError(err, ie.CauseMandatoryIEIncorrect)...)
 		return msg.ReplyTo(res)
 	}
 
 	// update PDRs
-	updatepdrs, err, cause, offendingie := NewPDRMap(m.UpdatePDR)
+	updatepdrs, err := NewPDRMap(m.UpdatePDR)
 	if err != nil {
-		res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, msg.Entity.NodeID(), ie.NewCause(cause), ie.NewOffendingIE(offendingie))
+		res := message.NewSessionModificationResponse(0, 0, rseid, msg.Sequence(), 0, causeIEsOfError(err, ie.CauseMandatoryIEIncorrect)...)
 		return msg.ReplyTo(res)
 	}
 
 	// update FARs
-	updatefars, err, cause, offendingie := NewFARMap(m.UpdateFAR)
+	updatefars, err := NewFARMap(m.UpdateFAR)
+	if err != nil {
+		res := message.NewSessionModificationResponse(0, 0, rseid, msg.Sequence(), 0, causeIEsOfError(err, ie.CauseMandatoryIEIncorrect)...)
+		return msg.ReplyTo(res)
+	}
+
+	// allocate F-TEIDs and UE IP addresses requested with the CH, CHV4 and CHV6 flags
+	resources := msg.localResources.get(msg.Entity, session)
+	createdpdrs, undoCreateAllocations, err := resources.allocate(createpdrs, ie.NewCreatedPDR)
 	if err != nil {
-		res := message.NewSessionEstablishmentResponse(0, 0, rseid, msg.Sequence(), 0, msg.Entity.NodeID(), ie.NewCause(cause), ie.NewOffendingIE(offendingie))
+		res := message.NewSessionModificationResponse(0, 0, rseid, msg.Sequence(), 0, causeIEsOfError(err, ie.CauseRuleCreationModificationFailure)...)
 		return msg.ReplyTo(res)
 	}
+	updatedpdrs, undoUpdateAllocations, err := resources.allocate(updatepdrs, ie.NewUpdatedPDR)
+	if err != nil {
+		undoCreateAllocations()
+		res := message.NewSessionModificationResponse(0, 0, rseid, msg.Sequence(), 0, causeIEsOfError(err, ie.CauseRuleCreationModificationFailure)...)
+		return msg.ReplyTo(res)
+	}
+	undoAllocations := func() {
+		undoUpdateAllocations()
+		undoCreateAllocations()
+	}
 
 	err = session.AddUpdatePDRsFARs(createpdrs, createfars, updatepdrs, updatefars)
 	if err != nil {
-		//XXX, offending IE
-		res := message.NewSessionModificationResponse(0, 0, rseid, msg.Sequence(), 0, ie.NewCause(ie.CauseRequestRejected))
+		undoAllocations()
+		msg.Logger().Info("Cannot modify session", slog.Any("error", err))
+		//XXX: Failed Rule ID IE
+		res := message.NewSessionModificationResponse(0, 0, rseid, msg.Sequence(), 0, causeIEsOfError(err, ie.CauseRuleCreationModificationFailure)...)
 		return msg.ReplyTo(res)
 	}
+	// resources of updated PDRs may have been replaced
+	resources.releaseUnused(session)
+	msg.localResources.set(session, resources)
 
 	//XXX: QER modification/creation is ignored for the moment
 	//XXX: Remove PDR
 	//XXX: Remove FAR
 	//XXX: RemoveQER
 
-	res := message.NewSessionModificationResponse(0, 0, rseid, msg.Sequence(), 0, ie.NewCause(ie.CauseRequestAccepted))
+	ies := append([]*ie.IE{ie.NewCause(ie.CauseRequestAccepted)}, createdpdrs...)
+	ies = append(ies, updatedpdrs...)
+	res := message.NewSessionModificationResponse(0, 0, rseid, msg.Sequence(), 0, ies...)
+	return msg.ReplyTo(res)
+}
+
+func DefaultSessionDeletionRequestHandler(msg ReceivedMessage) error {
+	msg.Logger().Debug("Received Session Deletion Request")
+	if _, ok := msg.Message.(*message.SessionDeletionRequest); !ok {
+		return fmt.Errorf("Issue with Session Deletion Request")
+	}
+	// Session is found by its F-SEID (use of the association of the sender is prohibed,
+	// see ReceivedMessage)
+	session := msg.Session
+	if session == nil {
+		res := message.NewSessionDeletionResponse(0, 0, 0, msg.Sequence(), 0, ie.NewCause(ie.CauseSessionContextNotFound))
+		return msg.ReplyTo(res)
+	}
+
+	rseid, err := session.RemoteSEID()
+	if err != nil {
+		return err
+	}
+
+	if err := msg.Entity.RemovePFCPSession(session); err != nil {
+		msg.Logger().Info("Cannot delete session", slog.Any("error", err))
+		cause, _ := causeOfError(err, ie.CauseSessionContextNotFound)
+		res := message.NewSessionDeletionResponse(0, 0, rseid, msg.Sequence(), 0, ie.NewCause(cause))
+		return msg.ReplyTo(res)
+	}
+
+	//XXX: Usage Reports are not supported for the moment
+	res := message.NewSessionDeletionResponse(0, 0, rseid, msg.Sequence(), 0, ie.NewCause(ie.CauseRequestAccepted))
 	return msg.ReplyTo(res)
 }
 
@@ -266,7 +334,11 @@ func checkSenderAssociation(entity api.PFCPEntityInterface, senderAddr net.Addr)
 	// Once the PFCP Association is established, any of the IP addresses of the peer
 	// function (found during the look-up) may then be used to send subsequent PFCP node related messages and PFCP
 	// session establishment requests for that PFCP Association.
-	nid := senderAddr.(*net.UDPAddr).IP.String()
+	udpAddr, ok := senderAddr.(*net.UDPAddr)
+	if !ok {
+		return nil, fmt.Errorf("Sender address is not an UDP address")
+	}
+	nid := udpAddr.IP.String()
 	association, err := entity.GetPFCPAssociation(nid)
 	if err != nil {
 		// TODO
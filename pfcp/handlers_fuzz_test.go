@@ -0,0 +1,244 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nextmn/go-pfcp-networking/pfcpcapture"
+	"github.com/nextmn/go-pfcp-networking/pfcptransport"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Seed corpus is in testdata/fuzz/<FuzzName>, completed with the Requests of testdata/smf.pcap:
+// a capture, recorded with pfcpcapture, of a CP function establishing, modifying,
+// and deleting a session with PDRs, FARs and QERs as a SMF does.
+
+var fuzzCPAddr = &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8805}
+
+// Start a UP function with TEID and UE IP pools, associated with a CP function
+func newFuzzUP(f *testing.F) *PFCPEntityUP {
+	f.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	network := pfcptransport.NewMemoryNetwork()
+	up := NewPFCPEntityUP("10.0.0.2")
+	if err := up.SetTransport(network); err != nil {
+		f.Fatal(err)
+	}
+	up.SetLogger(logger)
+	for _, ni := range []string{"", "internet"} {
+		for _, si := range []uint8{ie.SrcInterfaceAccess, ie.SrcInterfaceCore} {
+			pool, err := NewTEIDPool(net.ParseIP("10.0.0.2"), net.ParseIP("fd00::2"), 1, 1000)
+			if err != nil {
+				f.Fatal(err)
+			}
+			if err := up.AddTEIDPool(ni, si, pool); err != nil {
+				f.Fatal(err)
+			}
+		}
+		_, v4, _ := net.ParseCIDR("10.45.0.0/24")
+		v4pool, err := NewUEIPv4Pool(v4)
+		if err != nil {
+			f.Fatal(err)
+		}
+		if err := up.AddUEIPv4Pool(ni, v4pool); err != nil {
+			f.Fatal(err)
+		}
+		_, v6, _ := net.ParseCIDR("fd45::/48")
+		v6pool, err := NewUEIPv6PrefixPool(v6, 64)
+		if err != nil {
+			f.Fatal(err)
+		}
+		if err := up.AddUEIPv6PrefixPool(ni, v6pool); err != nil {
+			f.Fatal(err)
+		}
+	}
+	if err := up.Start(); err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(func() { up.Close() })
+
+	cp := NewPFCPEntityCP(fuzzCPAddr.IP.String())
+	if err := cp.SetTransport(network); err != nil {
+		f.Fatal(err)
+	}
+	cp.SetLogger(logger)
+	if err := cp.Start(); err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(func() { cp.Close() })
+	if _, err := cp.NewEstablishedPFCPAssociation(up.NodeID()); err != nil {
+		f.Fatal(err)
+	}
+	return up
+}
+
+// Add captured messages of type msgType to the seed corpus
+func addCapturedSeeds(f *testing.F, msgType uint8) {
+	f.Helper()
+	file, err := os.Open(filepath.Join("testdata", "smf.pcap"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	defer file.Close()
+	r, err := pfcpcapture.NewReader(file)
+	if err != nil {
+		f.Fatal(err)
+	}
+	datagrams, err := r.ReadAll()
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, d := range datagrams {
+		if len(d.Payload) > 1 && d.Payload[1] == msgType {
+			f.Add(d.Payload)
+		}
+	}
+}
+
+// Handle a message received from the CP function, as done by the entity
+func handleFuzzMessage(up *PFCPEntityUP, msg message.Message, handler PFCPMessageHandler) {
+	handler(up.newReceivedMessage(context.Background(), msg, fuzzCPAddr))
+}
+
+// Remove every session, so each input starts from the same state
+func removeFuzzSessions(t *testing.T, up *PFCPEntityUP) {
+	for _, s := range up.GetPFCPSessions() {
+		if err := up.RemovePFCPSession(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Establish a session with the CP function, and returns its SEID
+func establishFuzzSession(t *testing.T, up *PFCPEntityUP) uint64 {
+	establishment := message.NewSessionEstablishmentRequest(0, 0, 0, 1, 0,
+		ie.NewNodeIDHeuristic(fuzzCPAddr.IP.String()),
+		ie.NewFSEID(1, fuzzCPAddr.IP.To4(), nil),
+		ie.NewCreatePDR(ie.NewPDRID(1), ie.NewPrecedence(1), ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceAccess), ie.NewFTEID(0x05, 0, nil, nil, 0)), ie.NewFARID(1)),
+		ie.NewCreateFAR(ie.NewFARID(1), ie.NewApplyAction(2), ie.NewForwardingParameters(ie.NewDestinationInterface(ie.DstInterfaceCore))),
+	)
+	handleFuzzMessage(up, establishment, DefaultSessionEstablishmentRequestHandler)
+	sessions := up.GetPFCPSessions()
+	if len(sessions) != 1 {
+		t.Fatalf("%d sessions have been established", len(sessions))
+	}
+	seid, err := sessions[0].LocalSEID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seid
+}
+
+func FuzzHeartbeatRequest(f *testing.F) {
+	up := newFuzzUP(f)
+	addCapturedSeeds(f, message.MsgTypeHeartbeatRequest)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := message.Parse(data)
+		if err != nil {
+			return
+		}
+		if _, ok := msg.(*message.HeartbeatRequest); !ok {
+			return
+		}
+		handleFuzzMessage(up, msg, DefaultHeartbeatRequestHandler)
+	})
+}
+
+func FuzzAssociationSetupRequest(f *testing.F) {
+	up := newFuzzUP(f)
+	addCapturedSeeds(f, message.MsgTypeAssociationSetupRequest)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := message.Parse(data)
+		if err != nil {
+			return
+		}
+		m, ok := msg.(*message.AssociationSetupRequest)
+		if !ok {
+			return
+		}
+		// FQDNs would be resolved using DNS
+		if m.NodeID != nil {
+			if typ, err := m.NodeID.NodeIDType(); err == nil && typ == ie.NodeIDFQDN {
+				return
+			}
+		}
+		// only the association with the CP function is kept
+		defer func() {
+			for _, a := range up.associationsMap.GetPFCPAssociations() {
+				if nid, err := a.NodeID().NodeID(); err == nil && nid == fuzzCPAddr.IP.String() {
+					continue
+				}
+				a.Close()
+				if err := up.RemovePFCPAssociation(a); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}()
+		handleFuzzMessage(up, msg, DefaultAssociationSetupRequestHandler)
+	})
+}
+
+func FuzzSessionEstablishmentRequest(f *testing.F) {
+	up := newFuzzUP(f)
+	addCapturedSeeds(f, message.MsgTypeSessionEstablishmentRequest)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := message.Parse(data)
+		if err != nil {
+			return
+		}
+		if _, ok := msg.(*message.SessionEstablishmentRequest); !ok {
+			return
+		}
+		defer removeFuzzSessions(t, up)
+		handleFuzzMessage(up, msg, DefaultSessionEstablishmentRequestHandler)
+	})
+}
+
+func FuzzSessionModificationRequest(f *testing.F) {
+	up := newFuzzUP(f)
+	addCapturedSeeds(f, message.MsgTypeSessionModificationRequest)
+	// every input modifies a new session
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := message.Parse(data)
+		if err != nil {
+			return
+		}
+		m, ok := msg.(*message.SessionModificationRequest)
+		if !ok {
+			return
+		}
+		defer removeFuzzSessions(t, up)
+		m.Header.SetSEID(establishFuzzSession(t, up))
+		handleFuzzMessage(up, m, DefaultSessionModificationRequestHandler)
+	})
+}
+
+func FuzzSessionDeletionRequest(f *testing.F) {
+	up := newFuzzUP(f)
+	addCapturedSeeds(f, message.MsgTypeSessionDeletionRequest)
+	// every input deletes a new session
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := message.Parse(data)
+		if err != nil {
+			return
+		}
+		m, ok := msg.(*message.SessionDeletionRequest)
+		if !ok {
+			return
+		}
+		defer removeFuzzSessions(t, up)
+		m.Header.SetSEID(establishFuzzSession(t, up))
+		handleFuzzMessage(up, m, DefaultSessionDeletionRequestHandler)
+	})
+}
@@ -0,0 +1,498 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	pfcp_networking "github.com/nextmn/go-pfcp-networking/pfcp"
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/nextmn/go-pfcp-networking/pfcptest"
+	"github.com/nextmn/go-pfcp-networking/pfcptransport"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+const (
+	testCPNodeID = "10.0.0.1"
+	testUPNodeID = "10.0.0.2"
+)
+
+// Start a FakeUP using default handlers, and a FakeCP associated with it.
+// The UP entity is given to configure functions before it is started.
+func newTestFunctions(t *testing.T, configure ...func(up *pfcp_networking.PFCPEntityUP) error) (*pfcptest.FakeCP, *pfcptest.FakeUP) {
+	t.Helper()
+	options := pfcptest.Options{
+		Transport: pfcptransport.NewMemoryNetwork(),
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	up, err := pfcptest.NewFakeUP(testUPNodeID, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range configure {
+		if err := c(up.Entity()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := up.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { up.Close() })
+	cp, err := pfcptest.NewFakeCP(testCPNodeID, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cp.Close() })
+	if _, err := cp.Associate(testUPNodeID); err != nil {
+		t.Fatal(err)
+	}
+	return cp, up
+}
+
+// Send a Request to the UP function; rejections are not errors
+func sendToUP(t *testing.T, cp *pfcptest.FakeCP, req message.Message) message.Message {
+	t.Helper()
+	association, err := cp.Association(testUPNodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := association.Send(req)
+	var rejection *pfcp_networking.CauseError
+	if err != nil && !(errors.As(err, &rejection) && res != nil) {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// Check Cause and Offending IE of a Response; offendingIE is 0 when the Offending IE must be absent
+func checkCause(t *testing.T, causeIE *ie.IE, offendingIEIE *ie.IE, cause uint8, offendingIE uint16) {
+	t.Helper()
+	if causeIE == nil {
+		t.Fatal("Cause is missing")
+	}
+	c, err := causeIE.Cause()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != cause {
+		t.Errorf("got cause %d, expected %d", c, cause)
+	}
+	switch {
+	case offendingIE == 0 && offendingIEIE != nil:
+		o, _ := offendingIEIE.OffendingIE()
+		t.Errorf("got Offending IE %d, expected none", o)
+	case offendingIE != 0 && offendingIEIE == nil:
+		t.Errorf("Offending IE is missing, expected %d", offendingIE)
+	case offendingIE != 0:
+		o, err := offendingIEIE.OffendingIE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o != offendingIE {
+			t.Errorf("got Offending IE %d, expected %d", o, offendingIE)
+		}
+	}
+}
+
+func testCreatePDR(ies ...*ie.IE) *ie.IE {
+	if len(ies) == 0 {
+		ies = []*ie.IE{ie.NewPDRID(1), ie.NewPrecedence(1), ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceAccess)), ie.NewFARID(1)}
+	}
+	return ie.NewCreatePDR(ies...)
+}
+
+func testCreateFAR(ies ...*ie.IE) *ie.IE {
+	if len(ies) == 0 {
+		ies = []*ie.IE{ie.NewFARID(1), ie.NewApplyAction(2), ie.NewForwardingParameters(ie.NewDestinationInterface(ie.DstInterfaceCore))}
+	}
+	return ie.NewCreateFAR(ies...)
+}
+
+func TestHeartbeatRequestHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		recoveryTimeStamp *ie.IE
+		discarded         bool // no Response is sent
+	}{
+		{name: "answered", recoveryTimeStamp: ie.NewRecoveryTimeStamp(time.Unix(1654084800, 0))},
+		{name: "missing Recovery Time Stamp", discarded: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cp, up := newTestFunctions(t)
+			association, err := cp.Association(testUPNodeID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			res, err := association.SendContext(ctx, message.NewHeartbeatRequest(0, tc.recoveryTimeStamp, nil))
+			if tc.discarded {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("got %v, expected %v", err, context.DeadlineExceeded)
+				}
+				if n := len(up.Recorder().MessagesOfType(message.MsgTypeHeartbeatResponse)); n != 0 {
+					t.Errorf("%d Heartbeat Responses have been sent", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			hb, ok := res.(*message.HeartbeatResponse)
+			if !ok {
+				t.Fatal("unexpected Response type")
+			}
+			if hb.RecoveryTimeStamp == nil {
+				t.Fatal("Recovery Time Stamp is missing")
+			}
+			expected, _ := up.Entity().RecoveryTimeStamp().RecoveryTimeStamp()
+			if got, err := hb.RecoveryTimeStamp.RecoveryTimeStamp(); err != nil || !got.Equal(expected) {
+				t.Errorf("got Recovery Time Stamp %v, expected %v", got, expected)
+			}
+		})
+	}
+}
+
+func TestAssociationSetupRequestHandler(t *testing.T) {
+	recoveryTimeStamp := ie.NewRecoveryTimeStamp(time.Unix(1654084800, 0))
+	for _, tc := range []struct {
+		name        string
+		ies         []*ie.IE
+		cause       uint8
+		association string // Node ID of the association expected on the UP function
+	}{
+		{name: "accepted", ies: []*ie.IE{ie.NewNodeIDHeuristic("10.0.0.3"), recoveryTimeStamp}, cause: ie.CauseRequestAccepted, association: "10.0.0.3"},
+		{name: "already associated", ies: []*ie.IE{ie.NewNodeIDHeuristic(testCPNodeID), recoveryTimeStamp}, cause: ie.CauseRequestRejected},
+		{name: "missing Node ID", ies: []*ie.IE{recoveryTimeStamp}, cause: ie.CauseMandatoryIEMissing},
+		{name: "short Node ID", ies: []*ie.IE{ie.New(ie.NodeID, []byte{ie.NodeIDIPv4Address}), recoveryTimeStamp}, cause: ie.CauseInvalidLength},
+		{name: "missing Recovery Time Stamp", ies: []*ie.IE{ie.NewNodeIDHeuristic("10.0.0.3")}, cause: ie.CauseMandatoryIEMissing},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cp, up := newTestFunctions(t)
+			res, ok := sendToUP(t, cp, message.NewAssociationSetupRequest(0, tc.ies...)).(*message.AssociationSetupResponse)
+			if !ok {
+				t.Fatal("unexpected Response type")
+			}
+			checkCause(t, res.Cause, nil, tc.cause, 0)
+			if res.NodeID == nil || res.RecoveryTimeStamp == nil {
+				t.Error("Node ID or Recovery Time Stamp is missing")
+			}
+			if tc.association == "" {
+				return
+			}
+			if _, err := up.Entity().GetPFCPAssociation(tc.association); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSessionEstablishmentRequestHandler(t *testing.T) {
+	nodeID := ie.NewNodeIDHeuristic(testCPNodeID)
+	fseid := ie.NewFSEID(1, []byte{10, 0, 0, 1}, nil)
+	pdi := ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceAccess))
+	for _, tc := range []struct {
+		name        string
+		ies         []*ie.IE
+		cause       uint8
+		offendingIE uint16
+	}{
+		{name: "accepted", ies: []*ie.IE{nodeID, fseid, testCreatePDR(), testCreateFAR()}, cause: ie.CauseRequestAccepted},
+		{name: "missing CP F-SEID", ies: []*ie.IE{nodeID, testCreatePDR(), testCreateFAR()}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.FSEID},
+		{name: "short CP F-SEID", ies: []*ie.IE{nodeID, ie.New(ie.FSEID, []byte{0x02, 0}), testCreatePDR(), testCreateFAR()}, cause: ie.CauseInvalidLength, offendingIE: ie.FSEID},
+		{name: "missing Node ID", ies: []*ie.IE{fseid, testCreatePDR(), testCreateFAR()}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.NodeID},
+		{name: "short Node ID", ies: []*ie.IE{ie.New(ie.NodeID, []byte{ie.NodeIDIPv4Address}), fseid, testCreatePDR(), testCreateFAR()}, cause: ie.CauseInvalidLength, offendingIE: ie.NodeID},
+		{name: "no association", ies: []*ie.IE{ie.NewNodeIDHeuristic("10.0.0.9"), fseid, testCreatePDR(), testCreateFAR()}, cause: ie.CauseNoEstablishedPFCPAssociation},
+		{name: "missing Create PDR", ies: []*ie.IE{nodeID, fseid, testCreateFAR()}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.CreatePDR},
+		{name: "missing Create FAR", ies: []*ie.IE{nodeID, fseid, testCreatePDR()}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.CreateFAR},
+		{name: "missing PDR ID", ies: []*ie.IE{nodeID, fseid, testCreatePDR(ie.NewPrecedence(1), pdi, ie.NewFARID(1)), testCreateFAR()}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.PDRID},
+		{name: "short PDR ID", ies: []*ie.IE{nodeID, fseid, testCreatePDR(ie.New(ie.PDRID, []byte{1}), ie.NewPrecedence(1), pdi, ie.NewFARID(1)), testCreateFAR()}, cause: ie.CauseInvalidLength, offendingIE: ie.PDRID},
+		{name: "missing PDI", ies: []*ie.IE{nodeID, fseid, testCreatePDR(ie.NewPDRID(1), ie.NewPrecedence(1), ie.NewFARID(1)), testCreateFAR()}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.PDI},
+		{name: "missing Source Interface", ies: []*ie.IE{nodeID, fseid, testCreatePDR(ie.NewPDRID(1), ie.NewPrecedence(1), ie.NewPDI(ie.NewNetworkInstance("internet")), ie.NewFARID(1)), testCreateFAR()}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.SourceInterface},
+		{name: "short F-TEID", ies: []*ie.IE{nodeID, fseid, testCreatePDR(ie.NewPDRID(1), ie.NewPrecedence(1), ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceAccess), ie.New(ie.FTEID, []byte{0x01, 0})), ie.NewFARID(1)), testCreateFAR()}, cause: ie.CauseInvalidLength, offendingIE: ie.FTEID},
+		{name: "missing Precedence", ies: []*ie.IE{nodeID, fseid, testCreatePDR(ie.NewPDRID(1), pdi, ie.NewFARID(1)), testCreateFAR()}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.Precedence},
+		{name: "missing FAR ID in PDR", ies: []*ie.IE{nodeID, fseid, testCreatePDR(ie.NewPDRID(1), ie.NewPrecedence(1), pdi), testCreateFAR()}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.FARID},
+		{name: "missing FAR ID", ies: []*ie.IE{nodeID, fseid, testCreatePDR(), testCreateFAR(ie.NewApplyAction(2))}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.FARID},
+		{name: "short FAR ID", ies: []*ie.IE{nodeID, fseid, testCreatePDR(), testCreateFAR(ie.New(ie.FARID, []byte{0, 0, 1}), ie.NewApplyAction(2))}, cause: ie.CauseInvalidLength, offendingIE: ie.FARID},
+		{name: "missing Apply Action", ies: []*ie.IE{nodeID, fseid, testCreatePDR(), testCreateFAR(ie.NewFARID(1))}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.ApplyAction},
+		{name: "F-TEID allocation not supported", ies: []*ie.IE{nodeID, fseid, testCreatePDR(ie.NewPDRID(1), ie.NewPrecedence(1), ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceAccess), ie.NewFTEID(0x05, 0, nil, nil, 0)), ie.NewFARID(1)), testCreateFAR()}, cause: ie.CauseRuleCreationModificationFailure, offendingIE: ie.FTEID},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cp, up := newTestFunctions(t)
+			res, ok := sendToUP(t, cp, message.NewSessionEstablishmentRequest(0, 0, 0, 0, 0, tc.ies...)).(*message.SessionEstablishmentResponse)
+			if !ok {
+				t.Fatal("unexpected Response type")
+			}
+			checkCause(t, res.Cause, res.OffendingIE, tc.cause, tc.offendingIE)
+			// SEID is 0 when the CP F-SEID is missing or malformed
+			var seid api.SEID = 1
+			if tc.offendingIE == ie.FSEID {
+				seid = 0
+			}
+			if res.SEID() != seid {
+				t.Errorf("got SEID %d, expected %d", res.SEID(), seid)
+			}
+			sessions := 0
+			if tc.cause == ie.CauseRequestAccepted {
+				sessions = 1
+			}
+			if n := len(up.Sessions()); n != sessions {
+				t.Errorf("got %d sessions, expected %d", n, sessions)
+			}
+		})
+	}
+}
+
+func TestSessionModificationRequestHandler(t *testing.T) {
+	pdi := ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceCore))
+	for _, tc := range []struct {
+		name        string
+		unknownSEID bool // send the Request to a session which does not exist
+		ies         []*ie.IE
+		cause       uint8
+		offendingIE uint16
+	}{
+		{name: "accepted", ies: []*ie.IE{testCreatePDR(ie.NewPDRID(2), ie.NewPrecedence(2), pdi, ie.NewFARID(2)), testCreateFAR(ie.NewFARID(2), ie.NewApplyAction(1))}, cause: ie.CauseRequestAccepted},
+		{name: "empty", cause: ie.CauseRequestAccepted},
+		{name: "unknown session", unknownSEID: true, cause: ie.CauseSessionContextNotFound},
+		{name: "missing PDR ID", ies: []*ie.IE{testCreatePDR(ie.NewPrecedence(2), pdi, ie.NewFARID(1))}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.PDRID},
+		{name: "short PDR ID", ies: []*ie.IE{testCreatePDR(ie.New(ie.PDRID, []byte{2}), ie.NewPrecedence(2), pdi, ie.NewFARID(1))}, cause: ie.CauseInvalidLength, offendingIE: ie.PDRID},
+		{name: "missing PDI", ies: []*ie.IE{testCreatePDR(ie.NewPDRID(2), ie.NewPrecedence(2), ie.NewFARID(1))}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.PDI},
+		{name: "missing Source Interface", ies: []*ie.IE{testCreatePDR(ie.NewPDRID(2), ie.NewPrecedence(2), ie.NewPDI(ie.NewNetworkInstance("internet")), ie.NewFARID(1))}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.SourceInterface},
+		{name: "missing Precedence", ies: []*ie.IE{testCreatePDR(ie.NewPDRID(2), pdi, ie.NewFARID(1))}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.Precedence},
+		{name: "missing FAR ID in PDR", ies: []*ie.IE{testCreatePDR(ie.NewPDRID(2), ie.NewPrecedence(2), pdi)}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.FARID},
+		{name: "missing FAR ID", ies: []*ie.IE{testCreateFAR(ie.NewApplyAction(1))}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.FARID},
+		{name: "missing Apply Action", ies: []*ie.IE{testCreateFAR(ie.NewFARID(2))}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.ApplyAction},
+		{name: "missing PDR ID in Update PDR", ies: []*ie.IE{ie.NewUpdatePDR(ie.NewPrecedence(2), pdi, ie.NewFARID(1))}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.PDRID},
+		{name: "missing Apply Action in Update FAR", ies: []*ie.IE{ie.NewUpdateFAR(ie.NewFARID(1))}, cause: ie.CauseMandatoryIEMissing, offendingIE: ie.ApplyAction},
+		{name: "F-TEID allocation not supported", ies: []*ie.IE{testCreatePDR(ie.NewPDRID(2), ie.NewPrecedence(2), ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceAccess), ie.NewFTEID(0x05, 0, nil, nil, 0)), ie.NewFARID(1))}, cause: ie.CauseRuleCreationModificationFailure, offendingIE: ie.FTEID},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cp, up := newTestFunctions(t)
+			session, _, err := cp.EstablishSession(testUPNodeID, testCreatePDR(), testCreateFAR())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session == nil {
+				t.Fatal("session has not been established")
+			}
+			seid := session.RemoteSEID
+			if tc.unknownSEID {
+				seid++
+			}
+			res, ok := sendToUP(t, cp, message.NewSessionModificationRequest(0, 0, seid, 0, 0, tc.ies...)).(*message.SessionModificationResponse)
+			if !ok {
+				t.Fatal("unexpected Response type")
+			}
+			checkCause(t, res.Cause, res.OffendingIE, tc.cause, tc.offendingIE)
+			// SEID is 0 when the session is unknown, else the SEID of the CP function
+			rseid := session.LocalSEID
+			if tc.unknownSEID {
+				rseid = 0
+			}
+			if res.SEID() != rseid {
+				t.Errorf("got SEID %d, expected %d", res.SEID(), rseid)
+			}
+			if tc.cause == ie.CauseRequestAccepted {
+				return
+			}
+			// rejected Requests leave the session unchanged
+			s, err := up.Datapath().Session(api.SEID(session.RemoteSEID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(s.PDRs) != 1 || len(s.FARs) != 1 {
+				t.Errorf("session has been modified: %d PDRs, %d FARs", len(s.PDRs), len(s.FARs))
+			}
+		})
+	}
+}
+
+func TestSessionDeletionRequestHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		unknownSEID     bool // send the Request to a session which does not exist
+		datapathFailure bool
+		cause           uint8
+	}{
+		{name: "accepted", cause: ie.CauseRequestAccepted},
+		{name: "unknown session", unknownSEID: true, cause: ie.CauseSessionContextNotFound},
+		{name: "datapath failure", datapathFailure: true, cause: ie.CauseRuleCreationModificationFailure},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cp, up := newTestFunctions(t)
+			session, _, err := cp.EstablishSession(testUPNodeID, testCreatePDR(), testCreateFAR())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session == nil {
+				t.Fatal("session has not been established")
+			}
+			seid := session.RemoteSEID
+			if tc.unknownSEID {
+				seid++
+			}
+			if tc.datapathFailure {
+				up.Datapath().Fail(pfcptest.DatapathRemoveSession, 1)
+			}
+			res, ok := sendToUP(t, cp, message.NewSessionDeletionRequest(0, 0, seid, 0, 0)).(*message.SessionDeletionResponse)
+			if !ok {
+				t.Fatal("unexpected Response type")
+			}
+			checkCause(t, res.Cause, res.OffendingIE, tc.cause, 0)
+			// SEID is 0 when the session is unknown, else the SEID of the CP function
+			rseid := session.LocalSEID
+			if tc.unknownSEID {
+				rseid = 0
+			}
+			if res.SEID() != rseid {
+				t.Errorf("got SEID %d, expected %d", res.SEID(), rseid)
+			}
+			sessions := 1
+			if tc.cause == ie.CauseRequestAccepted {
+				sessions = 0
+			}
+			if n := len(up.Sessions()); n != sessions {
+				t.Errorf("got %d sessions, expected %d", n, sessions)
+			}
+		})
+	}
+}
+
+// A Session Modification Request rejected by the datapath leaves the session unchanged
+func TestSessionModificationDatapathFailure(t *testing.T) {
+	cp, up := newTestFunctions(t)
+	session, _, err := cp.EstablishSession(testUPNodeID, testCreatePDR(), testCreateFAR())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session == nil {
+		t.Fatal("session has not been established")
+	}
+	ies := []*ie.IE{
+		testCreatePDR(ie.NewPDRID(2), ie.NewPrecedence(2), ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceCore)), ie.NewFARID(2)),
+		testCreateFAR(ie.NewFARID(2), ie.NewApplyAction(1)),
+		ie.NewUpdateFAR(ie.NewFARID(1), ie.NewApplyAction(1)),
+	}
+	up.Datapath().Fail(pfcptest.DatapathUpdateSession, 1)
+	res, err := session.Modify(ies...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCause(t, res.Cause, res.OffendingIE, ie.CauseRuleCreationModificationFailure, 0)
+
+	sessions := up.Sessions()
+	if len(sessions) != 1 {
+		t.Fatalf("got %d sessions, expected 1", len(sessions))
+	}
+	s := sessions[0]
+	s.RLock()
+	pdrs := s.GetSortedPDRIDs()
+	_, errFAR2 := s.GetFAR(2)
+	far1, errFAR1 := s.GetFAR(1)
+	s.RUnlock()
+	if len(pdrs) != 1 || errFAR2 == nil {
+		t.Errorf("created rules are kept: PDRs %v, FAR 2 exists: %t", pdrs, errFAR2 == nil)
+	}
+	if errFAR1 != nil {
+		t.Fatal(errFAR1)
+	}
+	if aa, err := far1.ApplyAction().ApplyAction(); err != nil || aa[0] != 2 {
+		t.Errorf("updated FAR is kept: Apply Action %v", aa)
+	}
+
+	// the same Request is accepted once the datapath works again
+	res, err = session.Modify(ies...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCause(t, res.Cause, res.OffendingIE, ie.CauseRequestAccepted, 0)
+}
+
+// Resources are released when PDRs no longer use them, and when the association is removed
+func TestSessionResourcesRelease(t *testing.T) {
+	// pools are too small for resources to leak during the test
+	teids, err := pfcp_networking.NewTEIDPool(net.ParseIP(testUPNodeID), nil, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ueips := make(map[string]*pfcp_networking.UEIPv4Pool)
+	for ni, prefix := range map[string]string{"a": "10.45.0.1/32", "b": "10.46.0.1/32"} {
+		_, p, _ := net.ParseCIDR(prefix)
+		if ueips[ni], err = pfcp_networking.NewUEIPv4Pool(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cp, up := newTestFunctions(t, func(up *pfcp_networking.PFCPEntityUP) error {
+		if err := up.AddTEIDPool("", ie.SrcInterfaceAccess, teids); err != nil {
+			return err
+		}
+		for ni, pool := range ueips {
+			if err := up.AddUEIPv4Pool(ni, pool); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	ulPDI := func() *ie.IE {
+		return ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceAccess), ie.NewFTEID(0x05, 0, nil, nil, 0))
+	}
+	dlPDI := func(ni string) *ie.IE {
+		return ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceCore), ie.NewNetworkInstance(ni), ie.NewUEIPAddress(api.UEIPFlagCHV4|api.UEIPFlagSD, "", "", 0, 0))
+	}
+	session, res, err := cp.EstablishSession(testUPNodeID,
+		testCreatePDR(ie.NewPDRID(1), ie.NewPrecedence(1), ulPDI(), ie.NewFARID(1)),
+		testCreatePDR(ie.NewPDRID(2), ie.NewPrecedence(1), dlPDI("a"), ie.NewFARID(1)),
+		testCreateFAR(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCause(t, res.Cause, res.OffendingIE, ie.CauseRequestAccepted, 0)
+
+	// replaced F-TEIDs and UE IP addresses are released
+	for _, ni := range []string{"b", "a", "b"} {
+		res, err := session.Modify(
+			ie.NewUpdatePDR(ie.NewPDRID(1), ie.NewPrecedence(1), ulPDI(), ie.NewFARID(1)),
+			ie.NewUpdatePDR(ie.NewPDRID(2), ie.NewPrecedence(1), dlPDI(ni), ie.NewFARID(1)),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkCause(t, res.Cause, res.OffendingIE, ie.CauseRequestAccepted, 0)
+	}
+
+	association, err := up.Entity().GetPFCPAssociation(testCPNodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := up.Entity().RemovePFCPAssociation(association); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(up.Sessions()); n != 0 {
+		t.Errorf("got %d sessions after the association has been removed, expected 0", n)
+	}
+	for teid := uint32(1); teid <= 2; teid++ {
+		if err := teids.Reserve(teid); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, pool := range ueips {
+		if _, err := pool.Allocate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import "sync"
+
+// idRange allocates integers of a range in a round-robin way,
+// so that a released integer is reused as late as possible.
+type idRange struct {
+	first uint32
+	last  uint32
+	next  uint32
+	used  map[uint32]struct{}
+	mu    sync.Mutex
+}
+
+func newIDRange(first uint32, last uint32) *idRange {
+	return &idRange{
+		first: first,
+		last:  last,
+		next:  first,
+		used:  make(map[uint32]struct{}),
+		mu:    sync.Mutex{},
+	}
+}
+
+// Returns false when every integer of the range is used
+func (r *idRange) allocate() (uint32, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if uint64(len(r.used)) > uint64(r.last-r.first) {
+		return 0, false
+	}
+	for {
+		id := r.next
+		if r.next == r.last {
+			r.next = r.first
+		} else {
+			r.next++
+		}
+		if _, exists := r.used[id]; !exists {
+			r.used[id] = struct{}{}
+			return id, true
+		}
+	}
+}
+
+// Returns false when the integer is not allocated
+func (r *idRange) release(id uint32) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.used[id]; !exists {
+		return false
+	}
+	delete(r.used, id)
+	return true
+}
+
+// Mark an integer as used; returns false when it is out of the range or already used
+func (r *idRange) reserve(id uint32) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id < r.first || id > r.last {
+		return false
+	}
+	if _, exists := r.used[id]; exists {
+		return false
+	}
+	r.used[id] = struct{}{}
+	return true
+}
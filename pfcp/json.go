@@ -0,0 +1,914 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/nextmn/go-pfcp-networking/sdf"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// JSON encoding of PDRs, FARs, sessions and associations.
+// The schema is stable: fields may be added, but are never renamed nor removed.
+// Optional fields are omitted when the IE is not present.
+// IEs which are not modelled are kept in "other_ies", as sent on the wire, in base64.
+//
+// PDR:
+//
+//	{
+//	  "pdr_id": 1,
+//	  "precedence": 255,
+//	  "pdi": {
+//	    "source_interface": "Access",         // Access, Core, SGi-LAN/N6-LAN, CP-function, 5G VN Internal
+//	    "local_fteid": {"v4": true, "v6": true, "ch": true, "chid": true,
+//	                    "teid": 1, "ipv4": "10.0.0.2", "ipv6": "fd00::2", "choose_id": 1},
+//	    "network_instance": "internet",
+//	    "ue_ip_address": {"v4": true, "v6": true, "sd": true, "ipv6d": true, "chv4": true, "chv6": true, "ip6pl": true,
+//	                      "ipv4": "10.45.0.1", "ipv6": "2001:db8::", "ipv6_prefix_delegation_bits": 8, "ipv6_prefix_length": 64},
+//	    "sdf_filters": [{"flow_description": "permit out ip from any to assigned",
+//	                     "tos_traffic_class": 0, "tos_traffic_class_mask": 255,
+//	                     "security_parameter_index": 1, "flow_label": 1, "sdf_filter_id": 1}],
+//	    "application_id": "app",
+//	    "ethernet_pdu_session_information": 1,
+//	    "qfis": [9],
+//	    "framed_routes": ["10.46.0.0/16"],
+//	    "framed_routing": 1,
+//	    "framed_ipv6_routes": ["2001:db8:1::/48"],
+//	    "other_ies": ["<IE>"]
+//	  },
+//	  "outer_header_removal": {"description": "GTP-U/UDP/IPv4", "pdu_session_container_deletion": true},
+//	  "far_id": 1
+//	}
+//
+// Outer Header Removal descriptions are GTP-U/UDP/IPv4, GTP-U/UDP/IPv6, UDP/IPv4, UDP/IPv6,
+// IPv4, IPv6, GTP-U/UDP/IP, VLAN S-TAG, and S-TAG and C-TAG.
+// Flags of F-TEID and UE IP Address are set when the corresponding address is present.
+//
+// FAR:
+//
+//	{
+//	  "far_id": 1,
+//	  "apply_action": ["FORW"],               // DROP, FORW, BUFF, NOCP, DUPL, IPMA, IPMD, DFRT, EDRT, BDPN, DDPN, FSSM, MBSU
+//	  "forwarding_parameters": {
+//	    "destination_interface": "Access",    // Access, Core, SGi-LAN/N6-LAN, CP-function, LI-function, 5G VN Internal
+//	    "network_instance": "internet",
+//	    "outer_header_creation": {"description": ["GTP-U/UDP/IPv4"],
+//	                              "teid": 1, "ipv4": "10.0.0.3", "ipv6": "fd00::3", "port": 2152, "c_tag": 1, "s_tag": 1},
+//	    "other_ies": ["<IE>"]
+//	  }
+//	}
+//
+// Outer Header Creation descriptions are GTP-U/UDP/IPv4, GTP-U/UDP/IPv6, UDP/IPv4, UDP/IPv6,
+// IPv4, IPv6, C-TAG, S-TAG, N19, N6, and LLSSM-C-TEID.
+//
+// PFCPSession:
+//
+//	{
+//	  "local_fseid": {"seid": 1, "ipv4": "10.0.0.2", "ipv6": "fd00::2"},
+//	  "remote_fseid": {"seid": 1, "ipv4": "10.0.0.1"},   // omitted when unknown
+//	  "pdrs": [<PDR>, ...],                               // sorted by precedence
+//	  "fars": [<FAR>, ...]                                // sorted by FAR ID
+//	}
+//
+// PFCPAssociation:
+//
+//	{"node_id": "10.0.0.1", "is_setup": true, "next_seid": 2}
+//
+// Sessions and associations decoded from JSON are detached:
+// they can be inspected, but they are not bound to an entity and cannot send messages.
+
+var sourceInterfaceNames = map[uint8]string{
+	ie.SrcInterfaceAccess:       "Access",
+	ie.SrcInterfaceCore:         "Core",
+	ie.SrcInterfaceSGiLANN6LAN:  "SGi-LAN/N6-LAN",
+	ie.SrcInterfaceCPFunction:   "CP-function",
+	ie.SrcInterface5GVNInternal: "5G VN Internal",
+}
+
+var destinationInterfaceNames = map[uint8]string{
+	ie.DstInterfaceAccess:       "Access",
+	ie.DstInterfaceCore:         "Core",
+	ie.DstInterfaceSGiLANN6LAN:  "SGi-LAN/N6-LAN",
+	ie.DstInterfaceCPFunction:   "CP-function",
+	ie.DstInterfaceLIFunction:   "LI-function",
+	ie.DstInterface5GVNInternal: "5G VN Internal",
+}
+
+var outerHeaderRemovalNames = map[uint8]string{
+	0: "GTP-U/UDP/IPv4",
+	1: "GTP-U/UDP/IPv6",
+	2: "UDP/IPv4",
+	3: "UDP/IPv6",
+	4: "IPv4",
+	5: "IPv6",
+	6: "GTP-U/UDP/IP",
+	7: "VLAN S-TAG",
+	8: "S-TAG and C-TAG",
+}
+
+type flagName struct {
+	mask uint16
+	name string
+}
+
+// Flags of the Apply Action IE: first octet is the low byte
+var applyActionFlags = []flagName{
+	{0x0001, "DROP"}, {0x0002, "FORW"}, {0x0004, "BUFF"}, {0x0008, "NOCP"},
+	{0x0010, "DUPL"}, {0x0020, "IPMA"}, {0x0040, "IPMD"}, {0x0080, "DFRT"},
+	{0x0100, "EDRT"}, {0x0200, "BDPN"}, {0x0400, "DDPN"}, {0x0800, "FSSM"},
+	{0x1000, "MBSU"},
+}
+
+// Outer Header Creation Description: first octet is the high byte
+var outerHeaderCreationFlags = []flagName{
+	{0x0100, "GTP-U/UDP/IPv4"}, {0x0200, "GTP-U/UDP/IPv6"}, {0x0400, "UDP/IPv4"}, {0x0800, "UDP/IPv6"},
+	{0x1000, "IPv4"}, {0x2000, "IPv6"}, {0x4000, "C-TAG"}, {0x8000, "S-TAG"},
+	{0x0001, "N19"}, {0x0002, "N6"}, {0x0004, "LLSSM-C-TEID"},
+}
+
+// Returns the name of a value; unknown values are written in decimal
+func nameOf(names map[uint8]string, v uint8) string {
+	if name, exists := names[v]; exists {
+		return name
+	}
+	return strconv.Itoa(int(v))
+}
+
+func valueOf(names map[uint8]string, name string) (uint8, error) {
+	for v, n := range names {
+		if n == name {
+			return v, nil
+		}
+	}
+	v, err := strconv.ParseUint(name, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("Unknown value %q", name)
+	}
+	return uint8(v), nil
+}
+
+func flagNames(flags []flagName, v uint16) []string {
+	names := make([]string, 0)
+	for _, f := range flags {
+		if v&f.mask != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+func flagValue(flags []flagName, names []string) (uint16, error) {
+	var v uint16
+	for _, name := range names {
+		found := false
+		for _, f := range flags {
+			if f.name == name {
+				v |= f.mask
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("Unknown flag %q", name)
+		}
+	}
+	return v, nil
+}
+
+func marshalIEs(ies []*ie.IE) ([][]byte, error) {
+	if len(ies) == 0 {
+		return nil, nil
+	}
+	bs := make([][]byte, 0, len(ies))
+	for _, i := range ies {
+		b, err := i.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		bs = append(bs, b)
+	}
+	return bs, nil
+}
+
+type fteidJSON struct {
+	V4       bool   `json:"v4,omitempty"`
+	V6       bool   `json:"v6,omitempty"`
+	CH       bool   `json:"ch,omitempty"`
+	CHID     bool   `json:"chid,omitempty"`
+	TEID     uint32 `json:"teid,omitempty"`
+	IPv4     net.IP `json:"ipv4,omitempty"`
+	IPv6     net.IP `json:"ipv6,omitempty"`
+	ChooseID uint8  `json:"choose_id,omitempty"`
+}
+
+func newFTEIDJSON(f *ie.FTEIDFields) *fteidJSON {
+	j := fteidJSON{
+		V4:   f.HasIPv4(),
+		V6:   f.HasIPv6(),
+		CH:   f.HasCh(),
+		CHID: f.HasChID(),
+	}
+	if !j.CH {
+		j.TEID = f.TEID
+		if j.V4 {
+			j.IPv4 = f.IPv4Address
+		}
+		if j.V6 {
+			j.IPv6 = f.IPv6Address
+		}
+	}
+	if j.CHID {
+		j.ChooseID = f.ChooseID
+	}
+	return &j
+}
+
+func (j *fteidJSON) fields() *ie.FTEIDFields {
+	f := ie.NewFTEIDFields(0, j.TEID, nil, nil, j.ChooseID)
+	if j.V4 || j.IPv4 != nil {
+		f.SetIPv4Flag()
+		f.IPv4Address = j.IPv4.To4()
+	}
+	if j.V6 || j.IPv6 != nil {
+		f.SetIPv6Flag()
+		f.IPv6Address = j.IPv6.To16()
+	}
+	if j.CH {
+		f.SetChFlag()
+	}
+	if j.CHID {
+		f.SetChIDFlag()
+	}
+	return f
+}
+
+type ueIPAddressJSON struct {
+	V4                       bool   `json:"v4,omitempty"`
+	V6                       bool   `json:"v6,omitempty"`
+	SD                       bool   `json:"sd,omitempty"`
+	IPv6D                    bool   `json:"ipv6d,omitempty"`
+	CHV4                     bool   `json:"chv4,omitempty"`
+	CHV6                     bool   `json:"chv6,omitempty"`
+	IP6PL                    bool   `json:"ip6pl,omitempty"`
+	IPv4                     net.IP `json:"ipv4,omitempty"`
+	IPv6                     net.IP `json:"ipv6,omitempty"`
+	IPv6PrefixDelegationBits uint8  `json:"ipv6_prefix_delegation_bits,omitempty"`
+	IPv6PrefixLength         uint8  `json:"ipv6_prefix_length,omitempty"`
+}
+
+func newUEIPAddressJSON(f *ie.UEIPAddressFields) *ueIPAddressJSON {
+	j := ueIPAddressJSON{
+		V4:    f.Flags&api.UEIPFlagV4 != 0,
+		V6:    f.Flags&api.UEIPFlagV6 != 0,
+		SD:    f.Flags&api.UEIPFlagSD != 0,
+		IPv6D: f.Flags&api.UEIPFlagIPv6D != 0,
+		CHV4:  f.Flags&api.UEIPFlagCHV4 != 0,
+		CHV6:  f.Flags&api.UEIPFlagCHV6 != 0,
+		IP6PL: f.Flags&api.UEIPFlagIP6PL != 0,
+	}
+	if j.V4 && !j.CHV4 {
+		j.IPv4 = f.IPv4Address
+	}
+	if j.V6 && !j.CHV6 {
+		j.IPv6 = f.IPv6Address
+	}
+	if j.IPv6D {
+		j.IPv6PrefixDelegationBits = f.IPv6PrefixDelegationBits
+	}
+	if j.IP6PL {
+		j.IPv6PrefixLength = f.IPv6PrefixLength
+	}
+	return &j
+}
+
+func (j *ueIPAddressJSON) fields() *ie.UEIPAddressFields {
+	f := ie.UEIPAddressFields{
+		IPv4Address:              j.IPv4.To4(),
+		IPv6Address:              j.IPv6.To16(),
+		IPv6PrefixDelegationBits: j.IPv6PrefixDelegationBits,
+		IPv6PrefixLength:         j.IPv6PrefixLength,
+	}
+	flags := []struct {
+		set  bool
+		flag uint8
+	}{
+		{j.V4 || j.IPv4 != nil, api.UEIPFlagV4},
+		{j.V6 || j.IPv6 != nil, api.UEIPFlagV6},
+		{j.SD, api.UEIPFlagSD},
+		{j.IPv6D || j.IPv6PrefixDelegationBits != 0, api.UEIPFlagIPv6D},
+		{j.CHV4, api.UEIPFlagCHV4},
+		{j.CHV6, api.UEIPFlagCHV6},
+		{j.IP6PL || j.IPv6PrefixLength != 0, api.UEIPFlagIP6PL},
+	}
+	for _, fl := range flags {
+		if fl.set {
+			f.Flags |= fl.flag
+		}
+	}
+	return &f
+}
+
+type sdfFilterJSON struct {
+	FlowDescription        string  `json:"flow_description,omitempty"`
+	ToSTrafficClass        *uint8  `json:"tos_traffic_class,omitempty"`
+	ToSTrafficClassMask    *uint8  `json:"tos_traffic_class_mask,omitempty"`
+	SecurityParameterIndex *uint32 `json:"security_parameter_index,omitempty"`
+	FlowLabel              *uint32 `json:"flow_label,omitempty"`
+	FilterID               *uint32 `json:"sdf_filter_id,omitempty"`
+}
+
+func newSDFFilterJSON(f *sdf.Filter) sdfFilterJSON {
+	j := sdfFilterJSON{}
+	if f.FlowDescription != nil {
+		j.FlowDescription = f.FlowDescription.String()
+	}
+	if f.HasToSTrafficClass {
+		tos, mask := f.ToSTrafficClass, f.ToSTrafficClassMask
+		j.ToSTrafficClass, j.ToSTrafficClassMask = &tos, &mask
+	}
+	if f.HasSecurityParameterIndex {
+		spi := f.SecurityParameterIndex
+		j.SecurityParameterIndex = &spi
+	}
+	if f.HasFlowLabel {
+		fl := f.FlowLabel
+		j.FlowLabel = &fl
+	}
+	if f.HasFilterID {
+		id := f.FilterID
+		j.FilterID = &id
+	}
+	return j
+}
+
+func (j *sdfFilterJSON) filter() (*sdf.Filter, error) {
+	f := sdf.Filter{}
+	if j.FlowDescription != "" {
+		fd, err := sdf.ParseFlowDescription(j.FlowDescription)
+		if err != nil {
+			return nil, err
+		}
+		f.FlowDescription = fd
+	}
+	if j.ToSTrafficClass != nil {
+		f.HasToSTrafficClass = true
+		f.ToSTrafficClass = *j.ToSTrafficClass
+		f.ToSTrafficClassMask = 0xff
+		if j.ToSTrafficClassMask != nil {
+			f.ToSTrafficClassMask = *j.ToSTrafficClassMask
+		}
+	}
+	if j.SecurityParameterIndex != nil {
+		f.HasSecurityParameterIndex = true
+		f.SecurityParameterIndex = *j.SecurityParameterIndex
+	}
+	if j.FlowLabel != nil {
+		f.HasFlowLabel = true
+		f.FlowLabel = *j.FlowLabel
+	}
+	if j.FilterID != nil {
+		f.HasFilterID = true
+		f.FilterID = *j.FilterID
+	}
+	return &f, nil
+}
+
+type pdiJSON struct {
+	SourceInterface               string           `json:"source_interface"`
+	LocalFTEID                    *fteidJSON       `json:"local_fteid,omitempty"`
+	NetworkInstance               string           `json:"network_instance,omitempty"`
+	UEIPAddress                   *ueIPAddressJSON `json:"ue_ip_address,omitempty"`
+	SDFFilters                    []sdfFilterJSON  `json:"sdf_filters,omitempty"`
+	ApplicationID                 string           `json:"application_id,omitempty"`
+	EthernetPDUSessionInformation *uint8           `json:"ethernet_pdu_session_information,omitempty"`
+	QFIs                          []int            `json:"qfis,omitempty"`
+	FramedRoutes                  []string         `json:"framed_routes,omitempty"`
+	FramedRouting                 *uint32          `json:"framed_routing,omitempty"`
+	FramedIPv6Routes              []string         `json:"framed_ipv6_routes,omitempty"`
+	OtherIEs                      [][]byte         `json:"other_ies,omitempty"`
+}
+
+func newPDIJSON(p *api.PDIFields) (*pdiJSON, error) {
+	j := pdiJSON{
+		SourceInterface:  nameOf(sourceInterfaceNames, p.SourceInterface),
+		NetworkInstance:  p.NetworkInstance,
+		ApplicationID:    p.ApplicationID,
+		FramedRoutes:     p.FramedRoutes,
+		FramedIPv6Routes: p.FramedIPv6Routes,
+	}
+	if p.LocalFTEID != nil {
+		j.LocalFTEID = newFTEIDJSON(p.LocalFTEID)
+	}
+	if p.UEIPAddress != nil {
+		j.UEIPAddress = newUEIPAddressJSON(p.UEIPAddress)
+	}
+	for _, f := range p.SDFFilters {
+		j.SDFFilters = append(j.SDFFilters, newSDFFilterJSON(f))
+	}
+	if p.HasEthernetPDUSessionInformation {
+		info := p.EthernetPDUSessionInformation
+		j.EthernetPDUSessionInformation = &info
+	}
+	for _, qfi := range p.QFIs {
+		j.QFIs = append(j.QFIs, int(qfi))
+	}
+	if p.HasFramedRouting {
+		routing := p.FramedRouting
+		j.FramedRouting = &routing
+	}
+	var err error
+	if j.OtherIEs, err = marshalIEs(p.Others); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (j *pdiJSON) fields() (*api.PDIFields, error) {
+	if j.SourceInterface == "" {
+		return nil, fmt.Errorf("Source Interface is missing in PDI")
+	}
+	si, err := valueOf(sourceInterfaceNames, j.SourceInterface)
+	if err != nil {
+		return nil, fmt.Errorf("Wrong Source Interface: %w", err)
+	}
+	p := api.PDIFields{
+		SourceInterface:  si,
+		NetworkInstance:  j.NetworkInstance,
+		ApplicationID:    j.ApplicationID,
+		FramedRoutes:     j.FramedRoutes,
+		FramedIPv6Routes: j.FramedIPv6Routes,
+	}
+	if j.LocalFTEID != nil {
+		p.LocalFTEID = j.LocalFTEID.fields()
+	}
+	if j.UEIPAddress != nil {
+		p.UEIPAddress = j.UEIPAddress.fields()
+	}
+	for _, fj := range j.SDFFilters {
+		f, err := fj.filter()
+		if err != nil {
+			return nil, err
+		}
+		p.SDFFilters = append(p.SDFFilters, f)
+	}
+	if j.EthernetPDUSessionInformation != nil {
+		p.HasEthernetPDUSessionInformation = true
+		p.EthernetPDUSessionInformation = *j.EthernetPDUSessionInformation
+	}
+	for _, qfi := range j.QFIs {
+		if qfi < 0 || qfi > 0x3f {
+			return nil, fmt.Errorf("Wrong QFI %d", qfi)
+		}
+		p.QFIs = append(p.QFIs, uint8(qfi))
+	}
+	if j.FramedRouting != nil {
+		p.HasFramedRouting = true
+		p.FramedRouting = *j.FramedRouting
+	}
+	if p.Others, err = parseIEs(j.OtherIEs); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+type outerHeaderRemovalJSON struct {
+	Description                 string `json:"description"`
+	PDUSessionContainerDeletion bool   `json:"pdu_session_container_deletion,omitempty"`
+}
+
+func newOuterHeaderRemovalJSON(i *ie.IE) (*outerHeaderRemovalJSON, error) {
+	desc, err := i.OuterHeaderRemovalDescription()
+	if err != nil {
+		return nil, err
+	}
+	j := outerHeaderRemovalJSON{Description: nameOf(outerHeaderRemovalNames, desc)}
+	if ext, err := i.GTPUExtensionHeaderDeletion(); err == nil {
+		j.PDUSessionContainerDeletion = ext&0x01 != 0
+	}
+	return &j, nil
+}
+
+func (j *outerHeaderRemovalJSON) ie() (*ie.IE, error) {
+	desc, err := valueOf(outerHeaderRemovalNames, j.Description)
+	if err != nil {
+		return nil, fmt.Errorf("Wrong Outer Header Removal: %w", err)
+	}
+	var ext uint8
+	if j.PDUSessionContainerDeletion {
+		ext = 0x01
+	}
+	return ie.NewOuterHeaderRemoval(desc, ext), nil
+}
+
+type pdrJSON struct {
+	ID                 *api.PDRID              `json:"pdr_id"`
+	Precedence         *uint32                 `json:"precedence"`
+	PDI                *pdiJSON                `json:"pdi"`
+	OuterHeaderRemoval *outerHeaderRemovalJSON `json:"outer_header_removal,omitempty"`
+	FARID              *api.FARID              `json:"far_id,omitempty"`
+}
+
+func (pdr *PDR) MarshalJSON() ([]byte, error) {
+	id, err := pdr.ID()
+	if err != nil {
+		return nil, err
+	}
+	precedence, err := pdr.Precedence()
+	if err != nil {
+		return nil, err
+	}
+	fields, err := pdr.PDIFields()
+	if err != nil {
+		return nil, err
+	}
+	j := pdrJSON{ID: &id, Precedence: &precedence}
+	if j.PDI, err = newPDIJSON(fields); err != nil {
+		return nil, err
+	}
+	if pdr.outerHeaderRemoval != nil {
+		if j.OuterHeaderRemoval, err = newOuterHeaderRemovalJSON(pdr.outerHeaderRemoval); err != nil {
+			return nil, err
+		}
+	}
+	if pdr.farid != nil {
+		farid, err := pdr.FARID()
+		if err != nil {
+			return nil, err
+		}
+		j.FARID = &farid
+	}
+	return json.Marshal(&j)
+}
+
+func (pdr *PDR) UnmarshalJSON(b []byte) error {
+	var j pdrJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	switch {
+	case j.ID == nil:
+		return fmt.Errorf("PDR ID is missing")
+	case j.Precedence == nil:
+		return fmt.Errorf("Precedence is missing in PDR %d", *j.ID)
+	case j.PDI == nil:
+		return fmt.Errorf("PDI is missing in PDR %d", *j.ID)
+	}
+	pdi, err := j.PDI.fields()
+	if err != nil {
+		return fmt.Errorf("PDR %d: %w", *j.ID, err)
+	}
+	p := NewPDR(ie.NewPDRID(*j.ID), pdi.IE(), ie.NewPrecedence(*j.Precedence), nil, nil)
+	if j.OuterHeaderRemoval != nil {
+		if p.outerHeaderRemoval, err = j.OuterHeaderRemoval.ie(); err != nil {
+			return fmt.Errorf("PDR %d: %w", *j.ID, err)
+		}
+	}
+	if j.FARID != nil {
+		p.farid = ie.NewFARID(*j.FARID)
+	}
+	*pdr = *p
+	return nil
+}
+
+type outerHeaderCreationJSON struct {
+	Description []string `json:"description"`
+	TEID        uint32   `json:"teid,omitempty"`
+	IPv4        net.IP   `json:"ipv4,omitempty"`
+	IPv6        net.IP   `json:"ipv6,omitempty"`
+	Port        uint16   `json:"port,omitempty"`
+	CTag        uint32   `json:"c_tag,omitempty"`
+	STag        uint32   `json:"s_tag,omitempty"`
+}
+
+func newOuterHeaderCreationJSON(f *ie.OuterHeaderCreationFields) *outerHeaderCreationJSON {
+	return &outerHeaderCreationJSON{
+		Description: flagNames(outerHeaderCreationFlags, f.OuterHeaderCreationDescription),
+		TEID:        f.TEID,
+		IPv4:        f.IPv4Address,
+		IPv6:        f.IPv6Address,
+		Port:        f.PortNumber,
+		CTag:        f.CTag,
+		STag:        f.STag,
+	}
+}
+
+func (j *outerHeaderCreationJSON) ie() (*ie.IE, error) {
+	desc, err := flagValue(outerHeaderCreationFlags, j.Description)
+	if err != nil {
+		return nil, fmt.Errorf("Wrong Outer Header Creation: %w", err)
+	}
+	f := ie.OuterHeaderCreationFields{
+		OuterHeaderCreationDescription: desc,
+		TEID:                           j.TEID,
+		IPv4Address:                    j.IPv4.To4(),
+		IPv6Address:                    j.IPv6.To16(),
+		PortNumber:                     j.Port,
+		CTag:                           j.CTag,
+		STag:                           j.STag,
+	}
+	b, err := f.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return ie.New(ie.OuterHeaderCreation, b), nil
+}
+
+type forwardingParametersJSON struct {
+	DestinationInterface string                   `json:"destination_interface,omitempty"`
+	NetworkInstance      string                   `json:"network_instance,omitempty"`
+	OuterHeaderCreation  *outerHeaderCreationJSON `json:"outer_header_creation,omitempty"`
+	OtherIEs             [][]byte                 `json:"other_ies,omitempty"`
+}
+
+func newForwardingParametersJSON(i *ie.IE) (*forwardingParametersJSON, error) {
+	ies, err := i.ForwardingParameters()
+	if err != nil {
+		return nil, err
+	}
+	j := forwardingParametersJSON{}
+	others := make([]*ie.IE, 0)
+	for _, x := range ies {
+		switch x.Type {
+		case ie.DestinationInterface:
+			dst, err := x.DestinationInterface()
+			if err != nil {
+				return nil, err
+			}
+			j.DestinationInterface = nameOf(destinationInterfaceNames, dst)
+		case ie.NetworkInstance:
+			if j.NetworkInstance, err = x.NetworkInstance(); err != nil {
+				return nil, err
+			}
+		case ie.OuterHeaderCreation:
+			ohc, err := x.OuterHeaderCreation()
+			if err != nil {
+				return nil, err
+			}
+			j.OuterHeaderCreation = newOuterHeaderCreationJSON(ohc)
+		default:
+			others = append(others, x)
+		}
+	}
+	if j.OtherIEs, err = marshalIEs(others); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (j *forwardingParametersJSON) ie() (*ie.IE, error) {
+	ies := make([]*ie.IE, 0)
+	if j.DestinationInterface != "" {
+		dst, err := valueOf(destinationInterfaceNames, j.DestinationInterface)
+		if err != nil {
+			return nil, fmt.Errorf("Wrong Destination Interface: %w", err)
+		}
+		ies = append(ies, ie.NewDestinationInterface(dst))
+	}
+	if j.NetworkInstance != "" {
+		ies = append(ies, ie.NewNetworkInstance(j.NetworkInstance))
+	}
+	if j.OuterHeaderCreation != nil {
+		ohc, err := j.OuterHeaderCreation.ie()
+		if err != nil {
+			return nil, err
+		}
+		ies = append(ies, ohc)
+	}
+	others, err := parseIEs(j.OtherIEs)
+	if err != nil {
+		return nil, err
+	}
+	ies = append(ies, others...)
+	return ie.NewForwardingParameters(ies...), nil
+}
+
+type farJSON struct {
+	ID                   *api.FARID                `json:"far_id"`
+	ApplyAction          []string                  `json:"apply_action"`
+	ForwardingParameters *forwardingParametersJSON `json:"forwarding_parameters,omitempty"`
+}
+
+func (far *FAR) MarshalJSON() ([]byte, error) {
+	id, err := far.ID()
+	if err != nil {
+		return nil, err
+	}
+	j := farJSON{ID: &id, ApplyAction: make([]string, 0)}
+	if far.applyAction != nil {
+		aa, err := far.applyAction.ApplyAction()
+		if err != nil {
+			return nil, err
+		}
+		v := uint16(aa[0])
+		if len(aa) > 1 {
+			v |= uint16(aa[1]) << 8
+		}
+		j.ApplyAction = flagNames(applyActionFlags, v)
+	}
+	if far.forwardingParameters != nil {
+		if j.ForwardingParameters, err = newForwardingParametersJSON(far.forwardingParameters); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(&j)
+}
+
+func (far *FAR) UnmarshalJSON(b []byte) error {
+	var j farJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	if j.ID == nil {
+		return fmt.Errorf("FAR ID is missing")
+	}
+	aa, err := flagValue(applyActionFlags, j.ApplyAction)
+	if err != nil {
+		return fmt.Errorf("FAR %d: Wrong Apply Action: %w", *j.ID, err)
+	}
+	applyAction := ie.NewApplyAction(uint8(aa))
+	if aa > 0xff {
+		applyAction = ie.NewApplyAction(uint8(aa), uint8(aa>>8))
+	}
+	var fp *ie.IE
+	if j.ForwardingParameters != nil {
+		if fp, err = j.ForwardingParameters.ie(); err != nil {
+			return fmt.Errorf("FAR %d: %w", *j.ID, err)
+		}
+	}
+	*far = *NewFAR(ie.NewFARID(*j.ID), applyAction, fp)
+	return nil
+}
+
+type fseidJSON struct {
+	SEID api.SEID `json:"seid"`
+	IPv4 net.IP   `json:"ipv4,omitempty"`
+	IPv6 net.IP   `json:"ipv6,omitempty"`
+}
+
+func newFSEIDJSON(i *ie.IE) (*fseidJSON, error) {
+	if i == nil {
+		return nil, nil
+	}
+	f, err := i.FSEID()
+	if err != nil {
+		return nil, err
+	}
+	j := fseidJSON{SEID: f.SEID}
+	if f.HasIPv4() {
+		j.IPv4 = f.IPv4Address
+	}
+	if f.HasIPv6() {
+		j.IPv6 = f.IPv6Address
+	}
+	return &j, nil
+}
+
+func (j *fseidJSON) ie() *ie.IE {
+	if j == nil {
+		return nil
+	}
+	return ie.NewFSEID(j.SEID, j.IPv4.To4(), j.IPv6.To16())
+}
+
+type sessionJSON struct {
+	LocalFSEID  *fseidJSON        `json:"local_fseid"`
+	RemoteFSEID *fseidJSON        `json:"remote_fseid,omitempty"`
+	PDRs        []json.RawMessage `json:"pdrs"`
+	FARs        []json.RawMessage `json:"fars"`
+}
+
+func (s *PFCPSession) MarshalJSON() ([]byte, error) {
+	s.RLock()
+	defer s.RUnlock()
+	j := sessionJSON{
+		PDRs: make([]json.RawMessage, 0),
+		FARs: make([]json.RawMessage, 0),
+	}
+	var err error
+	if j.LocalFSEID, err = newFSEIDJSON(s.localFseid); err != nil {
+		return nil, err
+	}
+	if j.RemoteFSEID, err = newFSEIDJSON(s.remoteFseid); err != nil {
+		return nil, err
+	}
+	for _, id := range s.GetSortedPDRIDs() {
+		pdr, err := s.GetPDR(id)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(pdr)
+		if err != nil {
+			return nil, err
+		}
+		j.PDRs = append(j.PDRs, b)
+	}
+	fars := make([]api.FARInterface, 0)
+	s.ForeachUnsortedFAR(func(far api.FARInterface) error {
+		fars = append(fars, far)
+		return nil
+	})
+	sort.Slice(fars, func(i, j int) bool {
+		a, _ := fars[i].ID()
+		b, _ := fars[j].ID()
+		return a < b
+	})
+	for _, far := range fars {
+		b, err := json.Marshal(far)
+		if err != nil {
+			return nil, err
+		}
+		j.FARs = append(j.FARs, b)
+	}
+	return json.Marshal(&j)
+}
+
+// The decoded session is detached: it is not bound to an association
+func (s *PFCPSession) UnmarshalJSON(b []byte) error {
+	var j sessionJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	if j.LocalFSEID == nil {
+		return fmt.Errorf("Local F-SEID is missing")
+	}
+	pdrIEs := make([]*ie.IE, 0, len(j.PDRs))
+	for _, raw := range j.PDRs {
+		var pdr PDR
+		if err := json.Unmarshal(raw, &pdr); err != nil {
+			return err
+		}
+		pdrIEs = append(pdrIEs, pdr.NewCreatePDR())
+	}
+	farIEs := make([]*ie.IE, 0, len(j.FARs))
+	for _, raw := range j.FARs {
+		var far FAR
+		if err := json.Unmarshal(raw, &far); err != nil {
+			return err
+		}
+		farIEs = append(farIEs, far.NewCreateFAR())
+	}
+	pdrs, err := NewPDRMap(pdrIEs)
+	if err != nil {
+		return err
+	}
+	fars, err := NewFARMap(farIEs)
+	if err != nil {
+		return err
+	}
+	*s = PFCPSession{
+		isEstablished: false,
+		association:   nil,
+		localFseid:    j.LocalFSEID.ie(),
+		remoteFseid:   j.RemoteFSEID.ie(),
+		pdr:           pdrs,
+		far:           fars,
+		atomicMu:      sync.RWMutex{},
+	}
+	return nil
+}
+
+type associationJSON struct {
+	NodeID   string   `json:"node_id"`
+	IsSetup  bool     `json:"is_setup"`
+	NextSEID api.SEID `json:"next_seid"`
+}
+
+func (association *PFCPAssociation) MarshalJSON() ([]byte, error) {
+	nid, err := association.NodeID().NodeID()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&associationJSON{
+		NodeID:   nid,
+		IsSetup:  association.isSetup,
+		NextSEID: association.sessionIDPool.peekNext(),
+	})
+}
+
+// The decoded association is detached: its peer is closed, and is not bound to an entity
+func (association *PFCPAssociation) UnmarshalJSON(b []byte) error {
+	var j associationJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	if j.NodeID == "" {
+		return fmt.Errorf("Node ID is missing")
+	}
+	*association = PFCPAssociation{
+		PFCPPeerInterface: newDetachedPFCPPeer(nil, ie.NewNodeIDHeuristic(j.NodeID), ""),
+		isSetup:           j.IsSetup,
+		sessionIDPool:     NewSessionIDPool(),
+	}
+	association.sessionIDPool.setNext(j.NextSEID)
+	return nil
+}
@@ -0,0 +1,285 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// Fails when both IEs are not encoded the same way
+func checkIE(t *testing.T, name string, got, expected *ie.IE) {
+	t.Helper()
+	if got == nil || expected == nil {
+		if got != expected {
+			t.Errorf("%s: got %v, expected %v", name, got, expected)
+		}
+		return
+	}
+	g, err := got.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := expected.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(g, e) {
+		t.Errorf("%s: got %x, expected %x", name, g, e)
+	}
+}
+
+// PDI IEs are listed in the order used by api.PDIFields.IE
+func TestPDRJSONRoundTrip(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name string
+		pdr  *PDR
+	}{
+		{name: "minimal", pdr: NewPDR(ie.NewPDRID(1), ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceAccess)), ie.NewPrecedence(255), nil, nil)},
+		{name: "CH F-TEID and UE IP address", pdr: NewPDR(ie.NewPDRID(2), ie.NewPDI(
+			ie.NewSourceInterface(ie.SrcInterfaceAccess),
+			ie.NewFTEID(0x0d, 0, nil, nil, 5),
+			ie.NewNetworkInstance("internet"),
+			ie.NewUEIPAddress(api.UEIPFlagV4|api.UEIPFlagSD, "10.45.0.1", "", 0, 0),
+			ie.NewQFI(9),
+		), ie.NewPrecedence(1), ie.NewFARID(1), ie.NewOuterHeaderRemoval(0, 0))},
+		{name: "IPv4 and IPv6 F-TEID", pdr: NewPDR(ie.NewPDRID(3), ie.NewPDI(
+			ie.NewSourceInterface(ie.SrcInterfaceAccess),
+			ie.NewFTEID(0x03, 0x1234, net.ParseIP("10.0.0.2"), net.ParseIP("fd00::2"), 0),
+			ie.NewUEIPAddress(api.UEIPFlagV6|api.UEIPFlagIP6PL, "", "2001:db8::", 0, 64),
+		), ie.NewPrecedence(2), ie.NewFARID(2), ie.NewOuterHeaderRemoval(1, 1))},
+		{name: "SDF filters", pdr: NewPDR(ie.NewPDRID(4), ie.NewPDI(
+			ie.NewSourceInterface(ie.SrcInterfaceCore),
+			ie.NewNetworkInstance("internet"),
+			ie.NewUEIPAddress(api.UEIPFlagV4, "10.45.0.1", "", 0, 0),
+			ie.NewSDFFilter("permit out ip from 10.0.0.0/8 to assigned", "", "", "", 0),
+			ie.NewSDFFilter("permit out 17 from any 53 to assigned", "\x10\xfc", "\x00\x00\x00\x01", "\x00\x00\x02", 3),
+			ie.NewApplicationID("app"),
+			ie.NewQFI(1),
+			ie.NewQFI(2),
+		), ie.NewPrecedence(3), ie.NewFARID(3), nil)},
+		{name: "Outer Header Removal GTP-U/UDP/IP", pdr: NewPDR(ie.NewPDRID(5), ie.NewPDI(
+			ie.NewSourceInterface(ie.SrcInterfaceAccess),
+			ie.NewFTEID(0x01, 1, net.ParseIP("10.0.0.2"), nil, 0),
+		), ie.NewPrecedence(4), ie.NewFARID(4), ie.NewOuterHeaderRemoval(6, 0))},
+		{name: "framed routes and other IEs", pdr: NewPDR(ie.NewPDRID(6), ie.NewPDI(
+			ie.NewSourceInterface(ie.SrcInterfaceCore),
+			ie.NewEthernetPDUSessionInformation(1),
+			ie.NewFramedRoute("10.46.0.0/16"),
+			ie.NewFramedRouting(1),
+			ie.NewFramedIPv6Route("2001:db8:1::/48"),
+			ie.NewTrafficEndpointID(1),
+		), ie.NewPrecedence(5), ie.NewFARID(5), nil)},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			b, err := json.Marshal(tc.pdr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pdr PDR
+			if err := json.Unmarshal(b, &pdr); err != nil {
+				t.Fatalf("%s: %v", b, err)
+			}
+			checkIE(t, "Create PDR", pdr.NewCreatePDR(), tc.pdr.NewCreatePDR())
+		})
+	}
+}
+
+func TestFARJSONRoundTrip(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name string
+		far  *FAR
+	}{
+		{name: "drop", far: NewFAR(ie.NewFARID(1), ie.NewApplyAction(0x01), nil)},
+		{name: "two octets Apply Action", far: NewFAR(ie.NewFARID(2), ie.NewApplyAction(0x82, 0x01), nil)},
+		{name: "forward to Core", far: NewFAR(ie.NewFARID(3), ie.NewApplyAction(0x02), ie.NewForwardingParameters(
+			ie.NewDestinationInterface(ie.DstInterfaceCore),
+			ie.NewNetworkInstance("internet"),
+		))},
+		{name: "Outer Header Creation GTP-U/UDP/IPv4", far: NewFAR(ie.NewFARID(4), ie.NewApplyAction(0x02), ie.NewForwardingParameters(
+			ie.NewDestinationInterface(ie.DstInterfaceAccess),
+			ie.NewNetworkInstance("access"),
+			ie.NewOuterHeaderCreation(0x0100, 0x1234, "10.0.0.3", "", 0, 0, 0),
+		))},
+		{name: "Outer Header Creation GTP-U/UDP/IPv6", far: NewFAR(ie.NewFARID(5), ie.NewApplyAction(0x02), ie.NewForwardingParameters(
+			ie.NewDestinationInterface(ie.DstInterfaceAccess),
+			ie.NewOuterHeaderCreation(0x0200, 0x1234, "", "fd00::3", 0, 0, 0),
+		))},
+		{name: "Outer Header Creation UDP/IPv4 and other IEs", far: NewFAR(ie.NewFARID(6), ie.NewApplyAction(0x02), ie.NewForwardingParameters(
+			ie.NewDestinationInterface(ie.DstInterfaceSGiLANN6LAN),
+			ie.NewOuterHeaderCreation(0x0400, 0, "10.0.0.4", "", 2152, 0, 0),
+			ie.NewForwardingPolicy("policy"),
+		))},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			b, err := json.Marshal(tc.far)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var far FAR
+			if err := json.Unmarshal(b, &far); err != nil {
+				t.Fatalf("%s: %v", b, err)
+			}
+			checkIE(t, "Create FAR", far.NewCreateFAR(), tc.far.NewCreateFAR())
+		})
+	}
+}
+
+func TestPFCPSessionJSONRoundTrip(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name        string
+		localFseid  *ie.IE
+		remoteFseid *ie.IE
+	}{
+		{name: "IPv4", localFseid: ie.NewFSEID(1, net.ParseIP("10.0.0.2"), nil), remoteFseid: ie.NewFSEID(2, net.ParseIP("10.0.0.1"), nil)},
+		{name: "IPv6", localFseid: ie.NewFSEID(1, nil, net.ParseIP("fd00::2")), remoteFseid: ie.NewFSEID(2, nil, net.ParseIP("fd00::1"))},
+		{name: "IPv4 and IPv6", localFseid: ie.NewFSEID(1, net.ParseIP("10.0.0.2"), net.ParseIP("fd00::2")), remoteFseid: ie.NewFSEID(2, net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1"))},
+		{name: "unknown remote F-SEID", localFseid: ie.NewFSEID(1, net.ParseIP("10.0.0.2"), nil)},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			pdrs, err := NewPDRMap([]*ie.IE{
+				ie.NewCreatePDR(ie.NewPDRID(1), ie.NewPrecedence(2), ie.NewPDI(
+					ie.NewSourceInterface(ie.SrcInterfaceAccess),
+					ie.NewFTEID(0x01, 1, net.ParseIP("10.0.0.2"), nil, 0),
+				), ie.NewOuterHeaderRemoval(0, 0), ie.NewFARID(1)),
+				ie.NewCreatePDR(ie.NewPDRID(2), ie.NewPrecedence(1), ie.NewPDI(
+					ie.NewSourceInterface(ie.SrcInterfaceCore),
+					ie.NewUEIPAddress(api.UEIPFlagV4|api.UEIPFlagSD, "10.45.0.1", "", 0, 0),
+					ie.NewSDFFilter("permit out ip from any to assigned", "", "", "", 0),
+				), ie.NewFARID(2)),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			fars, err := NewFARMap([]*ie.IE{
+				ie.NewCreateFAR(ie.NewFARID(2), ie.NewApplyAction(0x02), ie.NewForwardingParameters(
+					ie.NewDestinationInterface(ie.DstInterfaceAccess),
+					ie.NewOuterHeaderCreation(0x0100, 1, "10.0.0.3", "", 0, 0, 0),
+				)),
+				ie.NewCreateFAR(ie.NewFARID(1), ie.NewApplyAction(0x02), ie.NewForwardingParameters(
+					ie.NewDestinationInterface(ie.DstInterfaceCore),
+				)),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			session := &PFCPSession{localFseid: tc.localFseid, remoteFseid: tc.remoteFseid, pdr: pdrs, far: fars}
+			b, err := json.Marshal(session)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded PFCPSession
+			if err := json.Unmarshal(b, &decoded); err != nil {
+				t.Fatalf("%s: %v", b, err)
+			}
+			checkIE(t, "local F-SEID", decoded.localFseid, tc.localFseid)
+			checkIE(t, "remote F-SEID", decoded.remoteFseid, tc.remoteFseid)
+			for _, id := range []api.PDRID{1, 2} {
+				got, err := decoded.GetPDR(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected, _ := session.GetPDR(id)
+				checkIE(t, "Create PDR", got.(*PDR).NewCreatePDR(), expected.(*PDR).NewCreatePDR())
+			}
+			for _, id := range []api.FARID{1, 2} {
+				got, err := decoded.GetFAR(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected, _ := session.GetFAR(id)
+				checkIE(t, "Create FAR", got.(*FAR).NewCreateFAR(), expected.(*FAR).NewCreateFAR())
+			}
+		})
+	}
+}
+
+func TestPFCPAssociationJSONRoundTrip(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name     string
+		nodeID   *ie.IE
+		isSetup  bool
+		nextSEID api.SEID
+	}{
+		{name: "IPv4", nodeID: ie.NewNodeIDHeuristic("10.0.0.1"), isSetup: true, nextSEID: 2},
+		{name: "IPv6", nodeID: ie.NewNodeIDHeuristic("fd00::1"), isSetup: true, nextSEID: 1},
+		{name: "FQDN", nodeID: ie.NewNodeIDHeuristic("smf.example.org"), nextSEID: 42},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			association := &PFCPAssociation{
+				PFCPPeerInterface: newDetachedPFCPPeer(nil, tc.nodeID, ""),
+				isSetup:           tc.isSetup,
+				sessionIDPool:     NewSessionIDPool(),
+			}
+			association.sessionIDPool.setNext(tc.nextSEID)
+			b, err := json.Marshal(association)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded PFCPAssociation
+			if err := json.Unmarshal(b, &decoded); err != nil {
+				t.Fatalf("%s: %v", b, err)
+			}
+			checkIE(t, "Node ID", decoded.NodeID(), tc.nodeID)
+			if decoded.isSetup != tc.isSetup {
+				t.Errorf("got is_setup %t, expected %t", decoded.isSetup, tc.isSetup)
+			}
+			if next := decoded.sessionIDPool.peekNext(); next != tc.nextSEID {
+				t.Errorf("got next SEID %d, expected %d", next, tc.nextSEID)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name string
+		v    json.Unmarshaler
+		doc  string
+		err  string
+	}{
+		{name: "missing PDR ID", v: &PDR{}, doc: `{"precedence": 1, "pdi": {"source_interface": "Access"}}`, err: "PDR ID is missing"},
+		{name: "missing Precedence", v: &PDR{}, doc: `{"pdr_id": 1, "pdi": {"source_interface": "Access"}}`, err: "Precedence is missing in PDR 1"},
+		{name: "missing PDI", v: &PDR{}, doc: `{"pdr_id": 1, "precedence": 1}`, err: "PDI is missing in PDR 1"},
+		{name: "missing Source Interface", v: &PDR{}, doc: `{"pdr_id": 1, "precedence": 1, "pdi": {}}`, err: "PDR 1: Source Interface is missing in PDI"},
+		{name: "missing FAR ID", v: &FAR{}, doc: `{"apply_action": ["FORW"]}`, err: "FAR ID is missing"},
+		{name: "unknown Apply Action", v: &FAR{}, doc: `{"far_id": 1, "apply_action": ["SEND"]}`, err: `FAR 1: Wrong Apply Action: Unknown flag "SEND"`},
+		{name: "missing Local F-SEID", v: &PFCPSession{}, doc: `{"pdrs": [], "fars": []}`, err: "Local F-SEID is missing"},
+		{name: "missing PDR ID in session", v: &PFCPSession{}, doc: `{"local_fseid": {"seid": 1, "ipv4": "10.0.0.2"}, "pdrs": [{"precedence": 1}], "fars": []}`, err: "PDR ID is missing"},
+		{name: "missing FAR ID in session", v: &PFCPSession{}, doc: `{"local_fseid": {"seid": 1, "ipv4": "10.0.0.2"}, "pdrs": [], "fars": [{}]}`, err: "FAR ID is missing"},
+		{name: "missing Node ID", v: &PFCPAssociation{}, doc: `{"is_setup": true, "next_seid": 1}`, err: "Node ID is missing"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := json.Unmarshal([]byte(tc.doc), tc.v)
+			if err == nil {
+				t.Fatalf("%s has been decoded", tc.doc)
+			}
+			if err.Error() != tc.err {
+				t.Errorf("got error %q, expected %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"log/slog"
+
+	"github.com/nextmn/go-pfcp-networking/pfcputil"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Keys of attributes used in log records
+const (
+	LogKeyNodeID         = "node_id"
+	LogKeyPeer           = "peer"
+	LogKeySEID           = "seid"
+	LogKeyMessageType    = "message_type"
+	LogKeySequenceNumber = "sequence_number"
+)
+
+// Returns a log attribute for a NodeID IE
+func logAttrNodeID(key string, nodeID *ie.IE) slog.Attr {
+	if nodeID == nil {
+		return slog.String(key, "")
+	}
+	nid, err := nodeID.NodeID()
+	if err != nil {
+		return slog.String(key, "")
+	}
+	return slog.String(key, nid)
+}
+
+// Returns log attributes describing a message
+func logAttrsMessage(msg message.Message) []any {
+	attrs := []any{
+		slog.String(LogKeyMessageType, msg.MessageTypeName()),
+		slog.Uint64(LogKeySequenceNumber, uint64(msg.Sequence())),
+	}
+	if pfcputil.IsMessageTypeSessionRelated(msg.MessageType()) {
+		attrs = append(attrs, slog.Uint64(LogKeySEID, msg.SEID()))
+	}
+	return attrs
+}
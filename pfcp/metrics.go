@@ -0,0 +1,70 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Default metrics: all events are discarded
+type noopMetrics struct{}
+
+func (noopMetrics) MessageSent(msgType uint8)                      {}
+func (noopMetrics) MessageReceived(msgType uint8)                  {}
+func (noopMetrics) RequestRetransmitted(msgType uint8)             {}
+func (noopMetrics) RequestTimedOut(msgType uint8)                  {}
+func (noopMetrics) ResponseSent(msgType uint8, cause uint8)        {}
+func (noopMetrics) ResponseReceived(msgType uint8, cause uint8)    {}
+func (noopMetrics) HandlerDuration(msgType uint8, d time.Duration) {}
+func (noopMetrics) AssociationsChanged(delta int)                  {}
+func (noopMetrics) SessionsChanged(delta int)                      {}
+func (noopMetrics) PDRsChanged(delta int)                          {}
+func (noopMetrics) FARsChanged(delta int)                          {}
+
+// Returns the value of the Cause IE of a marshaled PFCP message
+func causeFromBytes(b []byte) (uint8, error) {
+	h, err := message.ParseHeader(b)
+	if err != nil {
+		return 0, err
+	}
+	ies, err := ie.ParseMultiIEs(h.Payload)
+	if err != nil {
+		return 0, err
+	}
+	for _, i := range ies {
+		if i.Type == ie.Cause {
+			return i.Cause()
+		}
+	}
+	return 0, fmt.Errorf("No Cause IE in message")
+}
+
+// Returns the value of the Cause IE of a PFCP message
+func causeFromMessage(msg message.Message) (uint8, error) {
+	b := make([]byte, msg.MarshalLen())
+	if err := msg.MarshalTo(b); err != nil {
+		return 0, err
+	}
+	return causeFromBytes(b)
+}
+
+// Returns the number of PDRs and FARs of a session
+func countPDRsFARs(session api.PFCPSessionInterface) (pdrs int, fars int) {
+	session.ForeachUnsortedPDR(func(pdr api.PDRInterface) error {
+		pdrs++
+		return nil
+	})
+	session.ForeachUnsortedFAR(func(far api.FARInterface) error {
+		fars++
+		return nil
+	})
+	return pdrs, fars
+}
@@ -16,16 +16,28 @@ type PDR struct {
 	precedence         *ie.IE
 	farid              *ie.IE
 	outerHeaderRemoval *ie.IE
+
+	// PDI is parsed once, since it is used to classify every packet
+	pdiFields *api.PDIFields
+	pdiErr    error
 }
 
 func NewPDR(id *ie.IE, pdi *ie.IE, precedence *ie.IE, farid *ie.IE, outerHeaderRemoval *ie.IE) *PDR {
-	return &PDR{
+	pdr := PDR{
 		id:                 id,
 		pdi:                pdi,
 		precedence:         precedence,
 		farid:              farid,
 		outerHeaderRemoval: outerHeaderRemoval,
 	}
+	if pdi == nil {
+		pdr.pdiErr = ie.ErrIENotFound
+	} else if ies, err := pdi.PDI(); err != nil {
+		pdr.pdiErr = err
+	} else {
+		pdr.pdiFields, pdr.pdiErr = api.ParsePDIFields(ies)
+	}
+	return &pdr
 }
 
 func (pdr *PDR) ID() (api.PDRID, error) {
@@ -35,6 +47,11 @@ func (pdr *PDR) ID() (api.PDRID, error) {
 func (pdr *PDR) PDI() ([]*ie.IE, error) {
 	return pdr.pdi.PDI()
 }
+
+// The returned PDIFields is shared by every caller, and must not be modified
+func (pdr *PDR) PDIFields() (*api.PDIFields, error) {
+	return pdr.pdiFields, pdr.pdiErr
+}
 func (pdr *PDR) Precedence() (uint32, error) {
 	return pdr.precedence.Precedence()
 }
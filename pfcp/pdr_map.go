@@ -6,6 +6,7 @@
 package pfcp_networking
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"sort"
@@ -215,7 +216,25 @@ func (m *PDRMap) SimulateRemove(key api.PDRID) error {
 	return nil
 }
 
-func NewPDRMap(pdrs []*ie.IE) (pdrmap *PDRMap, err error, cause uint8, offendingIE uint16) {
+// Returns the CauseError to be sent when content of a PDI is incorrect
+func pdiValidationError(err error) *CauseError {
+	var perr *api.PDIError
+	if !errors.As(err, &perr) {
+		return newValidationError(err, ie.CauseMandatoryIEIncorrect, ie.PDI)
+	}
+	switch {
+	case perr.Err == io.ErrUnexpectedEOF:
+		return newValidationError(err, ie.CauseInvalidLength, perr.IEType)
+	case perr.Err == ie.ErrIENotFound:
+		return newValidationError(err, ie.CauseMandatoryIEMissing, perr.IEType)
+	case perr.IEType == ie.SourceInterface:
+		return newValidationError(err, ie.CauseMandatoryIEIncorrect, perr.IEType)
+	default:
+		return newValidationError(err, ie.CauseRuleCreationModificationFailure, perr.IEType)
+	}
+}
+
+func NewPDRMap(pdrs []*ie.IE) (pdrmap *PDRMap, err error) {
 	p := PDRMap{
 		pdrmap:    make(pdrmapInternal),
 		mu:        sync.RWMutex{},
@@ -229,44 +248,47 @@ func NewPDRMap(pdrs []*ie.IE) (pdrmap *PDRMap, err error, cause uint8, offending
 		if err != nil {
 			switch err {
 			case io.ErrUnexpectedEOF:
-				return nil, err, ie.CauseInvalidLength, ie.PDRID
+				return nil, newValidationError(err, ie.CauseInvalidLength, ie.PDRID)
 			case ie.ErrIENotFound:
-				return nil, err, ie.CauseMandatoryIEMissing, ie.PDRID
+				return nil, newValidationError(err, ie.CauseMandatoryIEMissing, ie.PDRID)
 			default:
-				return nil, err, ie.CauseMandatoryIEIncorrect, ie.CreatePDR
+				return nil, newValidationError(err, ie.CauseMandatoryIEIncorrect, pdr.Type)
 			}
 		}
 		pdi, err := pdr.PDI()
 		if err != nil {
 			switch err {
 			case io.ErrUnexpectedEOF:
-				return nil, err, ie.CauseInvalidLength, ie.PDI
+				return nil, newValidationError(err, ie.CauseInvalidLength, ie.PDI)
 			case ie.ErrIENotFound:
-				return nil, err, ie.CauseMandatoryIEMissing, ie.PDI
+				return nil, newValidationError(err, ie.CauseMandatoryIEMissing, ie.PDI)
 			default:
-				return nil, err, ie.CauseMandatoryIEIncorrect, ie.CreatePDR
+				return nil, newValidationError(err, ie.CauseMandatoryIEIncorrect, pdr.Type)
 			}
 		}
+		if _, err := api.ParsePDIFields(pdi); err != nil {
+			return nil, pdiValidationError(err)
+		}
 		precedence, err := pdr.Precedence()
 		if err != nil {
 			switch err {
 			case io.ErrUnexpectedEOF:
-				return nil, err, ie.CauseInvalidLength, ie.Precedence
+				return nil, newValidationError(err, ie.CauseInvalidLength, ie.Precedence)
 			case ie.ErrIENotFound:
-				return nil, err, ie.CauseMandatoryIEMissing, ie.Precedence
+				return nil, newValidationError(err, ie.CauseMandatoryIEMissing, ie.Precedence)
 			default:
-				return nil, err, ie.CauseMandatoryIEIncorrect, ie.CreatePDR
+				return nil, newValidationError(err, ie.CauseMandatoryIEIncorrect, pdr.Type)
 			}
 		}
 		farid, err := pdr.FARID()
 		if err != nil {
 			switch err {
 			case io.ErrUnexpectedEOF:
-				return nil, err, ie.CauseInvalidLength, ie.FARID
+				return nil, newValidationError(err, ie.CauseInvalidLength, ie.FARID)
 			case ie.ErrIENotFound:
-				return nil, err, ie.CauseMandatoryIEMissing, ie.FARID
+				return nil, newValidationError(err, ie.CauseMandatoryIEMissing, ie.FARID)
 			default:
-				return nil, err, ie.CauseMandatoryIEIncorrect, ie.CreatePDR
+				return nil, newValidationError(err, ie.CauseMandatoryIEIncorrect, pdr.Type)
 			}
 		}
 
@@ -280,7 +302,7 @@ func NewPDRMap(pdrs []*ie.IE) (pdrmap *PDRMap, err error, cause uint8, offending
 			}
 			ohrIE = ie.NewOuterHeaderRemoval(ohr[0], ohr[1])
 		} else if err == io.ErrUnexpectedEOF {
-			return nil, err, ie.CauseInvalidLength, ie.OuterHeaderRemoval
+			return nil, newValidationError(err, ie.CauseInvalidLength, ie.OuterHeaderRemoval)
 		}
 
 		err = p.Add(NewPDR(
@@ -291,8 +313,8 @@ func NewPDRMap(pdrs []*ie.IE) (pdrmap *PDRMap, err error, cause uint8, offending
 			ohrIE,
 		))
 		if err != nil {
-			return nil, err, ie.CauseMandatoryIEIncorrect, ie.CreatePDR
+			return nil, newValidationError(err, ie.CauseMandatoryIEIncorrect, pdr.Type)
 		}
 	}
-	return &p, nil, 0, 0
+	return &p, nil
 }
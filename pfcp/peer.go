@@ -6,10 +6,12 @@
 package pfcp_networking
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"github.com/nextmn/go-pfcp-networking/pfcp/api"
 	"github.com/nextmn/go-pfcp-networking/pfcputil"
@@ -19,18 +21,29 @@ import (
 
 type messageChan chan []byte
 
+// An outstanding Request
+type transaction struct {
+	ch    messageChan   // receives the Response
+	slots chan struct{} // semaphore the transaction slot has been taken from
+}
+
 // A PFCPPeer is a remote PFCPEntity
 type PFCPPeer struct {
 	nodeID  *ie.IE
 	srv     api.PFCPEntityInterface
-	conn    *net.UDPConn
+	conn    net.PacketConn
 	udpAddr *net.UDPAddr
 	seq     uint32
-	seqMu   sync.Mutex
-	queue   map[uint32]messageChan
+	queue   map[uint32]transaction
 	queueMu sync.Mutex
-	stop    bool
-	kind    string
+	// semaphore limiting the number of outstanding Requests:
+	// a token is sent to take a transaction slot, and received to free it
+	slots    chan struct{}
+	stop     atomic.Bool
+	closed   chan struct{} // closed when the peer is closed
+	kind     string
+	logger   *slog.Logger
+	recorder api.RecorderInterface // when nil, the recorder of the local entity is used
 }
 
 func (peer *PFCPPeer) NewEstablishedPFCPAssociation() (api.PFCPAssociationInterface, error) {
@@ -54,31 +67,49 @@ func newPFCPPeer(srv api.PFCPEntityInterface, nodeID *ie.IE, kind string) (peer
 	if err != nil {
 		return nil, err
 	}
-	c, err := net.Dial("udp", udpAddr)
+	conn, err := srv.Transport().DialUDP(nodeIDIP(srv.NodeID()), raddr)
 	if err != nil {
 		return nil, err
 	}
-	c.Close()
-	laddr := c.LocalAddr().(*net.UDPAddr)
-	conn, err := net.ListenUDP("udp", laddr)
-	if err != nil {
-		return nil, err
+	p := PFCPPeer{
+		srv:      srv,
+		nodeID:   nodeID,
+		conn:     conn,
+		udpAddr:  raddr,
+		seq:      1,
+		queue:    make(map[uint32]transaction),
+		queueMu:  sync.Mutex{},
+		slots:    make(chan struct{}, pfcputil.DEFAULT_MAX_OUTSTANDING_REQUESTS),
+		closed:   make(chan struct{}),
+		kind:     kind,
+		logger:   srv.Logger().With(logAttrNodeID(LogKeyPeer, nodeID)),
+		recorder: nil,
+	}
+	// Read incomming messages
+	p.start()
+	return &p, nil
+}
+
+// A detached peer is closed: no message can be sent to it.
+// It is used for decoded associations (srv is nil), and for pseudo-associations of provisioned sessions.
+func newDetachedPFCPPeer(srv api.PFCPEntityInterface, nodeID *ie.IE, kind string) *PFCPPeer {
+	logger := slog.Default()
+	if srv != nil {
+		logger = srv.Logger()
 	}
 	p := PFCPPeer{
 		srv:     srv,
 		nodeID:  nodeID,
-		conn:    conn,
-		udpAddr: raddr,
-		seq:     1,
-		seqMu:   sync.Mutex{},
-		queue:   make(map[uint32]messageChan),
+		queue:   make(map[uint32]transaction),
 		queueMu: sync.Mutex{},
-		stop:    false,
+		slots:   make(chan struct{}, pfcputil.DEFAULT_MAX_OUTSTANDING_REQUESTS),
+		closed:  make(chan struct{}),
 		kind:    kind,
+		logger:  logger.With(logAttrNodeID(LogKeyPeer, nodeID)),
 	}
-	// Read incomming messages
-	p.start()
-	return &p, nil
+	p.stop.Store(true)
+	close(p.closed)
+	return &p
 }
 
 func newPFCPPeerUP(srv api.PFCPEntityInterface, nodeID *ie.IE) (peer *PFCPPeer, err error) {
@@ -109,11 +140,14 @@ func (peer *PFCPPeer) startLoop() {
 
 func (peer *PFCPPeer) loopUnwrapped() {
 	b := make([]byte, pfcputil.DEFAULT_MTU) // TODO: detect MTU for interface instead of using DEFAULT_MTU
-	n, _, err := peer.conn.ReadFromUDP(b)
+	n, addr, err := peer.conn.ReadFrom(b)
 	if err != nil {
 		// socket has been closed
 		return
 	}
+	if recorder := peer.getRecorder(); recorder != nil {
+		recorder.Record(addr, peer.conn.LocalAddr(), b[:n])
+	}
 	// Processing of message in a new thread to avoid blocking
 	go func(msgArray []byte, size int, e *PFCPPeer) {
 		msg, err := message.ParseHeader(msgArray[:size])
@@ -124,25 +158,29 @@ func (peer *PFCPPeer) loopUnwrapped() {
 
 		e.queueMu.Lock()
 		defer e.queueMu.Unlock()
-		ch, exists := e.queue[sn]
+		t, exists := e.queue[sn]
 		if exists {
-			ch <- msgArray[:size]
+			select {
+			case t.ch <- msgArray[:size]:
+			default:
+				// a Response has already been received for this transaction
+			}
 		}
 	}(b, n, peer)
 }
 
 func (peer *PFCPPeer) IsRunning() bool {
-	return !peer.stop
+	return !peer.stop.Load()
 }
 
 // Close connection of PFCPPeer
 func (peer *PFCPPeer) Close() error {
 	// if already stopped, for whatever reason, we exit
-	if peer.stop {
+	if !peer.stop.CompareAndSwap(false, true) {
 		return nil
 	}
-	// setting stop state and closing connection
-	peer.stop = true
+	// wake up Requests waiting for a free transaction slot
+	close(peer.closed)
 	err := peer.conn.Close()
 	if err != nil {
 		return err
@@ -150,91 +188,232 @@ func (peer *PFCPPeer) Close() error {
 	return nil
 }
 
+// Set the recorder receiving every datagram sent or received by this PFCPPeer,
+// instead of the recorder of the local entity
+func (peer *PFCPPeer) SetRecorder(recorder api.RecorderInterface) {
+	peer.recorder = recorder
+}
+
+func (peer *PFCPPeer) getRecorder() api.RecorderInterface {
+	if peer.recorder != nil {
+		return peer.recorder
+	}
+	return peer.LocalEntity().Recorder()
+}
+
+// Write a datagram to the peer
+func (peer *PFCPPeer) write(b []byte) error {
+	if _, err := peer.conn.WriteTo(b, peer.udpAddr); err != nil {
+		return fmt.Errorf("Error on write: %s\n", err)
+	}
+	if recorder := peer.getRecorder(); recorder != nil {
+		recorder.Record(peer.conn.LocalAddr(), peer.udpAddr, b)
+	}
+	return nil
+}
+
+// Set the maximum number of outstanding Requests for this PFCPPeer.
+// Requests already outstanding are not counted against the new limit.
+func (peer *PFCPPeer) SetMaxOutstandingRequests(n int) error {
+	if n < 1 {
+		return fmt.Errorf("Maximum number of outstanding requests must be at least 1")
+	}
+	if n > pfcputil.SEQUENCE_NUMBER_MAX {
+		return fmt.Errorf("Maximum number of outstanding requests cannot exceed the number of sequence numbers")
+	}
+	peer.queueMu.Lock()
+	defer peer.queueMu.Unlock()
+	peer.slots = make(chan struct{}, n)
+	return nil
+}
+
 // Get next sequence number available for this PFCPPeer
 // Sequence murber shall be unique for each oustanding
 // message sourced from the same IP/UDP endpoint.
 // Since we use exactly 1 IP/UDP endpoint per peer to send Requests,
 // our sequence numbers are also unique per peer.
+// Sequence numbers are 24 bits long: after wraparound, numbers
+// still used by an outstanding message are skipped.
+// queueMu must be held by the caller.
 func (peer *PFCPPeer) getNextSequenceNumber() uint32 {
-	peer.seqMu.Lock()
-	defer peer.seqMu.Unlock()
-	s := peer.seq
-	peer.seq += 1
-	return s
+	for {
+		s := peer.seq
+		peer.seq = (peer.seq + 1) & pfcputil.SEQUENCE_NUMBER_MAX
+		if _, exists := peer.queue[s]; !exists {
+			return s
+		}
+	}
 }
 
-// Add a message to queue. Response will be send to channel ch messageChan
-func (peer *PFCPPeer) addToQueue(sn uint32, ch messageChan) {
+// Wait for a free transaction slot, then add a message to queue.
+// Response will be send to channel ch messageChan.
+// Waiting is aborted when ctx is done.
+func (peer *PFCPPeer) addToQueue(ctx context.Context, ch messageChan) (sn uint32, err error) {
+	peer.queueMu.Lock()
+	slots := peer.slots
+	peer.queueMu.Unlock()
+	if peer.stop.Load() {
+		return 0, fmt.Errorf("PFCP Peer is closed")
+	}
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-peer.closed:
+		return 0, fmt.Errorf("PFCP Peer is closed")
+	}
 	peer.queueMu.Lock()
 	defer peer.queueMu.Unlock()
-	peer.queue[sn] = ch
+	sn = peer.getNextSequenceNumber()
+	peer.queue[sn] = transaction{ch: ch, slots: slots}
+	return sn, nil
 }
 
 // Remove a message from queue (used when a response is received, or when timeout is reached)
 func (peer *PFCPPeer) deleteFromQueue(sn uint32) {
 	peer.queueMu.Lock()
 	defer peer.queueMu.Unlock()
-	close(peer.queue[sn])
+	t := peer.queue[sn]
+	close(t.ch)
 	delete(peer.queue, sn)
+	<-t.slots
 }
 
-// Send a PFCP message
+// Send a PFCP message, and wait for the Response.
+// If the peer rejects the Request (Cause with a rejection value, see TS 29.244 section 8.2.1),
+// the Response is returned together with a *CauseError: callers do not need to check the Cause
+// to detect a rejection. If the peer does not respond, the error is a *TimeoutError.
 func (peer *PFCPPeer) Send(msg message.Message) (m message.Message, err error) {
+	return peer.SendAsyncContext(context.Background(), msg).Wait()
+}
 
+// Send a PFCP message, and wait for the Response.
+// Errors are the ones of Send; the transaction is aborted when ctx is done.
+func (peer *PFCPPeer) SendContext(ctx context.Context, msg message.Message) (m message.Message, err error) {
+	return peer.SendAsyncContext(ctx, msg).Wait()
+}
+
+// Send a PFCP message without waiting for the Response.
+// If the maximum number of outstanding Requests is reached,
+// this function blocks until a transaction completes.
+func (peer *PFCPPeer) SendAsync(msg message.Message) api.PFCPFutureInterface {
+	return peer.SendAsyncContext(context.Background(), msg)
+}
+
+// Send a PFCP message without waiting for the Response.
+// If the maximum number of outstanding Requests is reached,
+// this function blocks until a transaction completes, or until ctx is done
+// (the future then fails with ctx.Err()).
+// The transaction is aborted when ctx is done.
+func (peer *PFCPPeer) SendAsyncContext(ctx context.Context, msg message.Message) api.PFCPFutureInterface {
 	//XXX: cannot use `h, err := msg.(*message.Header)` because Header does not implement MessageTypeName()
 	msgb := make([]byte, msg.MarshalLen())
-	err = msg.MarshalTo(msgb)
-	if err != nil {
-		return nil, err
+	if err := msg.MarshalTo(msgb); err != nil {
+		return newFailedPFCPFuture(err)
 	}
 	h, err := message.ParseHeader(msgb)
 	if err != nil {
-		return nil, err
+		return newFailedPFCPFuture(err)
 	}
 
 	if !pfcputil.IsMessageTypeRequest(h.MessageType()) {
-		return nil, fmt.Errorf("Unexpected outcomming PFCP message type")
+		return newFailedPFCPFuture(fmt.Errorf("Unexpected outcomming PFCP message type"))
+	}
+
+	// buffered channel: the reading loop must never block
+	ch := make(messageChan, 1)
+	sn, err := peer.addToQueue(ctx, ch)
+	if err != nil {
+		return newFailedPFCPFuture(err)
 	}
-	sn := peer.getNextSequenceNumber()
 	h.SetSequenceNumber(sn)
 	b, err := h.Marshal()
 	if err != nil {
-		return nil, err
+		peer.deleteFromQueue(sn)
+		return newFailedPFCPFuture(err)
 	}
 
-	ch := make(messageChan)
-	peer.addToQueue(sn, ch)
-	defer peer.deleteFromQueue(sn)
+	logger := peer.logger.With(slog.String(LogKeyMessageType, msg.MessageTypeName()), slog.Uint64(LogKeySequenceNumber, uint64(sn)))
+	if pfcputil.IsMessageTypeSessionRelated(msg.MessageType()) {
+		logger = logger.With(slog.Uint64(LogKeySEID, msg.SEID()))
+	}
+	ctx, span := startSpan(ctx, peer.LocalEntity().Tracer(), msg)
+	span.SetAttributes(spanAttrsMessage(msg.MessageType(), sn, msg.SEID())...)
+	if nid, err := peer.NodeID().NodeID(); err == nil {
+		span.SetAttributes(api.SpanAttribute{Key: api.SpanAttrPeerNodeID, Value: nid})
+	}
+	f := newPFCPFuture()
+	go func() {
+		defer peer.deleteFromQueue(sn)
+		res, retransmissions, err := peer.transaction(ctx, logger, msg.MessageType(), b, ch)
+		span.SetAttributes(api.SpanAttribute{Key: api.SpanAttrRetransmissions, Value: int64(retransmissions)})
+		if err != nil {
+			span.RecordError(err)
+		} else if cause, err := causeFromMessage(res); err == nil {
+			span.SetAttributes(api.SpanAttribute{Key: api.SpanAttrCause, Value: uint64(cause)})
+		}
+		if err == nil {
+			if rejection := rejectionFromResponse(peer.NodeID(), msg.MessageType(), res); rejection != nil {
+				err = rejection
+			}
+		}
+		span.End()
+		f.complete(res, err)
+	}()
+	return f
+}
 
-	_, err = peer.conn.WriteToUDP(b, peer.udpAddr)
-	if err != nil {
-		return nil, fmt.Errorf("Error on write: %s\n", err)
+// Transmit a Request, and retransmit it until a Response is received on ch
+func (peer *PFCPPeer) transaction(ctx context.Context, logger *slog.Logger, msgType uint8, b []byte, ch messageChan) (m message.Message, retransmissions int, err error) {
+	metrics := peer.LocalEntity().Metrics()
+	clock := peer.LocalEntity().Clock()
+	logger.Debug("Sending PFCP Request")
+	if err := peer.write(b); err != nil {
+		return nil, 0, err
 	}
+	metrics.MessageSent(msgType)
 
-	for i := 0; i < pfcputil.MESSAGE_RETRANSMISSION_N1; i++ {
+	for {
 		select {
 		case r := <-ch:
 			msg, err := message.Parse(r)
 			if err != nil {
-				return nil, fmt.Errorf("Unexpected incomming packet")
+				return nil, retransmissions, fmt.Errorf("Unexpected incomming packet")
 			}
 			if !pfcputil.IsMessageTypeResponse(msg.MessageType()) {
-				return nil, fmt.Errorf("Unexpected incomming PFCP message type")
+				return nil, retransmissions, fmt.Errorf("Unexpected incomming PFCP message type")
+			}
+			logger.Debug("Received PFCP Response", slog.String("response_type", msg.MessageTypeName()))
+			metrics.MessageReceived(msg.MessageType())
+			if cause, err := causeFromBytes(r); err == nil {
+				metrics.ResponseReceived(msg.MessageType(), cause)
+			}
+			return msg, retransmissions, nil
+		case <-ctx.Done():
+			logger.Debug("PFCP Request aborted", slog.Any("error", ctx.Err()))
+			return nil, retransmissions, ctx.Err()
+		case <-clock.After(pfcputil.MESSAGE_RETRANSMISSION_T1):
+			if retransmissions >= pfcputil.MESSAGE_RETRANSMISSION_N1 {
+				logger.Info("PFCP Request timed out")
+				metrics.RequestTimedOut(msgType)
+				return nil, retransmissions, &TimeoutError{Peer: peer.NodeID(), MessageType: msgType, Retransmissions: retransmissions}
 			}
-			return msg, nil
-		case <-time.After(pfcputil.MESSAGE_RETRANSMISSION_T1):
 			// retry
-			_, err = peer.conn.WriteToUDP(b, peer.udpAddr)
-			if err != nil {
-				return nil, fmt.Errorf("Error on write: %s\n", err)
+			retransmissions++
+			logger.Debug("Retransmitting PFCP Request", slog.Int("retransmission", retransmissions))
+			if err := peer.write(b); err != nil {
+				return nil, retransmissions, err
 			}
+			metrics.RequestRetransmitted(msgType)
 		}
 	}
-	return nil, fmt.Errorf("Unsuccessfull transfer of Request message")
 }
 
 // Send an Heartbeat request, return true if the PFCP peer is alive.
 func (peer *PFCPPeer) IsAlive() (res bool, err error) {
+	if peer.srv == nil {
+		return false, fmt.Errorf("PFCP Peer is detached")
+	}
 	if peer.LocalEntity().RecoveryTimeStamp() == nil {
 		return false, fmt.Errorf("Local PFCP Entity is not yet started.")
 	}
@@ -0,0 +1,78 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcptransport"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+func TestSendContextWaitingForSlot(t *testing.T) {
+	// the peer never answers, and retransmission timers never expire
+	e := NewPFCPEntityCP("10.0.0.1")
+	if err := e.SetTransport(pfcptransport.NewMemoryNetwork()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetClock(&manualClock{now: time.Unix(0, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	e.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := e.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	peer, err := newPFCPPeerUP(e, ie.NewNodeIDHeuristic("10.0.0.2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	if err := peer.SetMaxOutstandingRequests(1); err != nil {
+		t.Fatal(err)
+	}
+	heartbeat := func() message.Message {
+		return message.NewHeartbeatRequest(0, e.RecoveryTimeStamp(), nil)
+	}
+
+	// takes the only transaction slot
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	first := peer.SendAsyncContext(ctx1, heartbeat())
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel2()
+	done := make(chan error, 1)
+	go func() {
+		_, err := peer.SendContext(ctx2, heartbeat())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v, expected %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Request waiting for a transaction slot has not been cancelled")
+	}
+
+	// the slot is freed when the first transaction is aborted
+	cancel1()
+	if _, err := first.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, expected %v", err, context.Canceled)
+	}
+	ctx3, cancel3 := context.WithCancel(context.Background())
+	third := peer.SendAsyncContext(ctx3, heartbeat())
+	cancel3()
+	if _, err := third.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, expected %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/wmnsk/go-pfcp/ie"
+	"gopkg.in/yaml.v3"
+)
+
+// Sessions can be provisioned on the UP function from a file, without CP function.
+// They belong to a pseudo-association: no PFCP message is exchanged with its Node ID.
+// They are validated like sessions of a Session Establishment Request,
+// and F-TEIDs and UE IP addresses they request with the CH, CHV4 and CHV6 flags are allocated.
+//
+// The file is YAML or JSON; PDRs and FARs use the schema of their JSON encoding (see json.go):
+//
+//	node_id: 10.0.0.100                       # Node ID of the pseudo-association
+//	sessions:
+//	  - cp_fseid: {seid: 1, ipv4: 10.0.0.100}
+//	    local_seid: 1000                      # optional, allocated when omitted
+//	    pdrs:
+//	      - pdr_id: 1
+//	        precedence: 255
+//	        pdi: {source_interface: Access, local_fteid: {teid: 1, ipv4: 10.0.0.2}, ue_ip_address: {ipv4: 10.45.0.1}}
+//	        outer_header_removal: {description: GTP-U/UDP/IPv4}
+//	        far_id: 1
+//	    fars:
+//	      - far_id: 1
+//	        apply_action: [FORW]
+//	        forwarding_parameters: {destination_interface: Core}
+//
+// Provisioning again replaces every provisioned session.
+// Local SEIDs of provisioned sessions are not allocated to them, but SEIDs of real associations
+// are allocated from 1: local SEIDs set in the file should be chosen far above.
+type provisioningFile struct {
+	NodeID   string               `json:"node_id"`
+	Sessions []provisionedSession `json:"sessions"`
+}
+
+type provisionedSession struct {
+	CPFSEID   *fseidJSON        `json:"cp_fseid"`
+	LocalSEID api.SEID          `json:"local_seid,omitempty"`
+	PDRs      []json.RawMessage `json:"pdrs"`
+	FARs      []json.RawMessage `json:"fars"`
+}
+
+// A provisioned session, ready to be established
+type sessionProvision struct {
+	cpFseid    *ie.IE
+	localSEID  api.SEID // 0 when it is allocated
+	createPDRs []*ie.IE
+	createFARs []*ie.IE
+}
+
+// Read the provisioning file, and check it as far as possible without creating sessions
+func parseProvisioningFile(r io.Reader) (nodeID *ie.IE, sessions []sessionProvision, err error) {
+	var v interface{}
+	if err := yaml.NewDecoder(r).Decode(&v); err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	// YAML is converted to JSON, to use the same schema
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var f provisioningFile
+	if err := dec.Decode(&f); err != nil {
+		return nil, nil, err
+	}
+	if f.NodeID == "" {
+		return nil, nil, fmt.Errorf("Node ID is missing")
+	}
+	localSEIDs := make(map[api.SEID]struct{})
+	sessions = make([]sessionProvision, 0, len(f.Sessions))
+	for i, ps := range f.Sessions {
+		sp, err := ps.parse()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Session %d: %w", i, err)
+		}
+		if sp.localSEID != 0 {
+			if _, exists := localSEIDs[sp.localSEID]; exists {
+				return nil, nil, fmt.Errorf("Session %d: local SEID %d is used twice", i, sp.localSEID)
+			}
+			localSEIDs[sp.localSEID] = struct{}{}
+		}
+		sessions = append(sessions, *sp)
+	}
+	return ie.NewNodeIDHeuristic(f.NodeID), sessions, nil
+}
+
+func (ps *provisionedSession) parse() (*sessionProvision, error) {
+	// CP F-SEID is a mandatory IE
+	if ps.CPFSEID == nil {
+		return nil, fmt.Errorf("CP F-SEID is missing")
+	}
+	if ps.CPFSEID.IPv4 == nil && ps.CPFSEID.IPv6 == nil {
+		return nil, fmt.Errorf("CP F-SEID has no IP Address")
+	}
+	sp := sessionProvision{
+		cpFseid:    ps.CPFSEID.ie(),
+		localSEID:  ps.LocalSEID,
+		createPDRs: make([]*ie.IE, 0, len(ps.PDRs)),
+		createFARs: make([]*ie.IE, 0, len(ps.FARs)),
+	}
+	for _, raw := range ps.PDRs {
+		var pdr PDR
+		if err := json.Unmarshal(raw, &pdr); err != nil {
+			return nil, err
+		}
+		sp.createPDRs = append(sp.createPDRs, pdr.NewCreatePDR())
+	}
+	for _, raw := range ps.FARs {
+		var far FAR
+		if err := json.Unmarshal(raw, &far); err != nil {
+			return nil, err
+		}
+		sp.createFARs = append(sp.createFARs, far.NewCreateFAR())
+	}
+	// rules are checked before any session is replaced
+	if _, err := NewPDRMap(sp.createPDRs); err != nil {
+		return nil, err
+	}
+	if _, err := NewFARMap(sp.createFARs); err != nil {
+		return nil, err
+	}
+	return &sp, nil
+}
+
+// Provision sessions from a YAML or JSON document, replacing previously provisioned sessions.
+// The entity must be started.
+// If the document is malformed, or if one of its sessions cannot be established,
+// previously provisioned sessions are kept.
+func (e *PFCPEntityUP) ProvisionSessions(r io.Reader) error {
+	if e.RecoveryTimeStamp() == nil {
+		return fmt.Errorf("Local PFCP entity is not started")
+	}
+	nodeID, sessions, err := parseProvisioningFile(r)
+	if err != nil {
+		return fmt.Errorf("Cannot read provisioned sessions: %w", err)
+	}
+	e.provisioningMu.Lock()
+	defer e.provisioningMu.Unlock()
+	localIP, err := e.localIPAddress()
+	if err != nil {
+		return err
+	}
+	// Previous sessions are unregistered, so the document can reuse their SEIDs,
+	// but their resources are kept until new sessions are established
+	previous := e.provisioning
+	old, err := e.unregisterProvisionedSessions()
+	if err != nil {
+		e.reregisterProvisionedSessions(old)
+		return err
+	}
+	if e.provisioning == nil || !bytes.Equal(e.provisioning.NodeID().Payload, nodeID.Payload) {
+		e.provisioning = newStaticPFCPAssociation(newDetachedPFCPPeer(e, nodeID, e.kind))
+	}
+	association := e.provisioning
+	// SEIDs set in the document are not allocated to other sessions of the document
+	reserved := make(map[api.SEID]struct{})
+	for _, sp := range sessions {
+		if sp.localSEID != 0 {
+			reserved[sp.localSEID] = struct{}{}
+		}
+	}
+	established := make([]api.PFCPSessionInterface, 0, len(sessions))
+	for i, sp := range sessions {
+		session, _, err := establishSession(e, e.localResources, sp.createPDRs, sp.createFARs, func(pdrs api.PDRMapInterface, fars api.FARMapInterface) (api.PFCPSessionInterface, error) {
+			seid := sp.localSEID
+			if seid == 0 {
+				var err error
+				if seid, err = association.nextFreeSEID(reserved); err != nil {
+					return nil, err
+				}
+			} else if _, err := e.GetPFCPSession(localIP, seid); err == nil {
+				return nil, fmt.Errorf("Local SEID %d is already used", seid)
+			}
+			return association.createSession(seid, sp.cpFseid, pdrs, fars)
+		})
+		if err != nil {
+			for _, s := range established {
+				if err := e.RemovePFCPSession(s); err != nil {
+					e.Logger().Error("Cannot remove provisioned session", slog.Any("error", err))
+				}
+			}
+			e.provisioning = previous
+			e.reregisterProvisionedSessions(old)
+			return fmt.Errorf("Cannot establish provisioned session %d: %w", i, err)
+		}
+		established = append(established, session)
+	}
+	for _, s := range old {
+		e.localResources.release(s)
+	}
+	e.Logger().Info("Provisioned sessions", logAttrNodeID(LogKeyPeer, nodeID), slog.Int("sessions", len(sessions)))
+	return nil
+}
+
+// Provision sessions from a YAML or JSON file, replacing previously provisioned sessions
+func (e *PFCPEntityUP) ProvisionSessionsFromFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return e.ProvisionSessions(f)
+}
+
+// Provision sessions from a file again each time SIGHUP is received, until ctx is done
+func (e *PFCPEntityUP) ReloadProvisionedSessionsOnSIGHUP(ctx context.Context, path string) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				if err := e.ProvisionSessionsFromFile(path); err != nil {
+					e.Logger().Error("Cannot reload provisioned sessions", slog.String("path", path), slog.Any("error", err))
+				}
+			}
+		}
+	}()
+}
+
+// Unregister every provisioned session, keeping their resources; provisioningMu must be held by the caller.
+// Returns unregistered sessions, even on error.
+func (e *PFCPEntityUP) unregisterProvisionedSessions() ([]*PFCPSession, error) {
+	unregistered := make([]*PFCPSession, 0)
+	if e.provisioning == nil {
+		return unregistered, nil
+	}
+	for _, session := range e.GetPFCPSessions() {
+		s, ok := session.(*PFCPSession)
+		if !ok || s.association != api.PFCPAssociationInterface(e.provisioning) {
+			continue
+		}
+		if err := e.unregisterPFCPSession(s); err != nil {
+			return unregistered, fmt.Errorf("Cannot remove provisioned session: %w", err)
+		}
+		unregistered = append(unregistered, s)
+	}
+	return unregistered, nil
+}
+
+// Register again sessions unregistered by unregisterProvisionedSessions
+func (e *PFCPEntityUP) reregisterProvisionedSessions(sessions []*PFCPSession) {
+	for _, s := range sessions {
+		if err := s.register(); err != nil {
+			// the session cannot be used anymore
+			e.Logger().Error("Cannot restore provisioned session", slog.Any("error", err))
+			e.localResources.release(s)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	pfcp_networking "github.com/nextmn/go-pfcp-networking/pfcp"
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/nextmn/go-pfcp-networking/pfcptest"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// Returns a provisioning document with a session for each local SEID;
+// each session has a F-TEID allocated by the UP function
+func newProvisioningDocument(seids ...api.SEID) string {
+	var b strings.Builder
+	b.WriteString("node_id: 10.0.0.100\nsessions:\n")
+	for _, seid := range seids {
+		fmt.Fprintf(&b, `  - cp_fseid: {seid: %d, ipv4: 10.0.0.100}
+    local_seid: %d
+    pdrs:
+      - {pdr_id: 1, precedence: 1, pdi: {source_interface: Access, network_instance: internet, local_fteid: {v4: true, ch: true}}, far_id: 1}
+    fars:
+      - {far_id: 1, apply_action: [FORW], forwarding_parameters: {destination_interface: Core}}
+`, seid, seid)
+	}
+	return b.String()
+}
+
+// Returns a UP function with a TEID pool of n TEIDs, from 1
+func newProvisioningUP(t *testing.T, n uint32) (*pfcptest.FakeUP, *pfcp_networking.TEIDPool) {
+	t.Helper()
+	pool, err := pfcp_networking.NewTEIDPool(net.ParseIP(testUPNodeID), nil, 1, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, up := newTestFunctions(t, func(up *pfcp_networking.PFCPEntityUP) error {
+		return up.AddTEIDPool("internet", ie.SrcInterfaceAccess, pool)
+	})
+	return up, pool
+}
+
+// Checks local SEIDs of sessions of the entity and of its datapath
+func checkProvisionedSessions(t *testing.T, up *pfcptest.FakeUP, expected ...api.SEID) {
+	t.Helper()
+	seids := make([]api.SEID, 0)
+	for _, s := range up.Sessions() {
+		seid, err := s.LocalSEID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seids = append(seids, seid)
+	}
+	slices.Sort(seids)
+	if !slices.Equal(seids, expected) {
+		t.Errorf("got sessions %v, expected %v", seids, expected)
+	}
+	installed := up.Datapath().SEIDs()
+	slices.Sort(installed)
+	if !slices.Equal(installed, expected) {
+		t.Errorf("got sessions %v installed in datapath, expected %v", installed, expected)
+	}
+}
+
+// Returns TEIDs of F-TEIDs of sessions of the entity
+func sessionTEIDs(t *testing.T, up *pfcptest.FakeUP) []uint32 {
+	t.Helper()
+	teids := make([]uint32, 0)
+	for _, s := range up.Sessions() {
+		pdr, err := s.GetPDR(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pdi, err := pdr.PDIFields()
+		if err != nil {
+			t.Fatal(err)
+		}
+		teids = append(teids, pdi.LocalFTEID.TEID)
+	}
+	slices.Sort(teids)
+	return teids
+}
+
+// Checks TEIDs which are allocated
+func checkAllocatedTEIDs(t *testing.T, pool *pfcp_networking.TEIDPool, n uint32, allocated ...uint32) {
+	t.Helper()
+	for teid := uint32(1); teid <= n; teid++ {
+		err := pool.Reserve(teid)
+		if err == nil {
+			if err := pool.Release(teid); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if expected := slices.Contains(allocated, teid); expected && err == nil {
+			t.Errorf("TEID %d is not allocated", teid)
+		} else if !expected && err != nil {
+			t.Errorf("TEID %d is still allocated: %v", teid, err)
+		}
+	}
+}
+
+func TestProvisionSessions(t *testing.T) {
+	up, pool := newProvisioningUP(t, 3)
+	if err := up.Entity().ProvisionSessions(strings.NewReader(newProvisioningDocument(1000, 1001))); err != nil {
+		t.Fatal(err)
+	}
+	checkProvisionedSessions(t, up, 1000, 1001)
+
+	// previous sessions are replaced, and their TEIDs are released
+	if err := up.Entity().ProvisionSessions(strings.NewReader(newProvisioningDocument(2000))); err != nil {
+		t.Fatal(err)
+	}
+	checkProvisionedSessions(t, up, 2000)
+	checkAllocatedTEIDs(t, pool, 3, sessionTEIDs(t, up)...)
+}
+
+func TestProvisionSessionsMalformed(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{name: "not YAML", doc: "sessions: [", err: "Cannot read provisioned sessions: yaml: line 1: did not find expected node content"},
+		{name: "unknown field", doc: "node_id: 10.0.0.100\nsession: []\n", err: `Cannot read provisioned sessions: json: unknown field "session"`},
+		{name: "missing Node ID", doc: "sessions: []\n", err: "Cannot read provisioned sessions: Node ID is missing"},
+		{name: "missing CP F-SEID", doc: "node_id: 10.0.0.100\nsessions: [{pdrs: [], fars: []}]\n", err: "Cannot read provisioned sessions: Session 0: CP F-SEID is missing"},
+		{name: "missing PDR ID", doc: strings.Replace(newProvisioningDocument(2000), "pdr_id: 1, ", "", 1), err: "Cannot read provisioned sessions: Session 0: PDR ID is missing"},
+		{name: "local SEID used twice", doc: newProvisioningDocument(2000, 2000), err: "Cannot read provisioned sessions: Session 1: local SEID 2000 is used twice"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			up, pool := newProvisioningUP(t, 3)
+			if err := up.Entity().ProvisionSessions(strings.NewReader(newProvisioningDocument(1000, 1001))); err != nil {
+				t.Fatal(err)
+			}
+			teids := sessionTEIDs(t, up)
+			err := up.Entity().ProvisionSessions(strings.NewReader(tc.doc))
+			if err == nil {
+				t.Fatal("malformed document has been provisioned")
+			}
+			if err.Error() != tc.err {
+				t.Errorf("got error %q, expected %q", err, tc.err)
+			}
+			checkProvisionedSessions(t, up, 1000, 1001)
+			if got := sessionTEIDs(t, up); !slices.Equal(got, teids) {
+				t.Errorf("got TEIDs %v, expected %v", got, teids)
+			}
+			checkAllocatedTEIDs(t, pool, 3, teids...)
+		})
+	}
+}
+
+// When a session of the document cannot be established, sessions of the document already established are removed,
+// and previous sessions are registered again with their resources
+func TestProvisionSessionsFailure(t *testing.T) {
+	up, pool := newProvisioningUP(t, 3)
+	if err := up.Entity().ProvisionSessions(strings.NewReader(newProvisioningDocument(1000, 1001))); err != nil {
+		t.Fatal(err)
+	}
+	teids := sessionTEIDs(t, up)
+	// TEIDs are kept for previous sessions: the second session gets no TEID
+	if err := up.Entity().ProvisionSessions(strings.NewReader(newProvisioningDocument(2000, 2001))); err == nil {
+		t.Fatal("sessions have been provisioned without TEID")
+	}
+	checkProvisionedSessions(t, up, 1000, 1001)
+	if got := sessionTEIDs(t, up); !slices.Equal(got, teids) {
+		t.Errorf("got TEIDs %v, expected %v", got, teids)
+	}
+	checkAllocatedTEIDs(t, pool, 3, teids...)
+	// previous sessions are still provisioned sessions, and are replaced by the next document
+	if err := up.Entity().ProvisionSessions(strings.NewReader(newProvisioningDocument(2000))); err != nil {
+		t.Fatal(err)
+	}
+	checkProvisionedSessions(t, up, 2000)
+	checkAllocatedTEIDs(t, pool, 3, sessionTEIDs(t, up)...)
+}
+
+func TestReloadProvisionedSessionsOnSIGHUP(t *testing.T) {
+	up, _ := newProvisioningUP(t, 3)
+	path := filepath.Join(t.TempDir(), "sessions.yaml")
+	if err := os.WriteFile(path, []byte(newProvisioningDocument(1000)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	up.Entity().ReloadProvisionedSessionsOnSIGHUP(ctx, path)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(up.Sessions()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("sessions have not been provisioned")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	checkProvisionedSessions(t, up, 1000)
+}
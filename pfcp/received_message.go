@@ -6,7 +6,9 @@
 package pfcp_networking
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net"
 
 	"github.com/nextmn/go-pfcp-networking/pfcp/api"
@@ -18,6 +20,35 @@ type ReceivedMessage struct {
 	message.Message
 	SenderAddr net.Addr
 	Entity     api.PFCPEntityInterface
+	// PFCP Association with the sender of the message,
+	// nil if the sender has no established association
+	Association api.PFCPAssociationInterface
+	// For PFCP Session related messages, PFCP Session identified by the SEID
+	// of the message header, nil if there is no such session
+	Session   api.PFCPSessionInterface
+	ctx       context.Context
+	responses *responseCache // nil if the Response must not be kept
+	// Resources allocated for sessions, nil if they are not tracked
+	localResources *localResources
+}
+
+// Returns the context of the message
+func (receivedMessage *ReceivedMessage) Context() context.Context {
+	if receivedMessage.ctx == nil {
+		return context.Background()
+	}
+	return receivedMessage.ctx
+}
+
+// Returns a copy of the message with its context changed to ctx
+func (receivedMessage ReceivedMessage) WithContext(ctx context.Context) ReceivedMessage {
+	receivedMessage.ctx = ctx
+	return receivedMessage
+}
+
+// Returns the logger of the entity, with attributes describing the message
+func (receivedMessage *ReceivedMessage) Logger() *slog.Logger {
+	return receivedMessage.Entity.Logger().With(slog.Any(LogKeyPeer, receivedMessage.SenderAddr)).With(logAttrsMessage(receivedMessage.Message)...)
 }
 
 func (receivedMessage *ReceivedMessage) ReplyTo(responseMessage message.Message) error {
@@ -38,5 +69,14 @@ func (receivedMessage *ReceivedMessage) ReplyTo(responseMessage message.Message)
 	if err := receivedMessage.Entity.SendTo(b, receivedMessage.SenderAddr); err != nil {
 		return err
 	}
+	if receivedMessage.responses != nil {
+		receivedMessage.responses.setResponse(receivedMessage.SenderAddr, receivedMessage.Sequence(), b)
+	}
+	metrics := receivedMessage.Entity.Metrics()
+	metrics.MessageSent(responseMessage.MessageType())
+	if cause, err := causeFromBytes(b); err == nil {
+		metrics.ResponseSent(responseMessage.MessageType(), cause)
+		spanFromContext(receivedMessage.Context()).SetAttributes(api.SpanAttribute{Key: api.SpanAttrCause, Value: uint64(cause)})
+	}
 	return nil
 }
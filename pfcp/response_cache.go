@@ -0,0 +1,110 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+)
+
+// Retransmitted Requests must not be handled twice (TS 29.244, section 6.4):
+// the Response to the original Request is sent again instead.
+// Responses are kept as long as the sender may retransmit the Request.
+
+type cachedResponse struct {
+	key      string
+	request  []byte
+	response []byte // nil while the Request is being handled
+	expires  time.Time
+}
+
+type responseCache struct {
+	mu       sync.Mutex
+	clock    api.ClockInterface
+	lifetime time.Duration
+	entries  map[string]*list.Element
+	// Entries sorted by expiration time: all entries have the same lifetime,
+	// so they expire in the order they are added
+	byExpiration *list.List
+}
+
+func newResponseCache(clock api.ClockInterface, lifetime time.Duration) *responseCache {
+	return &responseCache{
+		mu:           sync.Mutex{},
+		clock:        clock,
+		lifetime:     lifetime,
+		entries:      make(map[string]*list.Element),
+		byExpiration: list.New(),
+	}
+}
+
+func responseCacheKey(sender net.Addr, sequenceNumber uint32) string {
+	return fmt.Sprintf("%s/%d", sender, sequenceNumber)
+}
+
+// Register a Request before handling it.
+// If the same Request has already been received, returns true and its Response
+// (nil if the Response has not yet been sent).
+func (c *responseCache) lookupOrAdd(sender net.Addr, sequenceNumber uint32, request []byte) (duplicate bool, response []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	c.removeExpired(now)
+	key := responseCacheKey(sender, sequenceNumber)
+	if elem, exists := c.entries[key]; exists {
+		if e := elem.Value.(*cachedResponse); bytes.Equal(e.request, request) {
+			return true, e.response
+		}
+		// same sequence number, but another Request
+		c.remove(elem)
+	}
+	c.entries[key] = c.byExpiration.PushBack(&cachedResponse{
+		key:     key,
+		request: request,
+		expires: now.Add(c.lifetime),
+	})
+	return false, nil
+}
+
+// Remove entries expired at now, starting with the oldest one
+func (c *responseCache) removeExpired(now time.Time) {
+	for elem := c.byExpiration.Front(); elem != nil; elem = c.byExpiration.Front() {
+		if !now.After(elem.Value.(*cachedResponse).expires) {
+			return
+		}
+		c.remove(elem)
+	}
+}
+
+func (c *responseCache) remove(elem *list.Element) {
+	delete(c.entries, elem.Value.(*cachedResponse).key)
+	c.byExpiration.Remove(elem)
+}
+
+// Store the Response sent to a Request
+func (c *responseCache) setResponse(sender net.Addr, sequenceNumber uint32, response []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, exists := c.entries[responseCacheKey(sender, sequenceNumber)]; exists {
+		elem.Value.(*cachedResponse).response = response
+	}
+}
+
+// Forget a Request which has not been answered,
+// so its retransmissions are handled again
+func (c *responseCache) forgetUnanswered(sender net.Addr, sequenceNumber uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, exists := c.entries[responseCacheKey(sender, sequenceNumber)]; exists && elem.Value.(*cachedResponse).response == nil {
+		c.remove(elem)
+	}
+}
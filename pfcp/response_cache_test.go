@@ -0,0 +1,110 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcptransport"
+)
+
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+func TestResponseCache(t *testing.T) {
+	const lifetime = 2 * time.Second
+	clock := &manualClock{now: time.Unix(0, 0)}
+	c := newResponseCache(clock, lifetime)
+	sender := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8805}
+	other := &net.UDPAddr{IP: net.ParseIP("10.0.0.3"), Port: 8805}
+
+	if duplicate, _ := c.lookupOrAdd(sender, 1, []byte("req1")); duplicate {
+		t.Fatal("first Request reported as duplicate")
+	}
+	if duplicate, res := c.lookupOrAdd(sender, 1, []byte("req1")); !duplicate || res != nil {
+		t.Fatalf("unanswered retransmission: got %t %q", duplicate, res)
+	}
+	c.setResponse(sender, 1, []byte("res1"))
+	if duplicate, res := c.lookupOrAdd(sender, 1, []byte("req1")); !duplicate || string(res) != "res1" {
+		t.Fatalf("answered retransmission: got %t %q", duplicate, res)
+	}
+	if duplicate, _ := c.lookupOrAdd(other, 1, []byte("req1")); duplicate {
+		t.Fatal("Request of another sender reported as duplicate")
+	}
+
+	// a new Request reusing a sequence number replaces the previous one
+	if duplicate, _ := c.lookupOrAdd(sender, 1, []byte("req2")); duplicate {
+		t.Fatal("new Request with the same sequence number reported as duplicate")
+	}
+	if duplicate, res := c.lookupOrAdd(sender, 1, []byte("req2")); !duplicate || res != nil {
+		t.Fatalf("retransmission of the new Request: got %t %q", duplicate, res)
+	}
+
+	// unanswered Requests can be forgotten, answered ones are kept
+	c.lookupOrAdd(sender, 2, []byte("req3"))
+	c.forgetUnanswered(sender, 2)
+	if duplicate, _ := c.lookupOrAdd(sender, 2, []byte("req3")); duplicate {
+		t.Fatal("forgotten Request reported as duplicate")
+	}
+	c.setResponse(sender, 2, []byte("res3"))
+	c.forgetUnanswered(sender, 2)
+	if duplicate, _ := c.lookupOrAdd(sender, 2, []byte("req3")); !duplicate {
+		t.Fatal("answered Request has been forgotten")
+	}
+
+	// entries expire in order
+	clock.now = clock.now.Add(lifetime / 2)
+	c.lookupOrAdd(sender, 3, []byte("req4"))
+	clock.now = clock.now.Add(lifetime/2 + time.Millisecond)
+	if duplicate, _ := c.lookupOrAdd(sender, 2, []byte("req3")); duplicate {
+		t.Fatal("expired Request reported as duplicate")
+	}
+	if duplicate, _ := c.lookupOrAdd(sender, 3, []byte("req4")); !duplicate {
+		t.Fatal("Request expired too early")
+	}
+	if len(c.entries) != c.byExpiration.Len() {
+		t.Fatalf("%d entries, but %d in expiration list", len(c.entries), c.byExpiration.Len())
+	}
+	clock.now = clock.now.Add(lifetime + time.Millisecond)
+	c.lookupOrAdd(other, 4, []byte("req5"))
+	if len(c.entries) != 1 || c.byExpiration.Len() != 1 {
+		t.Fatalf("expired entries are kept: %d entries, %d in expiration list", len(c.entries), c.byExpiration.Len())
+	}
+}
+
+func TestSetResponseCacheLifetime(t *testing.T) {
+	e := NewPFCPEntityUP("10.0.0.2")
+	if err := e.SetTransport(pfcptransport.NewMemoryNetwork()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetResponseCacheLifetime(0); err == nil {
+		t.Error("null lifetime has been accepted")
+	}
+	if err := e.SetResponseCacheLifetime(10 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	if e.responses.lifetime != 10*time.Second {
+		t.Errorf("got lifetime %s, expected %s", e.responses.lifetime, 10*time.Second)
+	}
+	if err := e.SetResponseCacheLifetime(time.Second); err == nil {
+		t.Error("lifetime of a started entity has been changed")
+	}
+}
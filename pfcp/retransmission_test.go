@@ -0,0 +1,221 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	pfcp_networking "github.com/nextmn/go-pfcp-networking/pfcp"
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/nextmn/go-pfcp-networking/pfcpclock"
+	"github.com/nextmn/go-pfcp-networking/pfcptest"
+	"github.com/nextmn/go-pfcp-networking/pfcptransport"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Sessions are established through an impaired network: Requests and Responses are lost,
+// duplicated and reordered, so the UP function receives retransmitted Requests.
+func TestEstablishmentOverImpairedTransport(t *testing.T) {
+	const sessions = 20
+	for _, tc := range []struct {
+		name        string
+		impairment  pfcptransport.Impairment
+		retransmits bool // Requests are received more than once
+	}{
+		{name: "lossy", impairment: pfcptransport.Impairment{Loss: 0.3, Seed: 1}, retransmits: true},
+		{name: "duplicating", impairment: pfcptransport.Impairment{Duplication: 0.5, Seed: 2}, retransmits: true},
+		{name: "reordering", impairment: pfcptransport.Impairment{Reordering: 0.3, Delay: time.Millisecond, Jitter: 5 * time.Millisecond, Seed: 3}},
+		{name: "all", impairment: pfcptransport.Impairment{Loss: 0.3, Duplication: 0.5, Reordering: 0.2, Delay: time.Millisecond, Jitter: 5 * time.Millisecond, Seed: 4}, retransmits: true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			// timers of entities and delays of the network use the same manual clock
+			clock := pfcpclock.NewManual(time.Unix(0, 0))
+			network := pfcptransport.NewMemoryNetwork()
+			impairment := tc.impairment
+			impairment.Clock = clock
+			options := pfcptest.Options{
+				Transport: pfcptransport.NewImpairedTransport(network, impairment),
+				Clock:     clock,
+				Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			up, err := pfcptest.NewFakeUP("10.0.0.2", options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := up.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer up.Close()
+			cp, err := pfcptest.NewFakeCP("10.0.0.1", options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cp.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer cp.Close()
+			barrier := newBarrier(t, up.Entity(), network)
+			stop := drive(clock)
+			defer stop()
+			associate(t, cp, "10.0.0.2")
+
+			var mu sync.Mutex
+			established := make(map[api.SEID]struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < sessions; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					session, res, err := cp.EstablishSession("10.0.0.2",
+						ie.NewCreatePDR(ie.NewPDRID(1), ie.NewPrecedence(1), ie.NewPDI(ie.NewSourceInterface(ie.SrcInterfaceAccess)), ie.NewFARID(1)),
+						ie.NewCreateFAR(ie.NewFARID(1), ie.NewApplyAction(2), ie.NewForwardingParameters(ie.NewDestinationInterface(ie.DstInterfaceCore))),
+					)
+					var timeout *pfcp_networking.TimeoutError
+					switch {
+					case err != nil && !errors.As(err, &timeout):
+						t.Errorf("transaction failed without timeout: %s", err)
+					case err == nil && session == nil:
+						cause, _ := res.Cause.Cause()
+						t.Errorf("Session Establishment Request rejected with cause %d", cause)
+					case err == nil:
+						mu.Lock()
+						established[session.LocalSEID] = struct{}{}
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			// let the UP function handle Requests still in flight
+			for handled := -1; handled != len(up.Recorder().MessagesOfType(message.MsgTypeSessionEstablishmentRequest)); {
+				handled = len(up.Recorder().MessagesOfType(message.MsgTypeSessionEstablishmentRequest))
+				barrier()
+			}
+			stop()
+
+			upSessions := make(map[api.SEID]int)
+			for _, s := range up.Sessions() {
+				seid, err := s.RemoteSEID()
+				if err != nil {
+					t.Fatal(err)
+				}
+				upSessions[seid]++
+			}
+			for seid, n := range upSessions {
+				if n > 1 {
+					t.Errorf("%d sessions created for CP SEID %d", n, seid)
+				}
+			}
+			for seid := range established {
+				if upSessions[seid] == 0 {
+					t.Errorf("session with CP SEID %d is established, but missing in the UP function", seid)
+				}
+			}
+			if n := len(up.Datapath().SEIDs()); n != len(upSessions) {
+				t.Errorf("%d sessions in the datapath, but %d in the UP function", n, len(upSessions))
+			}
+			requests := len(up.Recorder().MessagesOfType(message.MsgTypeSessionEstablishmentRequest))
+			if tc.retransmits && requests <= len(upSessions) {
+				t.Errorf("no retransmitted Request has been received (%d Requests for %d sessions)", requests, len(upSessions))
+			}
+		})
+	}
+}
+
+// Associate with a UP function, retrying when the Association Setup Request times out
+func associate(t *testing.T, cp *pfcptest.FakeCP, upNodeID string) {
+	t.Helper()
+	var err error
+	for i := 0; i < 10; i++ {
+		var timeout *pfcp_networking.TimeoutError
+		if _, err = cp.Associate(upNodeID); err == nil || !errors.As(err, &timeout) {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Advance the clock until the returned function is called,
+// letting other goroutines run between each step
+func drive(clock *pfcpclock.Manual) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			clock.Advance(time.Millisecond)
+			runtime.Gosched()
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// Returns a function sending a Heartbeat Request to the entity, bypassing impairments,
+// and waiting until it has been handled: as the entity handles messages in order,
+// datagrams it received before have been handled too.
+func newBarrier(t *testing.T, entity *pfcp_networking.PFCPEntityUP, network *pfcptransport.MemoryNetwork) func() {
+	t.Helper()
+	conn, err := network.ListenUDP(&net.UDPAddr{IP: net.ParseIP("10.0.0.254"), Port: 8805})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	handled := make(chan struct{}, 1)
+	if err := entity.AddMessageTypeMiddleware(message.MsgTypeHeartbeatRequest, func(next pfcp_networking.PFCPMessageHandler) pfcp_networking.PFCPMessageHandler {
+		return func(msg pfcp_networking.ReceivedMessage) error {
+			err := next(msg)
+			if msg.SenderAddr.String() == conn.LocalAddr().String() {
+				handled <- struct{}{}
+			}
+			return err
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	req := message.NewHeartbeatRequest(1, ie.NewRecoveryTimeStamp(time.Unix(0, 0)), nil)
+	b := make([]byte, req.MarshalLen())
+	if err := req.MarshalTo(b); err != nil {
+		t.Fatal(err)
+	}
+	nodeID, err := entity.NodeID().NodeID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		t.Helper()
+		if _, err := conn.WriteTo(b, &net.UDPAddr{IP: net.ParseIP(nodeID), Port: 8805}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-handled:
+		case <-time.After(5 * time.Second):
+			// the real clock only bounds a test which would otherwise hang
+			t.Fatal("Heartbeat Request has not been handled")
+		}
+	}
+}
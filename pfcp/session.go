@@ -7,7 +7,7 @@ package pfcp_networking
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"sync"
 
@@ -59,26 +59,65 @@ func newEstablishedPFCPSession(association api.PFCPAssociationInterface, fseid,
 	}
 	if fseid != nil {
 		fseidFields, err := fseid.FSEID()
-		s.localFseid = ie.NewFSEID(fseidFields.SEID, fseidFields.IPv4Address, fseidFields.IPv6Address)
 		if err != nil {
 			return nil, err
 		}
+		s.localFseid = ie.NewFSEID(fseidFields.SEID, fseidFields.IPv4Address, fseidFields.IPv6Address)
 	}
 	if rfseid != nil {
 		rfseidFields, err := rfseid.FSEID()
-		s.remoteFseid = ie.NewFSEID(rfseidFields.SEID, rfseidFields.IPv4Address, rfseidFields.IPv6Address)
 		if err != nil {
 			return nil, err
 		}
+		s.remoteFseid = ie.NewFSEID(rfseidFields.SEID, rfseidFields.IPv4Address, rfseidFields.IPv6Address)
 	}
 	if err := s.Setup(); err != nil {
 		return nil, err
 	}
-	// Add to SessionFSEIDMap of LocalEntity
-	s.association.LocalEntity().AddEstablishedPFCPSession(&s)
+	if err := s.register(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Create an established PFCPSession from a snapshot.
+// No message is sent to the peer.
+func restoreEstablishedPFCPSession(association api.PFCPAssociationInterface, fseid, rfseid *ie.IE, pdrs api.PDRMapInterface, fars api.FARMapInterface) (*PFCPSession, error) {
+	s := PFCPSession{
+		isEstablished: true,
+		association:   association,
+		localFseid:    fseid,
+		remoteFseid:   rfseid,
+		pdr:           pdrs,
+		far:           fars,
+		atomicMu:      sync.RWMutex{},
+	}
+	if err := s.register(); err != nil {
+		return nil, err
+	}
 	return &s, nil
 }
 
+// Install rules of an established session into the datapath,
+// and add the session to the LocalEntity
+func (s *PFCPSession) register() error {
+	// Install rules before the session is visible
+	datapath := s.association.LocalEntity().Datapath()
+	if datapath != nil {
+		if err := datapath.InstallSession(s, s.pdr, s.far); err != nil {
+			return newDatapathError(err)
+		}
+	}
+	// Add to SessionFSEIDMap of LocalEntity
+	if err := s.association.LocalEntity().AddEstablishedPFCPSession(s); err != nil {
+		if datapath != nil {
+			datapath.RemoveSession(s)
+		}
+		return err
+	}
+	return nil
+}
+
 // Get local F-SEID of this session
 // This value should be used when a session related message is received.
 func (s *PFCPSession) LocalFSEID() *ie.IE {
@@ -98,8 +137,13 @@ func (s *PFCPSession) LocalSEID() (api.SEID, error) {
 // Get IP Address part of local F-SEID
 // This value should be used when a session related message is received.
 func (s *PFCPSession) LocalIPAddress() (net.IP, error) {
+	return fseidIPAddress(s.localFseid)
+}
+
+// Returns the IP Address identifying sessions with this local F-SEID
+func fseidIPAddress(f *ie.IE) (net.IP, error) {
 	// XXX: handle case where both HasIPv6 and HasIPv4 are set
-	fseid, err := s.localFseid.FSEID()
+	fseid, err := f.FSEID()
 	if err != nil {
 		return nil, err
 	}
@@ -166,6 +210,10 @@ func (s *PFCPSession) ForeachUnsortedPDR(f func(pdr api.PDRInterface) error) err
 	return s.pdr.Foreach(f)
 }
 
+func (s *PFCPSession) ForeachUnsortedFAR(f func(far api.FARInterface) error) error {
+	return s.far.Foreach(f)
+}
+
 // Get FAR associated with this FARID
 func (s *PFCPSession) GetFAR(farid api.FARID) (api.FARInterface, error) {
 	// lock is not necessary, as it is to the caller to RLock and RUnlock
@@ -204,32 +252,93 @@ func (s *PFCPSession) AddUpdatePDRsFARs(createpdrs api.PDRMapInterface, createfa
 		return err
 	}
 	// Performing for real
+	// rules replaced or created are restored or removed if a step fails
+	undo := make([]func(), 0)
+	revert := func(err error) error {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+		return err
+	}
 	// deletions
 
 	// updates
 	if err := updatepdrs.Foreach(func(pdr api.PDRInterface) error {
-		return s.pdr.Update(pdr)
+		id, err := pdr.ID()
+		if err != nil {
+			return err
+		}
+		previous, err := s.pdr.Get(id)
+		if err != nil {
+			return err
+		}
+		if err := s.pdr.Update(pdr); err != nil {
+			return err
+		}
+		undo = append(undo, func() { s.pdr.Update(previous) })
+		return nil
 	}); err != nil {
-		return err
+		return revert(err)
 	}
 	if err := updatefars.Foreach(func(far api.FARInterface) error {
-		return s.far.Update(far)
+		id, err := far.ID()
+		if err != nil {
+			return err
+		}
+		previous, err := s.far.Get(id)
+		if err != nil {
+			return err
+		}
+		if err := s.far.Update(far); err != nil {
+			return err
+		}
+		undo = append(undo, func() { s.far.Update(previous) })
+		return nil
 	}); err != nil {
-		return err
+		return revert(err)
 	}
 
 	// creations
+	nbPDRs := 0
 	if err := createpdrs.Foreach(func(pdr api.PDRInterface) error {
-		return s.pdr.Add(pdr)
+		id, err := pdr.ID()
+		if err != nil {
+			return err
+		}
+		if err := s.pdr.Add(pdr); err != nil {
+			return err
+		}
+		nbPDRs++
+		undo = append(undo, func() { s.pdr.Remove(id) })
+		return nil
 	}); err != nil {
-		return err
+		return revert(err)
 	}
+	nbFARs := 0
 	if err := createfars.Foreach(func(far api.FARInterface) error {
-		return s.far.Add(far)
+		id, err := far.ID()
+		if err != nil {
+			return err
+		}
+		if err := s.far.Add(far); err != nil {
+			return err
+		}
+		nbFARs++
+		undo = append(undo, func() { s.far.Remove(id) })
+		return nil
 	}); err != nil {
-		return err
+		return revert(err)
 	}
 
+	// Datapath is updated last: the session is reverted if it fails
+	if datapath := s.association.LocalEntity().Datapath(); datapath != nil {
+		if err := datapath.UpdateSession(s, createpdrs, createfars, updatepdrs, updatefars); err != nil {
+			return revert(newDatapathError(err))
+		}
+	}
+	s.association.LocalEntity().Metrics().PDRsChanged(nbPDRs)
+	s.association.LocalEntity().Metrics().FARsChanged(nbFARs)
+
 	return nil
 	// TODO: if isControlPlane() -> send the Session Modification Request
 }
@@ -277,9 +386,19 @@ func (s *PFCPSession) Setup() error {
 		}
 		ser, ok := resp.(*message.SessionEstablishmentResponse)
 		if !ok {
-			log.Printf("got unexpected message: %s\n", resp.MessageTypeName())
+			s.association.LocalEntity().Logger().Warn("Got unexpected message", logAttrNodeID(LogKeyPeer, s.association.NodeID()), slog.String(LogKeyMessageType, resp.MessageTypeName()))
+			return fmt.Errorf("Unexpected response to Session Establishment Request")
+		}
+		// rejections are returned by Send as a CauseError
+		if ser.Cause == nil {
+			return fmt.Errorf("Cause is missing in Session Establishment Response")
+		}
+		if _, err := ser.Cause.Cause(); err != nil {
+			return err
+		}
+		if ser.UPFSEID == nil {
+			return fmt.Errorf("UP F-SEID is missing in Session Establishment Response")
 		}
-
 		remoteFseidFields, err := ser.UPFSEID.FSEID()
 		if err != nil {
 			return err
@@ -290,5 +409,4 @@ func (s *PFCPSession) Setup() error {
 	default:
 		return fmt.Errorf("Local PFCP entity is not a CP or a UP function")
 	}
-	return nil
 }
@@ -6,7 +6,6 @@
 package pfcp_networking
 
 import (
-	"log"
 	"sync"
 
 	"github.com/nextmn/go-pfcp-networking/pfcp/api"
@@ -32,6 +31,19 @@ func (pool *SessionIDPool) GetNext() api.SEID {
 	defer pool.muSessionID.Unlock()
 	id := pool.currentSessionID
 	pool.currentSessionID = id + 1
-	log.Println("Returning next Session ID:", id)
 	return id
 }
+
+// Returns the next id, without using it
+func (pool *SessionIDPool) peekNext() api.SEID {
+	pool.muSessionID.Lock()
+	defer pool.muSessionID.Unlock()
+	return pool.currentSessionID
+}
+
+// Set the next id returned by GetNext
+func (pool *SessionIDPool) setNext(id api.SEID) {
+	pool.muSessionID.Lock()
+	defer pool.muSessionID.Unlock()
+	pool.currentSessionID = id
+}
@@ -38,11 +38,41 @@ func (sm *SessionsMap) Add(session api.PFCPSessionInterface) error {
 	if _, exists := sm.sessions[localIP]; !exists {
 		sm.sessions[localIP] = make(sessionsMapSEID, 0)
 	}
+	if _, exists := sm.sessions[localIP][localSEID]; exists {
+		return fmt.Errorf("Session with local SEID %d already exists", localSEID)
+	}
 	// Add session
 	sm.sessions[localIP][localSEID] = session
 	return nil
 }
 
+// Remove a session from the map
+func (sm *SessionsMap) Remove(session api.PFCPSessionInterface) error {
+	localIPAddr, err := session.LocalIPAddress() // XXX: handle case where both ip6 and ip4 are set
+	if err != nil {
+		return err
+	}
+	localIP := localIPAddr.String()
+	localSEID, err := session.LocalSEID()
+	if err != nil {
+		return err
+	}
+	sm.muSessions.Lock()
+	defer sm.muSessions.Unlock()
+	sessions, exists := sm.sessions[localIP]
+	if !exists {
+		return fmt.Errorf("Session not found: wrong IP")
+	}
+	if _, exists := sessions[localSEID]; !exists {
+		return fmt.Errorf("Session not found: wrong SEID")
+	}
+	delete(sessions, localSEID)
+	if len(sessions) == 0 {
+		delete(sm.sessions, localIP)
+	}
+	return nil
+}
+
 // Create a new SessionMap
 func NewSessionsMap() *SessionsMap {
 	return &SessionsMap{
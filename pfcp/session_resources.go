@@ -0,0 +1,393 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// Resources allocated by the UP function for a session are
+// F-TEIDs, when the CP function sets the CH flag (TS 29.244, section 5.2.3.1),
+// and UE IP addresses, when it sets the CHV4 or CHV6 flag (TS 29.244, section 5.21).
+// They are released when the session is removed, or when no PDR uses them anymore.
+
+type teidAllocation struct {
+	networkInstance string
+	sourceInterface uint8
+	teid            uint32
+}
+
+type sessionResources struct {
+	mu sync.Mutex // Snapshot may read resources while a Request is handled
+
+	teidAllocator api.TEIDAllocatorInterface
+	ueipAllocator api.UEIPAllocatorInterface
+
+	teids     []teidAllocation
+	chooseIDs map[uint8]*ie.FTEIDFields // F-TEIDs shared by PDRs with the same CHOOSE ID
+	// A UE has a single address (or prefix) per Network Instance,
+	// shared by every PDR of the session
+	ipv4 map[string]net.IP
+	ipv6 map[string]*net.IPNet
+}
+
+func newSessionResources(teidAllocator api.TEIDAllocatorInterface, ueipAllocator api.UEIPAllocatorInterface) *sessionResources {
+	return &sessionResources{
+		mu:            sync.Mutex{},
+		teidAllocator: teidAllocator,
+		ueipAllocator: ueipAllocator,
+		teids:         make([]teidAllocation, 0),
+		chooseIDs:     make(map[uint8]*ie.FTEIDFields),
+		ipv4:          make(map[string]net.IP),
+		ipv6:          make(map[string]*net.IPNet),
+	}
+}
+
+// Allocate F-TEIDs and UE IP addresses requested by PDRs, and replace them in pdrs.
+// Returns the IEs to send to the CP function, built with newIE (ie.NewCreatedPDR for Create PDRs,
+// ie.NewUpdatedPDR for Update PDRs), and a function releasing resources allocated by this call
+// (to be used if the Request is finally rejected).
+func (s *sessionResources) allocate(pdrs *PDRMap, newIE func(ies ...*ie.IE) *ie.IE) (created []*ie.IE, undo func(), err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	firstTEID := len(s.teids)
+	newChooseIDs := make([]uint8, 0)
+	newIPv4 := make([]string, 0)
+	newIPv6 := make([]string, 0)
+	undoLocked := func() {
+		for _, a := range s.teids[firstTEID:] {
+			s.teidAllocator.Release(a.networkInstance, a.sourceInterface, a.teid)
+		}
+		s.teids = s.teids[:firstTEID]
+		for _, chid := range newChooseIDs {
+			delete(s.chooseIDs, chid)
+		}
+		for _, ni := range newIPv4 {
+			s.ueipAllocator.ReleaseIPv4(ni, s.ipv4[ni])
+			delete(s.ipv4, ni)
+		}
+		for _, ni := range newIPv6 {
+			s.ueipAllocator.ReleaseIPv6Prefix(ni, s.ipv6[ni])
+			delete(s.ipv6, ni)
+		}
+	}
+	undo = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		undoLocked()
+	}
+	fail := func(err error) ([]*ie.IE, func(), error) {
+		undoLocked()
+		return nil, nil, err
+	}
+
+	created = make([]*ie.IE, 0)
+	ids := append([]api.PDRID{}, pdrs.GetSortedPDRIDs()...)
+	for _, id := range ids {
+		pdr, err := pdrs.Get(id)
+		if err != nil {
+			return fail(err)
+		}
+		pdi, err := pdr.PDIFields()
+		if err != nil {
+			continue
+		}
+		createdIEs := make([]*ie.IE, 0)
+
+		if pdi.LocalFTEID != nil && pdi.LocalFTEID.HasCh() {
+			fteid, isNew, err := s.chooseFTEID(pdi)
+			if err != nil {
+				return fail(err)
+			}
+			if isNew && pdi.LocalFTEID.HasChID() {
+				newChooseIDs = append(newChooseIDs, pdi.LocalFTEID.ChooseID)
+			}
+			b, err := fteid.Marshal()
+			if err != nil {
+				return fail(err)
+			}
+			createdIEs = append(createdIEs, ie.New(ie.FTEID, b))
+		}
+
+		if pdi.UEIPAddress != nil && pdi.UEIPAddress.Flags&(api.UEIPFlagCHV4|api.UEIPFlagCHV6) != 0 {
+			ni := pdi.NetworkInstance
+			_, hadIPv4 := s.ipv4[ni]
+			_, hadIPv6 := s.ipv6[ni]
+			ueip, err := s.chooseUEIPAddress(pdi)
+			// an IPv4 address may have been allocated even if the IPv6 prefix allocation failed
+			if _, hasIPv4 := s.ipv4[ni]; hasIPv4 && !hadIPv4 {
+				newIPv4 = append(newIPv4, ni)
+			}
+			if _, hasIPv6 := s.ipv6[ni]; hasIPv6 && !hadIPv6 {
+				newIPv6 = append(newIPv6, ni)
+			}
+			if err != nil {
+				return fail(err)
+			}
+			b, err := ueip.Marshal()
+			if err != nil {
+				return fail(err)
+			}
+			createdIEs = append(createdIEs, ie.New(ie.UEIPAddress, b))
+		}
+
+		if len(createdIEs) == 0 {
+			continue
+		}
+		pdiIEs, err := pdr.PDI()
+		if err != nil {
+			return fail(err)
+		}
+		precedence, err := pdr.Precedence()
+		if err != nil {
+			return fail(err)
+		}
+		farid, err := pdr.FARID()
+		if err != nil {
+			return fail(err)
+		}
+		if err := pdrs.Update(NewPDR(ie.NewPDRID(id), replacePDIIEs(pdiIEs, createdIEs), ie.NewPrecedence(precedence), ie.NewFARID(farid), pdr.OuterHeaderRemoval())); err != nil {
+			return fail(err)
+		}
+		created = append(created, newIE(append([]*ie.IE{ie.NewPDRID(id)}, createdIEs...)...))
+	}
+	return created, undo, nil
+}
+
+// Returns a PDI IE made of pdi, where IEs of the type of one of replacements are replaced by it.
+// Other IEs are kept as received (SDF Filters are not rewritten).
+func replacePDIIEs(pdi []*ie.IE, replacements []*ie.IE) *ie.IE {
+	ies := make([]*ie.IE, 0, len(pdi))
+	for _, i := range pdi {
+		for _, r := range replacements {
+			if r.Type == i.Type {
+				i = r
+				break
+			}
+		}
+		ies = append(ies, i)
+	}
+	return ie.NewPDI(ies...)
+}
+
+// Returns the F-TEID of a PDI with the CH flag; isNew is true when it has just been allocated
+func (s *sessionResources) chooseFTEID(pdi *api.PDIFields) (fteid *ie.FTEIDFields, isNew bool, err error) {
+	if pdi.LocalFTEID.HasChID() {
+		if fteid, exists := s.chooseIDs[pdi.LocalFTEID.ChooseID]; exists {
+			return fteid, false, nil
+		}
+	}
+	if s.teidAllocator == nil {
+		return nil, false, newValidationError(fmt.Errorf("F-TEID allocation is not supported"), ie.CauseRuleCreationModificationFailure, ie.FTEID)
+	}
+	fteid, err = s.teidAllocator.Allocate(pdi.NetworkInstance, pdi.SourceInterface, pdi.LocalFTEID.HasIPv4(), pdi.LocalFTEID.HasIPv6())
+	if err != nil {
+		return nil, false, allocationError(err, ie.FTEID)
+	}
+	s.teids = append(s.teids, teidAllocation{
+		networkInstance: pdi.NetworkInstance,
+		sourceInterface: pdi.SourceInterface,
+		teid:            fteid.TEID,
+	})
+	if pdi.LocalFTEID.HasChID() {
+		s.chooseIDs[pdi.LocalFTEID.ChooseID] = fteid
+	}
+	return fteid, true, nil
+}
+
+// Returns the UE IP Address of a PDI with the CHV4 and/or CHV6 flag
+func (s *sessionResources) chooseUEIPAddress(pdi *api.PDIFields) (*ie.UEIPAddressFields, error) {
+	req := pdi.UEIPAddress
+	ni := pdi.NetworkInstance
+	ueip := &ie.UEIPAddressFields{
+		Flags:            req.Flags &^ (api.UEIPFlagCHV4 | api.UEIPFlagCHV6 | api.UEIPFlagIPv6D),
+		IPv4Address:      req.IPv4Address,
+		IPv6Address:      req.IPv6Address,
+		IPv6PrefixLength: req.IPv6PrefixLength,
+	}
+	if req.Flags&(api.UEIPFlagCHV4|api.UEIPFlagCHV6) != 0 && s.ueipAllocator == nil {
+		return nil, newValidationError(fmt.Errorf("UE IP address allocation is not supported"), ie.CauseRuleCreationModificationFailure, ie.UEIPAddress)
+	}
+	if req.Flags&api.UEIPFlagCHV4 != 0 {
+		addr, exists := s.ipv4[ni]
+		if !exists {
+			var err error
+			addr, err = s.ueipAllocator.AllocateIPv4(ni)
+			if err != nil {
+				return nil, allocationError(err, ie.UEIPAddress)
+			}
+			s.ipv4[ni] = addr
+		}
+		ueip.Flags |= api.UEIPFlagV4
+		ueip.IPv4Address = addr
+	}
+	if req.Flags&api.UEIPFlagCHV6 != 0 {
+		prefix, exists := s.ipv6[ni]
+		if !exists {
+			var err error
+			prefix, err = s.ueipAllocator.AllocateIPv6Prefix(ni)
+			if err != nil {
+				return nil, allocationError(err, ie.UEIPAddress)
+			}
+			s.ipv6[ni] = prefix
+		}
+		ones, _ := prefix.Mask.Size()
+		ueip.Flags |= api.UEIPFlagV6 | api.UEIPFlagIP6PL
+		ueip.IPv6Address = prefix.IP
+		ueip.IPv6PrefixLength = uint8(ones)
+	}
+	return ueip, nil
+}
+
+// Errors of allocators are reported as Rule creation/modification failure
+// unless they carry their own cause
+func allocationError(err error, offendingIE uint16) error {
+	var cerr *CauseError
+	if errors.As(err, &cerr) {
+		return err
+	}
+	return newValidationError(err, ie.CauseRuleCreationModificationFailure, offendingIE)
+}
+
+// Release every resource
+func (s *sessionResources) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.teids {
+		s.teidAllocator.Release(a.networkInstance, a.sourceInterface, a.teid)
+	}
+	s.teids = s.teids[:0]
+	clear(s.chooseIDs)
+	for ni, addr := range s.ipv4 {
+		s.ueipAllocator.ReleaseIPv4(ni, addr)
+	}
+	clear(s.ipv4)
+	for ni, prefix := range s.ipv6 {
+		s.ueipAllocator.ReleaseIPv6Prefix(ni, prefix)
+	}
+	clear(s.ipv6)
+}
+
+// Release resources no longer used by a PDR of the session,
+// e.g. the F-TEID of a PDR updated with the CH flag
+func (s *sessionResources) releaseUnused(session api.PFCPSessionInterface) {
+	used := make([]*api.PDIFields, 0)
+	session.RLock()
+	session.ForeachUnsortedPDR(func(pdr api.PDRInterface) error {
+		if pdi, err := pdr.PDIFields(); err == nil {
+			used = append(used, pdi)
+		}
+		return nil
+	})
+	session.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	teids := s.teids[:0]
+	for _, a := range s.teids {
+		if slices.ContainsFunc(used, func(pdi *api.PDIFields) bool {
+			return pdi.LocalFTEID != nil && pdi.LocalFTEID.TEID == a.teid && pdi.NetworkInstance == a.networkInstance && pdi.SourceInterface == a.sourceInterface
+		}) {
+			teids = append(teids, a)
+			continue
+		}
+		s.teidAllocator.Release(a.networkInstance, a.sourceInterface, a.teid)
+		for chid, fteid := range s.chooseIDs {
+			if fteid.TEID == a.teid {
+				delete(s.chooseIDs, chid)
+			}
+		}
+	}
+	s.teids = teids
+	for ni, addr := range s.ipv4 {
+		if !slices.ContainsFunc(used, func(pdi *api.PDIFields) bool {
+			return pdi.NetworkInstance == ni && pdi.UEIPAddress != nil && pdi.UEIPAddress.IPv4Address.Equal(addr)
+		}) {
+			s.ueipAllocator.ReleaseIPv4(ni, addr)
+			delete(s.ipv4, ni)
+		}
+	}
+	for ni, prefix := range s.ipv6 {
+		if !slices.ContainsFunc(used, func(pdi *api.PDIFields) bool {
+			return pdi.NetworkInstance == ni && pdi.UEIPAddress != nil && pdi.UEIPAddress.IPv6Address.Equal(prefix.IP)
+		}) {
+			s.ueipAllocator.ReleaseIPv6Prefix(ni, prefix)
+			delete(s.ipv6, ni)
+		}
+	}
+}
+
+func (s *sessionResources) isEmpty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.teids) == 0 && len(s.ipv4) == 0 && len(s.ipv6) == 0
+}
+
+// localResources are resources allocated by the UP function, for each session
+type localResources struct {
+	mu       sync.Mutex
+	sessions map[api.PFCPSessionInterface]*sessionResources
+}
+
+func newLocalResources() *localResources {
+	return &localResources{
+		mu:       sync.Mutex{},
+		sessions: make(map[api.PFCPSessionInterface]*sessionResources),
+	}
+}
+
+// Returns resources allocated for the session (a new empty set if there is none)
+func (l *localResources) get(entity api.PFCPEntityInterface, session api.PFCPSessionInterface) *sessionResources {
+	if l != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if s, exists := l.sessions[session]; exists {
+			return s
+		}
+	}
+	return newSessionResources(entity.TEIDAllocator(), entity.UEIPAllocator())
+}
+
+// Returns resources allocated for the session, if any
+func (l *localResources) lookup(session api.PFCPSessionInterface) (*sessionResources, bool) {
+	if l == nil {
+		return nil, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, exists := l.sessions[session]
+	return s, exists
+}
+
+func (l *localResources) set(session api.PFCPSessionInterface, s *sessionResources) {
+	if l == nil || s.isEmpty() {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sessions[session] = s
+}
+
+// Release resources allocated for the session
+func (l *localResources) release(session api.PFCPSessionInterface) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	s, exists := l.sessions[session]
+	delete(l.sessions, session)
+	l.mu.Unlock()
+	if exists {
+		s.release()
+	}
+}
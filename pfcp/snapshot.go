@@ -0,0 +1,478 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// A snapshot holds the state of an entity, so that it can be restored after a restart
+// without its peers detecting the restart: the Recovery Time Stamp is kept.
+//
+// The snapshot is a JSON document (version 1):
+//
+//	{
+//	  "version": 1,
+//	  "kind": "UP",                           // "CP" or "UP"
+//	  "node_id": "10.0.0.2",
+//	  "recovery_time_stamp": "2022-06-01T12:00:00Z",
+//	  "associations": [{
+//	    "node_id": "10.0.0.1",                // Node ID of the peer
+//	    "next_seid": 3,                       // next SEID allocated in this association
+//	    "sessions": [{
+//	      "local_fseid": "<IE>",
+//	      "remote_fseid": "<IE>",             // omitted when unknown
+//	      "create_pdrs": ["<IE>", ...],
+//	      "create_fars": ["<IE>", ...],
+//	      "resources": {                      // resources allocated by the UP function, omitted when none
+//	        "teids": [{"network_instance": "", "source_interface": 0, "teid": 1}],
+//	        "choose_ids": {"1": "<IE>"},      // F-TEID IE allocated for each CHOOSE ID
+//	        "ipv4": {"internet": "10.45.0.1"},
+//	        "ipv6": {"internet": "2001:db8::/64"}
+//	      }
+//	    }]
+//	  }]
+//	}
+//
+// IEs are encoded as they are sent on the wire, in base64.
+type snapshot struct {
+	Version           int                   `json:"version"`
+	Kind              string                `json:"kind"`
+	NodeID            string                `json:"node_id"`
+	RecoveryTimeStamp time.Time             `json:"recovery_time_stamp"`
+	Associations      []associationSnapshot `json:"associations"`
+}
+
+const snapshotVersion = 1
+
+type associationSnapshot struct {
+	NodeID   string            `json:"node_id"`
+	NextSEID api.SEID          `json:"next_seid"`
+	Sessions []sessionSnapshot `json:"sessions"`
+}
+
+type sessionSnapshot struct {
+	LocalFSEID  []byte             `json:"local_fseid"`
+	RemoteFSEID []byte             `json:"remote_fseid,omitempty"`
+	CreatePDRs  [][]byte           `json:"create_pdrs"`
+	CreateFARs  [][]byte           `json:"create_fars"`
+	Resources   *resourcesSnapshot `json:"resources,omitempty"`
+}
+
+type resourcesSnapshot struct {
+	TEIDs     []teidSnapshot    `json:"teids,omitempty"`
+	ChooseIDs map[uint8][]byte  `json:"choose_ids,omitempty"`
+	IPv4      map[string]string `json:"ipv4,omitempty"`
+	IPv6      map[string]string `json:"ipv6,omitempty"`
+}
+
+type teidSnapshot struct {
+	NetworkInstance string `json:"network_instance"`
+	SourceInterface uint8  `json:"source_interface"`
+	TEID            uint32 `json:"teid"`
+}
+
+// Write a snapshot of associations, sessions (with their PDRs and FARs),
+// and resources allocated by the entity.
+// Sessions provisioned from a file are not included.
+// The entity must be started.
+func (e *PFCPEntity) Snapshot(w io.Writer) error {
+	if e.RecoveryTimeStamp() == nil {
+		return fmt.Errorf("Local PFCP entity is not started")
+	}
+	recoveryTimeStamp, err := e.RecoveryTimeStamp().RecoveryTimeStamp()
+	if err != nil {
+		return err
+	}
+	nodeID, err := e.NodeID().NodeID()
+	if err != nil {
+		return err
+	}
+	snap := snapshot{
+		Version:           snapshotVersion,
+		Kind:              e.kind,
+		NodeID:            nodeID,
+		RecoveryTimeStamp: recoveryTimeStamp.UTC(),
+		Associations:      make([]associationSnapshot, 0),
+	}
+	associations := make(map[string]int) // index of associations in snap.Associations
+	for _, association := range e.associationsMap.GetPFCPAssociations() {
+		a, ok := association.(*PFCPAssociation)
+		if !ok {
+			return fmt.Errorf("Cannot snapshot association of unknown type %T", association)
+		}
+		nid, err := a.NodeID().NodeID()
+		if err != nil {
+			return err
+		}
+		snap.Associations = append(snap.Associations, associationSnapshot{
+			NodeID:   nid,
+			NextSEID: a.sessionIDPool.peekNext(),
+			Sessions: make([]sessionSnapshot, 0),
+		})
+	}
+	sort.Slice(snap.Associations, func(i, j int) bool {
+		return snap.Associations[i].NodeID < snap.Associations[j].NodeID
+	})
+	for i, a := range snap.Associations {
+		associations[a.NodeID] = i
+	}
+	for _, session := range e.GetPFCPSessions() {
+		s, ok := session.(*PFCPSession)
+		if !ok {
+			return fmt.Errorf("Cannot snapshot session of unknown type %T", session)
+		}
+		if a, ok := s.association.(*PFCPAssociation); ok && a.isStatic {
+			// provisioned sessions are provisioned again
+			continue
+		}
+		nid, err := s.association.NodeID().NodeID()
+		if err != nil {
+			return err
+		}
+		i, exists := associations[nid]
+		if !exists {
+			return fmt.Errorf("Association of session is not found")
+		}
+		ss, err := e.newSessionSnapshot(s)
+		if err != nil {
+			return err
+		}
+		snap.Associations[i].Sessions = append(snap.Associations[i].Sessions, *ss)
+	}
+	for _, a := range snap.Associations {
+		sort.Slice(a.Sessions, func(i, j int) bool {
+			return string(a.Sessions[i].LocalFSEID) < string(a.Sessions[j].LocalFSEID)
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&snap)
+}
+
+func (e *PFCPEntity) newSessionSnapshot(s *PFCPSession) (*sessionSnapshot, error) {
+	s.RLock()
+	defer s.RUnlock()
+	ss := sessionSnapshot{
+		CreatePDRs: make([][]byte, 0),
+		CreateFARs: make([][]byte, 0),
+	}
+	var err error
+	if ss.LocalFSEID, err = s.localFseid.Marshal(); err != nil {
+		return nil, err
+	}
+	if s.remoteFseid != nil {
+		if ss.RemoteFSEID, err = s.remoteFseid.Marshal(); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range s.GetSortedPDRIDs() {
+		pdr, err := s.GetPDR(id)
+		if err != nil {
+			return nil, err
+		}
+		b, err := pdr.NewCreatePDR().Marshal()
+		if err != nil {
+			return nil, err
+		}
+		ss.CreatePDRs = append(ss.CreatePDRs, b)
+	}
+	fars := make([]api.FARInterface, 0)
+	s.ForeachUnsortedFAR(func(far api.FARInterface) error {
+		fars = append(fars, far)
+		return nil
+	})
+	sort.Slice(fars, func(i, j int) bool {
+		a, _ := fars[i].ID()
+		b, _ := fars[j].ID()
+		return a < b
+	})
+	for _, far := range fars {
+		b, err := far.NewCreateFAR().Marshal()
+		if err != nil {
+			return nil, err
+		}
+		ss.CreateFARs = append(ss.CreateFARs, b)
+	}
+	if r, exists := e.localResources.lookup(s); exists {
+		if ss.Resources, err = r.snapshot(); err != nil {
+			return nil, err
+		}
+	}
+	return &ss, nil
+}
+
+func (r *sessionResources) snapshot() (*resourcesSnapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rs := resourcesSnapshot{
+		TEIDs:     make([]teidSnapshot, 0, len(r.teids)),
+		ChooseIDs: make(map[uint8][]byte, len(r.chooseIDs)),
+		IPv4:      make(map[string]string, len(r.ipv4)),
+		IPv6:      make(map[string]string, len(r.ipv6)),
+	}
+	for _, a := range r.teids {
+		rs.TEIDs = append(rs.TEIDs, teidSnapshot{
+			NetworkInstance: a.networkInstance,
+			SourceInterface: a.sourceInterface,
+			TEID:            a.teid,
+		})
+	}
+	for chid, fteid := range r.chooseIDs {
+		b, err := fteid.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		if rs.ChooseIDs[chid], err = ie.New(ie.FTEID, b).Marshal(); err != nil {
+			return nil, err
+		}
+	}
+	for ni, addr := range r.ipv4 {
+		rs.IPv4[ni] = addr.String()
+	}
+	for ni, prefix := range r.ipv6 {
+		rs.IPv6[ni] = prefix.String()
+	}
+	return &rs, nil
+}
+
+// Read a snapshot written by Snapshot.
+// This must be called before starting the entity: the state is restored when the entity starts,
+// using the Recovery Time Stamp of the snapshot.
+// Sessions are installed again into the datapath, and resources they use are reserved
+// in allocators: they must implement api.TEIDReserverInterface and api.UEIPReserverInterface
+// when the snapshot holds F-TEIDs and UE IP addresses.
+func (e *PFCPEntity) Restore(r io.Reader) error {
+	if e.RecoveryTimeStamp() != nil {
+		return fmt.Errorf("Cannot restore already started PFCP Entity")
+	}
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return err
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("Unsupported snapshot version %d", snap.Version)
+	}
+	if snap.Kind != e.kind {
+		return fmt.Errorf("Snapshot of a %s function cannot be restored on a %s function", snap.Kind, e.kind)
+	}
+	nodeID, err := e.NodeID().NodeID()
+	if err != nil {
+		return err
+	}
+	if snap.NodeID != nodeID {
+		return fmt.Errorf("Snapshot of Node %s cannot be restored on Node %s", snap.NodeID, nodeID)
+	}
+	// sessions are checked now, so that a malformed snapshot is rejected as a whole
+	for _, as := range snap.Associations {
+		for _, ss := range as.Sessions {
+			if _, err := ss.parse(); err != nil {
+				return fmt.Errorf("Cannot restore session of association with %s: %w", as.NodeID, err)
+			}
+		}
+	}
+	e.restored = &snap
+	return nil
+}
+
+// Restore associations and sessions of a snapshot; the entity is listening.
+// On error, the entity may be partially restored: see undoSnapshot.
+func (e *PFCPEntity) applySnapshot(snap *snapshot) error {
+	for _, as := range snap.Associations {
+		peer, err := newPFCPPeerUP(e, ie.NewNodeIDHeuristic(as.NodeID))
+		if err != nil {
+			return err
+		}
+		association := restoreEstablishedPFCPAssociation(peer, as.NextSEID)
+		if err := e.associationsMap.Add(association); err != nil {
+			association.Close()
+			return err
+		}
+		e.metrics.AssociationsChanged(1)
+		for _, ss := range as.Sessions {
+			if err := e.restoreSession(association, &ss); err != nil {
+				return fmt.Errorf("Cannot restore session of association with %s: %w", as.NodeID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Remove every association and session of the entity, and release their resources:
+// this is the state of an entity before a snapshot is applied.
+func (e *PFCPEntity) undoSnapshot() {
+	for _, session := range e.GetPFCPSessions() {
+		if err := e.RemovePFCPSession(session); err != nil {
+			e.logger.Error("Cannot remove restored session", slog.Any("error", err))
+		}
+	}
+	for _, association := range e.associationsMap.GetPFCPAssociations() {
+		association.Close()
+		if err := e.RemovePFCPAssociation(association); err != nil {
+			e.logger.Error("Cannot remove restored association", slog.Any("error", err))
+		}
+	}
+}
+
+// A session of a snapshot, ready to be restored
+type restoredSession struct {
+	localFseid  *ie.IE
+	remoteFseid *ie.IE // nil when unknown
+	pdrs        *PDRMap
+	fars        *FARMap
+}
+
+// Parse a session of a snapshot; nothing is allocated
+func (ss *sessionSnapshot) parse() (*restoredSession, error) {
+	var rs restoredSession
+	var err error
+	if rs.localFseid, err = ie.Parse(ss.LocalFSEID); err != nil {
+		return nil, err
+	}
+	if _, err := rs.localFseid.FSEID(); err != nil {
+		return nil, err
+	}
+	if ss.RemoteFSEID != nil {
+		if rs.remoteFseid, err = ie.Parse(ss.RemoteFSEID); err != nil {
+			return nil, err
+		}
+		if _, err := rs.remoteFseid.FSEID(); err != nil {
+			return nil, err
+		}
+	}
+	pdrIEs, err := parseIEs(ss.CreatePDRs)
+	if err != nil {
+		return nil, err
+	}
+	farIEs, err := parseIEs(ss.CreateFARs)
+	if err != nil {
+		return nil, err
+	}
+	if rs.pdrs, err = NewPDRMap(pdrIEs); err != nil {
+		return nil, err
+	}
+	if rs.fars, err = NewFARMap(farIEs); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+func (e *PFCPEntity) restoreSession(association api.PFCPAssociationInterface, ss *sessionSnapshot) error {
+	rs, err := ss.parse()
+	if err != nil {
+		return err
+	}
+	var resources *sessionResources
+	if ss.Resources != nil {
+		if resources, err = e.restoreSessionResources(ss.Resources); err != nil {
+			return err
+		}
+	}
+	session, err := restoreEstablishedPFCPSession(association, rs.localFseid, rs.remoteFseid, rs.pdrs, rs.fars)
+	if err != nil {
+		if resources != nil {
+			resources.release()
+		}
+		return err
+	}
+	if resources != nil {
+		e.localResources.set(session, resources)
+	}
+	return nil
+}
+
+func parseIEs(bs [][]byte) ([]*ie.IE, error) {
+	ies := make([]*ie.IE, 0, len(bs))
+	for _, b := range bs {
+		i, err := ie.Parse(b)
+		if err != nil {
+			return nil, err
+		}
+		ies = append(ies, i)
+	}
+	return ies, nil
+}
+
+// Create sessionResources from a snapshot, and reserve them in allocators.
+// Only reserved resources are recorded, so that they can be released on failure.
+func (e *PFCPEntity) restoreSessionResources(rs *resourcesSnapshot) (*sessionResources, error) {
+	r := newSessionResources(e.TEIDAllocator(), e.UEIPAllocator())
+	fail := func(err error) (*sessionResources, error) {
+		r.release()
+		return nil, err
+	}
+	var teidReserver api.TEIDReserverInterface
+	if len(rs.TEIDs) > 0 {
+		if r.teidAllocator == nil {
+			return fail(fmt.Errorf("F-TEID allocation is not supported"))
+		}
+		var ok bool
+		if teidReserver, ok = r.teidAllocator.(api.TEIDReserverInterface); !ok {
+			return fail(fmt.Errorf("TEID allocator cannot reserve restored TEIDs"))
+		}
+	}
+	var ueipReserver api.UEIPReserverInterface
+	if len(rs.IPv4) > 0 || len(rs.IPv6) > 0 {
+		if r.ueipAllocator == nil {
+			return fail(fmt.Errorf("UE IP address allocation is not supported"))
+		}
+		var ok bool
+		if ueipReserver, ok = r.ueipAllocator.(api.UEIPReserverInterface); !ok {
+			return fail(fmt.Errorf("UE IP allocator cannot reserve restored UE IP addresses"))
+		}
+	}
+	for _, a := range rs.TEIDs {
+		if err := teidReserver.Reserve(a.NetworkInstance, a.SourceInterface, a.TEID); err != nil {
+			return fail(err)
+		}
+		r.teids = append(r.teids, teidAllocation{
+			networkInstance: a.NetworkInstance,
+			sourceInterface: a.SourceInterface,
+			teid:            a.TEID,
+		})
+	}
+	for chid, b := range rs.ChooseIDs {
+		i, err := ie.Parse(b)
+		if err != nil {
+			return fail(err)
+		}
+		fteid, err := i.FTEID()
+		if err != nil {
+			return fail(err)
+		}
+		r.chooseIDs[chid] = fteid
+	}
+	for ni, s := range rs.IPv4 {
+		addr := net.ParseIP(s).To4()
+		if addr == nil {
+			return fail(fmt.Errorf("Wrong UE IPv4 address %q", s))
+		}
+		if err := ueipReserver.ReserveIPv4(ni, addr); err != nil {
+			return fail(err)
+		}
+		r.ipv4[ni] = addr
+	}
+	for ni, s := range rs.IPv6 {
+		_, prefix, err := net.ParseCIDR(s)
+		if err != nil {
+			return fail(fmt.Errorf("Wrong UE IPv6 prefix %q", s))
+		}
+		if err := ueipReserver.ReserveIPv6Prefix(ni, prefix); err != nil {
+			return fail(err)
+		}
+		r.ipv6[ni] = prefix
+	}
+	return r, nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking_test
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	pfcp_networking "github.com/nextmn/go-pfcp-networking/pfcp"
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/nextmn/go-pfcp-networking/pfcptransport"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// A UE IP allocator which cannot reserve addresses of a snapshot
+type nonReservingUEIPAllocator struct {
+	api.UEIPAllocatorInterface
+	released int
+}
+
+func (a *nonReservingUEIPAllocator) ReleaseIPv4(networkInstance string, addr net.IP) error {
+	a.released++
+	return a.UEIPAllocatorInterface.ReleaseIPv4(networkInstance, addr)
+}
+
+func (a *nonReservingUEIPAllocator) ReleaseIPv6Prefix(networkInstance string, prefix *net.IPNet) error {
+	a.released++
+	return a.UEIPAllocatorInterface.ReleaseIPv6Prefix(networkInstance, prefix)
+}
+
+func newSnapshotTEIDPool(t *testing.T) *pfcp_networking.TEIDPool {
+	t.Helper()
+	pool, err := pfcp_networking.NewTEIDPool(net.ParseIP(testUPNodeID), nil, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func newSnapshotUEIPPools(t *testing.T) *pfcp_networking.UEIPPools {
+	t.Helper()
+	pools := pfcp_networking.NewUEIPPools()
+	_, prefix, _ := net.ParseCIDR("10.45.0.0/24")
+	pool, err := pfcp_networking.NewUEIPv4Pool(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pools.AddIPv4Pool("internet", pool); err != nil {
+		t.Fatal(err)
+	}
+	return pools
+}
+
+// Returns the snapshot of a UP function with a session using a F-TEID and a UE IP address
+// allocated by the UP function, and the TEID allocated
+func newTestSnapshot(t *testing.T) ([]byte, uint32) {
+	t.Helper()
+	cp, up := newTestFunctions(t, func(up *pfcp_networking.PFCPEntityUP) error {
+		if err := up.AddTEIDPool("internet", ie.SrcInterfaceAccess, newSnapshotTEIDPool(t)); err != nil {
+			return err
+		}
+		return up.SetUEIPAllocator(newSnapshotUEIPPools(t))
+	})
+	_, res, err := cp.EstablishSession(testUPNodeID,
+		testCreatePDR(ie.NewPDRID(1), ie.NewPrecedence(1), ie.NewPDI(
+			ie.NewSourceInterface(ie.SrcInterfaceAccess),
+			ie.NewNetworkInstance("internet"),
+			ie.NewFTEID(0x05, 0, nil, nil, 0),
+			ie.NewUEIPAddress(api.UEIPFlagCHV4, "", "", 0, 0),
+		), ie.NewFARID(1)),
+		testCreateFAR(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCause(t, res.Cause, res.OffendingIE, ie.CauseRequestAccepted, 0)
+	if len(res.CreatedPDR) != 1 {
+		t.Fatalf("got %d Created PDRs, expected 1", len(res.CreatedPDR))
+	}
+	fteid, err := res.CreatedPDR[0].FTEID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := up.Entity().Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), fteid.TEID
+}
+
+// Create a UP function restoring the snapshot
+func newRestoredUP(t *testing.T, snap []byte) *pfcp_networking.PFCPEntityUP {
+	t.Helper()
+	up := pfcp_networking.NewPFCPEntityUP(testUPNodeID)
+	if err := up.SetTransport(pfcptransport.NewMemoryNetwork()); err != nil {
+		t.Fatal(err)
+	}
+	up.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := up.Restore(bytes.NewReader(snap)); err != nil {
+		t.Fatal(err)
+	}
+	return up
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	snap, teid := newTestSnapshot(t)
+
+	up := newRestoredUP(t, snap)
+	teids := newSnapshotTEIDPool(t)
+	if err := up.AddTEIDPool("internet", ie.SrcInterfaceAccess, teids); err != nil {
+		t.Fatal(err)
+	}
+	if err := up.SetUEIPAllocator(newSnapshotUEIPPools(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := up.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer up.Close()
+
+	if n := len(up.GetPFCPSessions()); n != 1 {
+		t.Fatalf("got %d sessions, expected 1", n)
+	}
+	if err := teids.Reserve(teid); err == nil {
+		t.Errorf("TEID %d of the snapshot is not reserved", teid)
+	}
+	var buf bytes.Buffer
+	if err := up.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), snap) {
+		t.Errorf("got snapshot %s, expected %s", buf.Bytes(), snap)
+	}
+}
+
+// Resources of a snapshot cannot be restored with an allocator which cannot reserve them,
+// and resources that have been reserved are released
+func TestSnapshotRestoreNonReservingAllocator(t *testing.T) {
+	snap, teid := newTestSnapshot(t)
+
+	up := newRestoredUP(t, snap)
+	teids := newSnapshotTEIDPool(t)
+	if err := up.AddTEIDPool("internet", ie.SrcInterfaceAccess, teids); err != nil {
+		t.Fatal(err)
+	}
+	ueips := &nonReservingUEIPAllocator{UEIPAllocatorInterface: newSnapshotUEIPPools(t)}
+	if err := up.SetUEIPAllocator(ueips); err != nil {
+		t.Fatal(err)
+	}
+	if err := up.Start(); err == nil {
+		up.Close()
+		t.Fatal("snapshot has been restored with a non-reserving UE IP allocator")
+	}
+	if n := len(up.GetPFCPSessions()); n != 0 {
+		t.Errorf("got %d sessions, expected 0", n)
+	}
+	if ueips.released != 0 {
+		t.Errorf("%d UE IP addresses not reserved have been released", ueips.released)
+	}
+	if err := teids.Reserve(teid); err != nil {
+		t.Errorf("TEID of the snapshot is still reserved: %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// TEIDPool allocates TEIDs of a range, used with the IP address(es) of the pool
+type TEIDPool struct {
+	ipv4  net.IP
+	ipv6  net.IP
+	teids *idRange
+}
+
+// Create a TEIDPool of TEIDs from first to last (included).
+// TEID 0 is reserved and cannot be allocated.
+func NewTEIDPool(ipv4 net.IP, ipv6 net.IP, first uint32, last uint32) (*TEIDPool, error) {
+	if ipv4 == nil && ipv6 == nil {
+		return nil, fmt.Errorf("Cannot create TEID pool with no IP Address")
+	}
+	if ipv4 != nil {
+		if ipv4 = ipv4.To4(); ipv4 == nil {
+			return nil, fmt.Errorf("IPv4 Address of TEID pool is not an IPv4 Address")
+		}
+	}
+	if ipv6 != nil {
+		if ipv6.To4() != nil {
+			return nil, fmt.Errorf("IPv6 Address of TEID pool is not an IPv6 Address")
+		}
+		ipv6 = ipv6.To16()
+	}
+	if first == 0 {
+		return nil, fmt.Errorf("TEID 0 is reserved")
+	}
+	if last < first {
+		return nil, fmt.Errorf("Last TEID of the pool is lower than first TEID")
+	}
+	return &TEIDPool{
+		ipv4:  ipv4,
+		ipv6:  ipv6,
+		teids: newIDRange(first, last),
+	}, nil
+}
+
+// Allocate a TEID
+func (pool *TEIDPool) Allocate() (uint32, error) {
+	teid, ok := pool.teids.allocate()
+	if !ok {
+		return 0, newValidationError(fmt.Errorf("TEID pool is exhausted"), ie.CauseNoResourcesAvailable, ie.FTEID)
+	}
+	return teid, nil
+}
+
+// Release a TEID
+func (pool *TEIDPool) Release(teid uint32) error {
+	if !pool.teids.release(teid) {
+		return fmt.Errorf("TEID %d is not allocated", teid)
+	}
+	return nil
+}
+
+// Mark a TEID as allocated
+func (pool *TEIDPool) Reserve(teid uint32) error {
+	if !pool.teids.reserve(teid) {
+		return fmt.Errorf("TEID %d is not available", teid)
+	}
+	return nil
+}
+
+type teidPoolKey struct {
+	networkInstance string
+	sourceInterface uint8
+}
+
+// TEIDPools is the default TEID allocator of UP functions.
+// It holds a TEIDPool per Network Instance and Source Interface;
+// the same TEIDPool can be used for several of them.
+type TEIDPools struct {
+	pools map[teidPoolKey]*TEIDPool
+	mu    sync.RWMutex
+}
+
+var _ api.TEIDAllocatorInterface = (*TEIDPools)(nil)
+var _ api.TEIDReserverInterface = (*TEIDPools)(nil)
+
+// Create an empty TEIDPools
+func NewTEIDPools() *TEIDPools {
+	return &TEIDPools{
+		pools: make(map[teidPoolKey]*TEIDPool),
+		mu:    sync.RWMutex{},
+	}
+}
+
+// Use pool for PDIs with this Network Instance and Source Interface.
+// An empty networkInstance is used for PDIs without Network Instance.
+func (p *TEIDPools) Add(networkInstance string, sourceInterface uint8, pool *TEIDPool) error {
+	if pool == nil {
+		return fmt.Errorf("TEID pool is nil")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := teidPoolKey{networkInstance: networkInstance, sourceInterface: sourceInterface}
+	if _, exists := p.pools[key]; exists {
+		return fmt.Errorf("A TEID pool already exists for this Network Instance and Source Interface")
+	}
+	p.pools[key] = pool
+	return nil
+}
+
+func (p *TEIDPools) get(networkInstance string, sourceInterface uint8) (*TEIDPool, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	pool, exists := p.pools[teidPoolKey{networkInstance: networkInstance, sourceInterface: sourceInterface}]
+	if !exists {
+		return nil, fmt.Errorf("No TEID pool for Network Instance %q and Source Interface %d", networkInstance, sourceInterface)
+	}
+	return pool, nil
+}
+
+func (p *TEIDPools) Allocate(networkInstance string, sourceInterface uint8, ipv4 bool, ipv6 bool) (*ie.FTEIDFields, error) {
+	pool, err := p.get(networkInstance, sourceInterface)
+	if err != nil {
+		return nil, err
+	}
+	if !ipv4 && !ipv6 {
+		// no IP version requested: use the one of the pool
+		ipv4 = pool.ipv4 != nil
+		ipv6 = !ipv4
+	}
+	if (ipv4 && pool.ipv4 == nil) || (ipv6 && pool.ipv6 == nil) {
+		return nil, fmt.Errorf("TEID pool has no address of the requested IP version")
+	}
+	teid, err := pool.Allocate()
+	if err != nil {
+		return nil, err
+	}
+	fteid := ie.NewFTEIDFields(0, teid, nil, nil, 0)
+	if ipv4 {
+		fteid.SetIPv4Flag()
+		fteid.IPv4Address = pool.ipv4
+	}
+	if ipv6 {
+		fteid.SetIPv6Flag()
+		fteid.IPv6Address = pool.ipv6
+	}
+	return fteid, nil
+}
+
+func (p *TEIDPools) Release(networkInstance string, sourceInterface uint8, teid uint32) error {
+	pool, err := p.get(networkInstance, sourceInterface)
+	if err != nil {
+		return err
+	}
+	return pool.Release(teid)
+}
+
+func (p *TEIDPools) Reserve(networkInstance string, sourceInterface uint8, teid uint32) error {
+	pool, err := p.get(networkInstance, sourceInterface)
+	if err != nil {
+		return err
+	}
+	return pool.Reserve(teid)
+}
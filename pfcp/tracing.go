@@ -0,0 +1,53 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"context"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/nextmn/go-pfcp-networking/pfcputil"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Default tracer: spans are discarded
+type noopTracer struct{}
+type noopSpan struct{}
+
+func (noopTracer) Start(ctx context.Context, spanName string) (context.Context, api.SpanInterface) {
+	return ctx, noopSpan{}
+}
+func (noopSpan) SetAttributes(attrs ...api.SpanAttribute) {}
+func (noopSpan) RecordError(err error)                    {}
+func (noopSpan) End()                                     {}
+
+type spanContextKey struct{}
+
+// Start a span, also stored in the context so handlers can complete it
+func startSpan(ctx context.Context, tracer api.TracerInterface, msg message.Message) (context.Context, api.SpanInterface) {
+	ctx, span := tracer.Start(ctx, "PFCP "+msg.MessageTypeName())
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// Returns the span of a context, or a no-op span
+func spanFromContext(ctx context.Context) api.SpanInterface {
+	if span, ok := ctx.Value(spanContextKey{}).(api.SpanInterface); ok {
+		return span
+	}
+	return noopSpan{}
+}
+
+// Returns span attributes describing a message
+func spanAttrsMessage(msgType uint8, sn uint32, seid uint64) []api.SpanAttribute {
+	attrs := []api.SpanAttribute{
+		{Key: api.SpanAttrMessageType, Value: pfcputil.MessageTypeName(msgType)},
+		{Key: api.SpanAttrSequenceNumber, Value: uint64(sn)},
+	}
+	if pfcputil.IsMessageTypeSessionRelated(msgType) {
+		attrs = append(attrs, api.SpanAttribute{Key: api.SpanAttrSEID, Value: seid})
+	}
+	return attrs
+}
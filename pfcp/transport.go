@@ -0,0 +1,48 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"net"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// Default transport, using UDP sockets of the operating system
+type udpTransport struct{}
+
+func (udpTransport) ListenUDP(laddr *net.UDPAddr) (net.PacketConn, error) {
+	return net.ListenUDP("udp", laddr)
+}
+
+func (udpTransport) DialUDP(localIP net.IP, raddr *net.UDPAddr) (net.PacketConn, error) {
+	// localIP is ignored: the source address is the one
+	// the operating system would use to reach raddr
+	c, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return nil, err
+	}
+	c.Close()
+	laddr := c.LocalAddr().(*net.UDPAddr)
+	return net.ListenUDP("udp", laddr)
+}
+
+// Returns the IP Address of a NodeID IE, or nil if NodeID is not an IP Address
+func nodeIDIP(nodeID *ie.IE) net.IP {
+	if nodeID == nil || len(nodeID.Payload) == 0 {
+		return nil
+	}
+	switch nodeID.Payload[0] {
+	case ie.NodeIDIPv4Address, ie.NodeIDIPv6Address:
+		nid, err := nodeID.NodeID()
+		if err != nil {
+			return nil
+		}
+		return net.ParseIP(nid)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcp_networking
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// At most 2^32 prefixes can be allocated from an IPv6 prefix pool
+const ueIPv6PrefixPoolMaxBits = 32
+
+// UEIPv4Pool allocates IPv4 addresses of a prefix to UEs.
+// Network and broadcast addresses are not allocated.
+type UEIPv4Pool struct {
+	prefix    *net.IPNet
+	addresses *idRange // offsets in the prefix
+}
+
+// Create an UEIPv4Pool of addresses of prefix
+func NewUEIPv4Pool(prefix *net.IPNet) (*UEIPv4Pool, error) {
+	if prefix == nil || prefix.IP.To4() == nil {
+		return nil, fmt.Errorf("UE IPv4 pool requires an IPv4 prefix")
+	}
+	ones, bits := prefix.Mask.Size()
+	if bits != 8*net.IPv4len {
+		return nil, fmt.Errorf("UE IPv4 pool requires an IPv4 prefix")
+	}
+	hostBits := bits - ones
+	var first, last uint32 = 0, uint32((uint64(1) << hostBits) - 1)
+	if hostBits >= 2 {
+		// skip network and broadcast addresses
+		first, last = first+1, last-1
+	}
+	return &UEIPv4Pool{
+		prefix:    &net.IPNet{IP: prefix.IP.To4().Mask(prefix.Mask), Mask: prefix.Mask},
+		addresses: newIDRange(first, last),
+	}, nil
+}
+
+// Allocate an IPv4 address
+func (pool *UEIPv4Pool) Allocate() (net.IP, error) {
+	offset, ok := pool.addresses.allocate()
+	if !ok {
+		return nil, newValidationError(fmt.Errorf("UE IPv4 pool is exhausted"), ie.CauseNoResourcesAvailable, ie.UEIPAddress)
+	}
+	base := big.NewInt(0).SetBytes(pool.prefix.IP)
+	return bigToIP(base.Add(base, big.NewInt(int64(offset))), net.IPv4len), nil
+}
+
+// Release an IPv4 address
+func (pool *UEIPv4Pool) Release(addr net.IP) error {
+	offset, err := pool.offset(addr)
+	if err != nil {
+		return err
+	}
+	if !pool.addresses.release(offset) {
+		return fmt.Errorf("Address %s is not allocated", addr)
+	}
+	return nil
+}
+
+// Mark an IPv4 address as allocated
+func (pool *UEIPv4Pool) Reserve(addr net.IP) error {
+	offset, err := pool.offset(addr)
+	if err != nil {
+		return err
+	}
+	if !pool.addresses.reserve(offset) {
+		return fmt.Errorf("Address %s is not available", addr)
+	}
+	return nil
+}
+
+// Returns the offset of an address in the prefix
+func (pool *UEIPv4Pool) offset(addr net.IP) (uint32, error) {
+	addr = addr.To4()
+	if addr == nil || !pool.prefix.Contains(addr) {
+		return 0, fmt.Errorf("Address %s is not in UE IPv4 pool", addr)
+	}
+	offset := big.NewInt(0).Sub(big.NewInt(0).SetBytes(addr), big.NewInt(0).SetBytes(pool.prefix.IP))
+	return uint32(offset.Uint64()), nil
+}
+
+// UEIPv6PrefixPool allocates IPv6 prefixes of a larger prefix to UEs
+type UEIPv6PrefixPool struct {
+	prefix       *net.IPNet
+	prefixLength int
+	prefixes     *idRange // indexes of prefixes in the pool
+}
+
+// Create an UEIPv6PrefixPool of prefixes of prefixLength bits (usually 64) taken from prefix.
+// At most 2^32 prefixes are used.
+func NewUEIPv6PrefixPool(prefix *net.IPNet, prefixLength int) (*UEIPv6PrefixPool, error) {
+	if prefix == nil || prefix.IP.To4() != nil || prefix.IP.To16() == nil {
+		return nil, fmt.Errorf("UE IPv6 prefix pool requires an IPv6 prefix")
+	}
+	ones, bits := prefix.Mask.Size()
+	if bits != 8*net.IPv6len {
+		return nil, fmt.Errorf("UE IPv6 prefix pool requires an IPv6 prefix")
+	}
+	if prefixLength < ones || prefixLength > bits {
+		return nil, fmt.Errorf("Length of allocated prefixes must be between %d and %d", ones, bits)
+	}
+	indexBits := prefixLength - ones
+	if indexBits > ueIPv6PrefixPoolMaxBits {
+		indexBits = ueIPv6PrefixPoolMaxBits
+	}
+	return &UEIPv6PrefixPool{
+		prefix:       &net.IPNet{IP: prefix.IP.Mask(prefix.Mask), Mask: prefix.Mask},
+		prefixLength: prefixLength,
+		prefixes:     newIDRange(0, uint32((uint64(1)<<indexBits)-1)),
+	}, nil
+}
+
+// Allocate an IPv6 prefix
+func (pool *UEIPv6PrefixPool) Allocate() (*net.IPNet, error) {
+	index, ok := pool.prefixes.allocate()
+	if !ok {
+		return nil, newValidationError(fmt.Errorf("UE IPv6 prefix pool is exhausted"), ie.CauseNoResourcesAvailable, ie.UEIPAddress)
+	}
+	offset := big.NewInt(int64(index))
+	offset.Lsh(offset, uint(8*net.IPv6len-pool.prefixLength))
+	base := big.NewInt(0).SetBytes(pool.prefix.IP)
+	return &net.IPNet{
+		IP:   bigToIP(base.Add(base, offset), net.IPv6len),
+		Mask: net.CIDRMask(pool.prefixLength, 8*net.IPv6len),
+	}, nil
+}
+
+// Release an IPv6 prefix
+func (pool *UEIPv6PrefixPool) Release(prefix *net.IPNet) error {
+	index, err := pool.index(prefix)
+	if err != nil {
+		return err
+	}
+	if !pool.prefixes.release(index) {
+		return fmt.Errorf("Prefix %s is not allocated", prefix)
+	}
+	return nil
+}
+
+// Mark an IPv6 prefix as allocated
+func (pool *UEIPv6PrefixPool) Reserve(prefix *net.IPNet) error {
+	index, err := pool.index(prefix)
+	if err != nil {
+		return err
+	}
+	if !pool.prefixes.reserve(index) {
+		return fmt.Errorf("Prefix %s is not available", prefix)
+	}
+	return nil
+}
+
+// Returns the index of a prefix in the pool
+func (pool *UEIPv6PrefixPool) index(prefix *net.IPNet) (uint32, error) {
+	if prefix == nil || !pool.prefix.Contains(prefix.IP) {
+		return 0, fmt.Errorf("Prefix %s is not in UE IPv6 prefix pool", prefix)
+	}
+	offset := big.NewInt(0).Sub(big.NewInt(0).SetBytes(prefix.IP.To16()), big.NewInt(0).SetBytes(pool.prefix.IP))
+	offset.Rsh(offset, uint(8*net.IPv6len-pool.prefixLength))
+	if !offset.IsUint64() || offset.Uint64() > uint64(^uint32(0)) {
+		return 0, fmt.Errorf("Prefix %s is not in UE IPv6 prefix pool", prefix)
+	}
+	return uint32(offset.Uint64()), nil
+}
+
+func bigToIP(i *big.Int, length int) net.IP {
+	ip := make(net.IP, length)
+	i.FillBytes(ip)
+	return ip
+}
+
+// UEIPPools is the default UE IP address allocator of UP functions.
+// It holds an UEIPv4Pool and an UEIPv6PrefixPool per Network Instance.
+type UEIPPools struct {
+	ipv4 map[string]*UEIPv4Pool
+	ipv6 map[string]*UEIPv6PrefixPool
+	mu   sync.RWMutex
+}
+
+var _ api.UEIPAllocatorInterface = (*UEIPPools)(nil)
+var _ api.UEIPReserverInterface = (*UEIPPools)(nil)
+
+// Create an empty UEIPPools
+func NewUEIPPools() *UEIPPools {
+	return &UEIPPools{
+		ipv4: make(map[string]*UEIPv4Pool),
+		ipv6: make(map[string]*UEIPv6PrefixPool),
+		mu:   sync.RWMutex{},
+	}
+}
+
+// Use pool for PDIs with this Network Instance.
+// An empty networkInstance is used for PDIs without Network Instance.
+func (p *UEIPPools) AddIPv4Pool(networkInstance string, pool *UEIPv4Pool) error {
+	if pool == nil {
+		return fmt.Errorf("UE IPv4 pool is nil")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.ipv4[networkInstance]; exists {
+		return fmt.Errorf("An UE IPv4 pool already exists for this Network Instance")
+	}
+	p.ipv4[networkInstance] = pool
+	return nil
+}
+
+// Use pool for PDIs with this Network Instance.
+// An empty networkInstance is used for PDIs without Network Instance.
+func (p *UEIPPools) AddIPv6PrefixPool(networkInstance string, pool *UEIPv6PrefixPool) error {
+	if pool == nil {
+		return fmt.Errorf("UE IPv6 prefix pool is nil")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.ipv6[networkInstance]; exists {
+		return fmt.Errorf("An UE IPv6 prefix pool already exists for this Network Instance")
+	}
+	p.ipv6[networkInstance] = pool
+	return nil
+}
+
+func (p *UEIPPools) getIPv4(networkInstance string) (*UEIPv4Pool, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	pool, exists := p.ipv4[networkInstance]
+	if !exists {
+		return nil, fmt.Errorf("No UE IPv4 pool for Network Instance %q", networkInstance)
+	}
+	return pool, nil
+}
+
+func (p *UEIPPools) getIPv6(networkInstance string) (*UEIPv6PrefixPool, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	pool, exists := p.ipv6[networkInstance]
+	if !exists {
+		return nil, fmt.Errorf("No UE IPv6 prefix pool for Network Instance %q", networkInstance)
+	}
+	return pool, nil
+}
+
+func (p *UEIPPools) AllocateIPv4(networkInstance string) (net.IP, error) {
+	pool, err := p.getIPv4(networkInstance)
+	if err != nil {
+		return nil, err
+	}
+	return pool.Allocate()
+}
+
+func (p *UEIPPools) AllocateIPv6Prefix(networkInstance string) (*net.IPNet, error) {
+	pool, err := p.getIPv6(networkInstance)
+	if err != nil {
+		return nil, err
+	}
+	return pool.Allocate()
+}
+
+func (p *UEIPPools) ReleaseIPv4(networkInstance string, addr net.IP) error {
+	pool, err := p.getIPv4(networkInstance)
+	if err != nil {
+		return err
+	}
+	return pool.Release(addr)
+}
+
+func (p *UEIPPools) ReleaseIPv6Prefix(networkInstance string, prefix *net.IPNet) error {
+	pool, err := p.getIPv6(networkInstance)
+	if err != nil {
+		return err
+	}
+	return pool.Release(prefix)
+}
+
+func (p *UEIPPools) ReserveIPv4(networkInstance string, addr net.IP) error {
+	pool, err := p.getIPv4(networkInstance)
+	if err != nil {
+		return err
+	}
+	return pool.Reserve(addr)
+}
+
+func (p *UEIPPools) ReserveIPv6Prefix(networkInstance string, prefix *net.IPNet) error {
+	pool, err := p.getIPv6(networkInstance)
+	if err != nil {
+		return err
+	}
+	return pool.Reserve(prefix)
+}
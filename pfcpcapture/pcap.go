@@ -0,0 +1,165 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Package pfcpcapture records PFCP traffic to pcap files.
+// Each datagram is written with synthetic IP and UDP headers
+// so Wireshark dissects it as PFCP.
+package pfcpcapture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	pcapMagic           = 0xa1b2c3d4 // microsecond timestamps
+	pcapMagicNanosecond = 0xa1b23c4d // nanosecond timestamps
+	pcapVersionMajor    = 2
+	pcapVersionMinor    = 4
+	pcapSnapLen         = 65535
+	pcapLinkTypeRaw     = 101 // LINKTYPE_RAW: packets begin with an IPv4 or IPv6 header
+	pcapHeaderLen       = 24
+	pcapRecordHdrLen    = 16
+	ipv4HeaderLen       = 20
+	ipv6HeaderLen       = 40
+	udpHeaderLen        = 8
+	ipProtocolUDP       = 17
+	defaultHopLimit     = 64
+	maxUDPPayloadSize   = 65535 - ipv6HeaderLen - udpHeaderLen
+)
+
+// Write the pcap global header
+func writeFileHeader(w io.Writer) error {
+	h := make([]byte, pcapHeaderLen)
+	binary.LittleEndian.PutUint32(h[0:4], pcapMagic)
+	binary.LittleEndian.PutUint16(h[4:6], pcapVersionMajor)
+	binary.LittleEndian.PutUint16(h[6:8], pcapVersionMinor)
+	// thiszone and sigfigs are 0
+	binary.LittleEndian.PutUint32(h[16:20], pcapSnapLen)
+	binary.LittleEndian.PutUint32(h[20:24], pcapLinkTypeRaw)
+	_, err := w.Write(h)
+	return err
+}
+
+// Write a pcap record for a datagram
+func writeRecord(w io.Writer, t time.Time, packet []byte) error {
+	h := make([]byte, pcapRecordHdrLen)
+	binary.LittleEndian.PutUint32(h[0:4], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(h[4:8], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(h[8:12], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(h[12:16], uint32(len(packet)))
+	if _, err := w.Write(h); err != nil {
+		return err
+	}
+	_, err := w.Write(packet)
+	return err
+}
+
+// Returns IP and port of an address
+func splitAddr(addr net.Addr) (net.IP, uint16, error) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP, uint16(a.Port), nil
+	default:
+		host, port, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return nil, 0, err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return nil, 0, fmt.Errorf("Address %s is not an IP address", host)
+		}
+		var p uint16
+		if _, err := fmt.Sscanf(port, "%d", &p); err != nil {
+			return nil, 0, err
+		}
+		return ip, p, nil
+	}
+}
+
+// Build an IP/UDP packet containing payload
+func buildPacket(src net.Addr, dst net.Addr, payload []byte) ([]byte, error) {
+	if len(payload) > maxUDPPayloadSize {
+		return nil, fmt.Errorf("Payload is too large")
+	}
+	srcIP, srcPort, err := splitAddr(src)
+	if err != nil {
+		return nil, err
+	}
+	dstIP, dstPort, err := splitAddr(dst)
+	if err != nil {
+		return nil, err
+	}
+	udpLen := udpHeaderLen + len(payload)
+	if src4, dst4 := srcIP.To4(), dstIP.To4(); src4 != nil && dst4 != nil {
+		b := make([]byte, ipv4HeaderLen+udpLen)
+		b[0] = 0x45 // version 4, IHL 5
+		binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+		b[8] = defaultHopLimit
+		b[9] = ipProtocolUDP
+		copy(b[12:16], src4)
+		copy(b[16:20], dst4)
+		binary.BigEndian.PutUint16(b[10:12], checksum(b[:ipv4HeaderLen], 0))
+		writeUDP(b[ipv4HeaderLen:], srcPort, dstPort, payload, pseudoHeaderSum(src4, dst4, udpLen))
+		return b, nil
+	}
+	src16, dst16 := srcIP.To16(), dstIP.To16()
+	if src16 == nil || dst16 == nil {
+		return nil, fmt.Errorf("Invalid IP address")
+	}
+	b := make([]byte, ipv6HeaderLen+udpLen)
+	b[0] = 0x60 // version 6
+	binary.BigEndian.PutUint16(b[4:6], uint16(udpLen))
+	b[6] = ipProtocolUDP
+	b[7] = defaultHopLimit
+	copy(b[8:24], src16)
+	copy(b[24:40], dst16)
+	writeUDP(b[ipv6HeaderLen:], srcPort, dstPort, payload, pseudoHeaderSum(src16, dst16, udpLen))
+	return b, nil
+}
+
+// Write UDP header and payload into b
+func writeUDP(b []byte, srcPort uint16, dstPort uint16, payload []byte, pseudoSum uint32) {
+	binary.BigEndian.PutUint16(b[0:2], srcPort)
+	binary.BigEndian.PutUint16(b[2:4], dstPort)
+	binary.BigEndian.PutUint16(b[4:6], uint16(len(b)))
+	copy(b[udpHeaderLen:], payload)
+	c := checksum(b, pseudoSum)
+	if c == 0 {
+		c = 0xffff
+	}
+	binary.BigEndian.PutUint16(b[6:8], c)
+}
+
+// Sum of the pseudo-header used in UDP checksum
+func pseudoHeaderSum(src net.IP, dst net.IP, udpLen int) uint32 {
+	var sum uint32
+	for _, ip := range []net.IP{src, dst} {
+		for i := 0; i+1 < len(ip); i += 2 {
+			sum += uint32(ip[i])<<8 | uint32(ip[i+1])
+		}
+	}
+	sum += ipProtocolUDP
+	sum += uint32(udpLen)
+	return sum
+}
+
+// Internet checksum (RFC 1071)
+func checksum(b []byte, initial uint32) uint16 {
+	sum := initial
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
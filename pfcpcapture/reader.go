@@ -0,0 +1,211 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcpcapture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Link types supported by the Reader
+const (
+	linkTypeEthernet = 1
+	linkTypeRaw      = pcapLinkTypeRaw
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+)
+
+// A Datagram is an UDP datagram read from a capture
+type Datagram struct {
+	Time    time.Time
+	Src     *net.UDPAddr
+	Dst     *net.UDPAddr
+	Payload []byte
+}
+
+// Reader reads UDP datagrams from a pcap stream.
+// Packets that are not UDP (or are IP fragments) are skipped.
+// The pcapng format is not supported.
+type Reader struct {
+	r         io.Reader
+	order     binary.ByteOrder
+	nanosec   bool
+	linkType  uint32
+	headerBuf []byte
+}
+
+// Create a Reader, and read the pcap global header
+func NewReader(r io.Reader) (*Reader, error) {
+	h := make([]byte, pcapHeaderLen)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	reader := &Reader{r: r, headerBuf: make([]byte, pcapRecordHdrLen)}
+	switch {
+	case binary.LittleEndian.Uint32(h[0:4]) == pcapMagic:
+		reader.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(h[0:4]) == pcapMagic:
+		reader.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(h[0:4]) == pcapMagicNanosecond:
+		reader.order = binary.LittleEndian
+		reader.nanosec = true
+	case binary.BigEndian.Uint32(h[0:4]) == pcapMagicNanosecond:
+		reader.order = binary.BigEndian
+		reader.nanosec = true
+	default:
+		return nil, fmt.Errorf("Not a pcap file (pcapng is not supported)")
+	}
+	reader.linkType = reader.order.Uint32(h[20:24]) & 0x0fffffff
+	switch reader.linkType {
+	case linkTypeEthernet, linkTypeRaw, linkTypeLinuxSLL, linkTypeIPv4, linkTypeIPv6:
+	default:
+		return nil, fmt.Errorf("Unsupported link type %d", reader.linkType)
+	}
+	return reader, nil
+}
+
+// Returns the next UDP datagram of the capture, or io.EOF
+func (r *Reader) Next() (*Datagram, error) {
+	for {
+		if _, err := io.ReadFull(r.r, r.headerBuf); err != nil {
+			return nil, err
+		}
+		sec := r.order.Uint32(r.headerBuf[0:4])
+		frac := r.order.Uint32(r.headerBuf[4:8])
+		inclLen := r.order.Uint32(r.headerBuf[8:12])
+		origLen := r.order.Uint32(r.headerBuf[12:16])
+		if inclLen > pcapSnapLen*4 {
+			return nil, fmt.Errorf("Invalid record length")
+		}
+		data := make([]byte, inclLen)
+		if _, err := io.ReadFull(r.r, data); err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if inclLen < origLen {
+			// truncated packet
+			continue
+		}
+		nsec := int64(frac) * 1000
+		if r.nanosec {
+			nsec = int64(frac)
+		}
+		d, ok := r.decode(data)
+		if !ok {
+			continue
+		}
+		d.Time = time.Unix(int64(sec), nsec)
+		return d, nil
+	}
+}
+
+// Read every remaining UDP datagram of the capture
+func (r *Reader) ReadAll() ([]*Datagram, error) {
+	datagrams := make([]*Datagram, 0)
+	for {
+		d, err := r.Next()
+		if err == io.EOF {
+			return datagrams, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		datagrams = append(datagrams, d)
+	}
+}
+
+// Decode link layer, then IP and UDP headers
+func (r *Reader) decode(data []byte) (*Datagram, bool) {
+	switch r.linkType {
+	case linkTypeEthernet:
+		if len(data) < 14 {
+			return nil, false
+		}
+		etherType := binary.BigEndian.Uint16(data[12:14])
+		data = data[14:]
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+			if len(data) < 4 {
+				return nil, false
+			}
+			etherType = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+		if etherType != etherTypeIPv4 && etherType != etherTypeIPv6 {
+			return nil, false
+		}
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil, false
+		}
+		data = data[16:]
+	}
+	return decodeIP(data)
+}
+
+func decodeIP(data []byte) (*Datagram, bool) {
+	if len(data) < 1 {
+		return nil, false
+	}
+	var src, dst net.IP
+	switch data[0] >> 4 {
+	case 4:
+		if len(data) < ipv4HeaderLen {
+			return nil, false
+		}
+		ihl := int(data[0]&0x0f) * 4
+		totalLen := int(binary.BigEndian.Uint16(data[2:4]))
+		flagsOffset := binary.BigEndian.Uint16(data[6:8])
+		if flagsOffset&0x3fff != 0 {
+			// fragment
+			return nil, false
+		}
+		if data[9] != ipProtocolUDP || ihl < ipv4HeaderLen || totalLen < ihl || totalLen > len(data) {
+			return nil, false
+		}
+		src = net.IP(append([]byte{}, data[12:16]...))
+		dst = net.IP(append([]byte{}, data[16:20]...))
+		data = data[ihl:totalLen]
+	case 6:
+		if len(data) < ipv6HeaderLen {
+			return nil, false
+		}
+		// extension headers are not supported
+		payloadLen := int(binary.BigEndian.Uint16(data[4:6]))
+		if data[6] != ipProtocolUDP || ipv6HeaderLen+payloadLen > len(data) {
+			return nil, false
+		}
+		src = net.IP(append([]byte{}, data[8:24]...))
+		dst = net.IP(append([]byte{}, data[24:40]...))
+		data = data[ipv6HeaderLen : ipv6HeaderLen+payloadLen]
+	default:
+		return nil, false
+	}
+	if len(data) < udpHeaderLen {
+		return nil, false
+	}
+	udpLen := int(binary.BigEndian.Uint16(data[4:6]))
+	if udpLen < udpHeaderLen || udpLen > len(data) {
+		return nil, false
+	}
+	return &Datagram{
+		Src:     &net.UDPAddr{IP: src, Port: int(binary.BigEndian.Uint16(data[0:2]))},
+		Dst:     &net.UDPAddr{IP: dst, Port: int(binary.BigEndian.Uint16(data[2:4]))},
+		Payload: append([]byte{}, data[udpHeaderLen:udpLen]...),
+	}, true
+}
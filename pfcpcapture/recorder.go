@@ -0,0 +1,181 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcpcapture
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+)
+
+var _ api.RecorderInterface = (*Recorder)(nil)
+
+// Recorder writes PFCP datagrams to a pcap stream
+type Recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error // last write error
+
+	// rotation, only used by file recorders
+	path     string
+	file     *os.File
+	size     int64 // size of the current file
+	maxSize  int64 // 0 to disable rotation
+	maxFiles int   // number of rotated files to keep, 0 to keep all of them
+	rotated  int   // number of rotated files: path.1 to path.rotated
+}
+
+// Create a Recorder writing to w. The pcap header is written immediately.
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	if err := writeFileHeader(w); err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		mu:  sync.Mutex{},
+		w:   w,
+		err: nil,
+	}, nil
+}
+
+// Create a Recorder writing to the file at path.
+// When writing a packet would make the file larger than maxSize bytes,
+// the file is renamed to path.1 (previous path.1 is renamed to path.2, and so on)
+// and a new file is started. At most maxFiles rotated files are kept.
+// Set maxSize to 0 to disable rotation, and maxFiles to 0 to keep every rotated file.
+// Rotated files left by a previous Recorder are rotated with new ones.
+func NewFileRecorder(path string, maxSize int64, maxFiles int) (*Recorder, error) {
+	if maxSize < 0 || maxFiles < 0 {
+		return nil, fmt.Errorf("maxSize and maxFiles cannot be negative")
+	}
+	if maxSize > 0 && maxSize < pcapHeaderLen+pcapRecordHdrLen {
+		return nil, fmt.Errorf("maxSize is too small")
+	}
+	r := &Recorder{
+		mu:       sync.Mutex{},
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	if maxSize > 0 {
+		r.countRotatedFiles()
+	}
+	if err := r.openFile(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Create the file, and write the pcap header
+func (r *Recorder) openFile() error {
+	f, err := os.Create(r.path)
+	if err != nil {
+		return err
+	}
+	if err := writeFileHeader(f); err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.w = f
+	r.size = pcapHeaderLen
+	return nil
+}
+
+func (r *Recorder) rotatedPath(i int) string {
+	return fmt.Sprintf("%s.%d", r.path, i)
+}
+
+// Count rotated files, up to the first missing one
+func (r *Recorder) countRotatedFiles() {
+	for r.maxFiles == 0 || r.rotated < r.maxFiles {
+		if _, err := os.Stat(r.rotatedPath(r.rotated + 1)); err != nil {
+			return
+		}
+		r.rotated++
+	}
+}
+
+// Rename current file and open a new one
+func (r *Recorder) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	if r.maxFiles > 0 && r.rotated == r.maxFiles {
+		if err := os.Remove(r.rotatedPath(r.rotated)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		r.rotated--
+	}
+	for i := r.rotated; i >= 1; i-- {
+		// a rotated file may have been removed by someone else
+		if err := os.Rename(r.rotatedPath(i), r.rotatedPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(r.path, r.rotatedPath(1)); err != nil {
+		return err
+	}
+	r.rotated++
+	return r.openFile()
+}
+
+// Record a datagram sent from src to dst
+func (r *Recorder) Record(src net.Addr, dst net.Addr, payload []byte) {
+	r.RecordAt(time.Now(), src, dst, payload)
+}
+
+// Record a datagram sent from src to dst at time t
+func (r *Recorder) RecordAt(t time.Time, src net.Addr, dst net.Addr, payload []byte) {
+	packet, err := buildPacket(src, dst, payload)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.err = err
+		return
+	}
+	if r.w == nil {
+		r.err = fmt.Errorf("Recorder is closed")
+		return
+	}
+	recordLen := int64(pcapRecordHdrLen + len(packet))
+	if r.file != nil && r.maxSize > 0 && r.size > pcapHeaderLen && r.size+recordLen > r.maxSize {
+		if err := r.rotate(); err != nil {
+			r.err = err
+			r.w = nil
+			return
+		}
+	}
+	if err := writeRecord(r.w, t, packet); err != nil {
+		r.err = err
+		return
+	}
+	r.size += recordLen
+}
+
+// Returns the last error that occurred while recording, if any
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close the Recorder. The underlying writer is closed only for file recorders.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w = nil
+	if r.file != nil {
+		err := r.file.Close()
+		r.file = nil
+		return err
+	}
+	return nil
+}
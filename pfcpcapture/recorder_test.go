@@ -0,0 +1,185 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcpcapture_test
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcpcapture"
+)
+
+// Size of a record of a datagram of 100 bytes between IPv4 addresses:
+// record header (16), IPv4 header (20), UDP header (8), and payload
+const testRecordLen = 16 + 20 + 8 + 100
+
+func newTestDatagram(i int) *pfcpcapture.Datagram {
+	return &pfcpcapture.Datagram{
+		Time:    time.Unix(int64(1654084800+i), 0),
+		Src:     &net.UDPAddr{IP: net.ParseIP("10.0.0.1").To4(), Port: 8805},
+		Dst:     &net.UDPAddr{IP: net.ParseIP("10.0.0.2").To4(), Port: 8805},
+		Payload: bytes.Repeat([]byte{byte(i)}, 100),
+	}
+}
+
+func readFile(t *testing.T, path string) []*pfcpcapture.Datagram {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := pfcpcapture.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datagrams, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return datagrams
+}
+
+func checkDatagrams(t *testing.T, name string, got []*pfcpcapture.Datagram, expected []*pfcpcapture.Datagram) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Errorf("%s: got %d datagrams, expected %d", name, len(got), len(expected))
+		return
+	}
+	for i := range got {
+		g, e := got[i], expected[i]
+		if !g.Time.Equal(e.Time) || g.Src.String() != e.Src.String() || g.Dst.String() != e.Dst.String() || !bytes.Equal(g.Payload, e.Payload) {
+			t.Errorf("%s: datagram %d: got %+v, expected %+v", name, i, *g, *e)
+		}
+	}
+}
+
+func TestRecorderReader(t *testing.T) {
+	expected := []*pfcpcapture.Datagram{
+		newTestDatagram(1),
+		{
+			Time:    time.Unix(1654084800, 123456000),
+			Src:     &net.UDPAddr{IP: net.ParseIP("fd00::1"), Port: 8805},
+			Dst:     &net.UDPAddr{IP: net.ParseIP("fd00::2"), Port: 40000},
+			Payload: []byte{0x21, 0x02, 0x00, 0x00},
+		},
+	}
+	var buf bytes.Buffer
+	r, err := pfcpcapture.NewRecorder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range expected {
+		r.RecordAt(d.Time, d.Src, d.Dst, d.Payload)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := pfcpcapture.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDatagrams(t, "capture", got, expected)
+}
+
+func TestFileRecorderRotation(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		maxFiles int
+		// datagrams of each file, from the current file to the oldest rotated file
+		files [][]int
+	}{
+		{name: "keep every file", maxFiles: 0, files: [][]int{{7}, {5, 6}, {3, 4}, {1, 2}}},
+		{name: "keep 2 files", maxFiles: 2, files: [][]int{{7}, {5, 6}, {3, 4}}},
+		{name: "keep 1 file", maxFiles: 1, files: [][]int{{7}, {5, 6}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "pfcp.pcap")
+			// 2 datagrams per file
+			r, err := pfcpcapture.NewFileRecorder(path, 24+2*testRecordLen, tc.maxFiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i <= 7; i++ {
+				d := newTestDatagram(i)
+				r.RecordAt(d.Time, d.Src, d.Dst, d.Payload)
+			}
+			if err := r.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tc.files) {
+				t.Errorf("got %d files, expected %d", len(entries), len(tc.files))
+			}
+			for i, datagrams := range tc.files {
+				name := path
+				if i > 0 {
+					name = fmt.Sprintf("%s.%d", path, i)
+				}
+				expected := make([]*pfcpcapture.Datagram, 0, len(datagrams))
+				for _, d := range datagrams {
+					expected = append(expected, newTestDatagram(d))
+				}
+				checkDatagrams(t, filepath.Base(name), readFile(t, name), expected)
+			}
+		})
+	}
+}
+
+// Rotated files left by a previous Recorder are rotated with new ones
+func TestFileRecorderRotationPreviousFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pfcp.pcap")
+	for _, i := range []int{1, 2} {
+		r, err := pfcpcapture.NewFileRecorder(fmt.Sprintf("%s.%d", path, i), 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := newTestDatagram(10 * i)
+		r.RecordAt(d.Time, d.Src, d.Dst, d.Payload)
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := pfcpcapture.NewFileRecorder(path, 24+testRecordLen, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		d := newTestDatagram(i)
+		r.RecordAt(d.Time, d.Src, d.Dst, d.Payload)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("got %d files, expected 3", len(entries))
+	}
+	checkDatagrams(t, "pfcp.pcap", readFile(t, path), []*pfcpcapture.Datagram{newTestDatagram(2)})
+	checkDatagrams(t, "pfcp.pcap.1", readFile(t, path+".1"), []*pfcpcapture.Datagram{newTestDatagram(1)})
+	checkDatagrams(t, "pfcp.pcap.2", readFile(t, path+".2"), []*pfcpcapture.Datagram{newTestDatagram(10)})
+}
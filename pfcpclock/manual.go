@@ -0,0 +1,106 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Package pfcpclock provides a clock advanced manually,
+// to test timer-driven behaviour (retransmissions, heartbeats) deterministically.
+package pfcpclock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type waiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// A Manual clock only moves when Advance or Set is called
+type Manual struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+// Create a Manual clock starting at t
+func NewManual(t time.Time) *Manual {
+	c := &Manual{now: t}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *Manual) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Returns a channel receiving the time once the clock has been advanced by d
+func (c *Manual) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, &waiter{deadline: c.now.Add(d), ch: ch})
+	c.cond.Broadcast()
+	return ch
+}
+
+// Advance the clock by d, firing expired timers in deadline order
+func (c *Manual) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(c.now.Add(d))
+}
+
+// Set the clock to t, firing expired timers in deadline order.
+// The clock cannot go backward.
+func (c *Manual) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.Before(c.now) {
+		return
+	}
+	c.set(t)
+}
+
+func (c *Manual) set(t time.Time) {
+	c.now = t
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].deadline.Before(c.waiters[j].deadline)
+	})
+	n := 0
+	for _, w := range c.waiters {
+		if w.deadline.After(t) {
+			break
+		}
+		w.ch <- t
+		n++
+	}
+	c.waiters = c.waiters[n:]
+}
+
+// Returns the number of timers not yet fired.
+// Timers abandoned by their owner are still counted until they fire.
+func (c *Manual) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// Block until at least n timers are waiting; this allows tests
+// to advance the clock only once goroutines are sleeping.
+func (c *Manual) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
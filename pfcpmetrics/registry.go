@@ -0,0 +1,245 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Package pfcpmetrics provides an in-process implementation of api.MetricsInterface
+// that can be scraped in the Prometheus text exposition format.
+package pfcpmetrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/nextmn/go-pfcp-networking/pfcputil"
+)
+
+var _ api.MetricsInterface = (*Registry)(nil)
+
+// Upper bounds (in seconds) of the handler duration histogram buckets
+var DefaultDurationBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+type responseKey struct {
+	msgType uint8
+	cause   uint8
+}
+
+type histogram struct {
+	counts []uint64 // one per bucket, non cumulative
+	count  uint64
+	sum    float64
+}
+
+// Registry stores PFCP metrics in memory
+type Registry struct {
+	mu                sync.Mutex
+	buckets           []float64
+	messagesSent      map[uint8]uint64
+	messagesReceived  map[uint8]uint64
+	retransmissions   map[uint8]uint64
+	timeouts          map[uint8]uint64
+	responsesSent     map[responseKey]uint64
+	responsesReceived map[responseKey]uint64
+	handlerDuration   map[uint8]*histogram
+	associations      int64
+	sessions          int64
+	pdrs              int64
+	fars              int64
+}
+
+// Create a new Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		mu:                sync.Mutex{},
+		buckets:           DefaultDurationBuckets,
+		messagesSent:      make(map[uint8]uint64),
+		messagesReceived:  make(map[uint8]uint64),
+		retransmissions:   make(map[uint8]uint64),
+		timeouts:          make(map[uint8]uint64),
+		responsesSent:     make(map[responseKey]uint64),
+		responsesReceived: make(map[responseKey]uint64),
+		handlerDuration:   make(map[uint8]*histogram),
+		associations:      0,
+		sessions:          0,
+		pdrs:              0,
+		fars:              0,
+	}
+}
+
+func (r *Registry) MessageSent(msgType uint8) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messagesSent[msgType]++
+}
+
+func (r *Registry) MessageReceived(msgType uint8) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messagesReceived[msgType]++
+}
+
+func (r *Registry) RequestRetransmitted(msgType uint8) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retransmissions[msgType]++
+}
+
+func (r *Registry) RequestTimedOut(msgType uint8) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeouts[msgType]++
+}
+
+func (r *Registry) ResponseSent(msgType uint8, cause uint8) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responsesSent[responseKey{msgType: msgType, cause: cause}]++
+}
+
+func (r *Registry) ResponseReceived(msgType uint8, cause uint8) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responsesReceived[responseKey{msgType: msgType, cause: cause}]++
+}
+
+func (r *Registry) HandlerDuration(msgType uint8, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, exists := r.handlerDuration[msgType]
+	if !exists {
+		h = &histogram{counts: make([]uint64, len(r.buckets))}
+		r.handlerDuration[msgType] = h
+	}
+	seconds := d.Seconds()
+	for i, upper := range r.buckets {
+		if seconds <= upper {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (r *Registry) AssociationsChanged(delta int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.associations += int64(delta)
+}
+
+func (r *Registry) SessionsChanged(delta int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions += int64(delta)
+}
+
+func (r *Registry) PDRsChanged(delta int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pdrs += int64(delta)
+}
+
+func (r *Registry) FARsChanged(delta int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fars += int64(delta)
+}
+
+// Write all metrics in the Prometheus text exposition format
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var b strings.Builder
+
+	writeCounterByType(&b, "pfcp_messages_sent_total", "PFCP messages sent.", r.messagesSent)
+	writeCounterByType(&b, "pfcp_messages_received_total", "PFCP messages received.", r.messagesReceived)
+	writeCounterByType(&b, "pfcp_request_retransmissions_total", "PFCP Request messages retransmitted.", r.retransmissions)
+	writeCounterByType(&b, "pfcp_request_timeouts_total", "PFCP Request messages considered lost after all retransmissions.", r.timeouts)
+	writeCounterByCause(&b, "pfcp_responses_sent_total", "PFCP Response messages sent, by cause.", r.responsesSent)
+	writeCounterByCause(&b, "pfcp_responses_received_total", "PFCP Response messages received, by cause.", r.responsesReceived)
+
+	fmt.Fprintf(&b, "# HELP pfcp_handler_duration_seconds Time spent in PFCP message handlers.\n")
+	fmt.Fprintf(&b, "# TYPE pfcp_handler_duration_seconds histogram\n")
+	for _, t := range sortedTypes(r.handlerDuration) {
+		h := r.handlerDuration[t]
+		label := typeLabel(t)
+		var cumulative uint64
+		for i, upper := range r.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "pfcp_handler_duration_seconds_bucket{%s,le=\"%s\"} %d\n", label, formatFloat(upper), cumulative)
+		}
+		fmt.Fprintf(&b, "pfcp_handler_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", label, h.count)
+		fmt.Fprintf(&b, "pfcp_handler_duration_seconds_sum{%s} %s\n", label, formatFloat(h.sum))
+		fmt.Fprintf(&b, "pfcp_handler_duration_seconds_count{%s} %d\n", label, h.count)
+	}
+
+	writeGauge(&b, "pfcp_associations", "Active PFCP Associations.", r.associations)
+	writeGauge(&b, "pfcp_sessions", "Active PFCP Sessions.", r.sessions)
+	writeGauge(&b, "pfcp_pdrs", "PDRs installed in active PFCP Sessions.", r.pdrs)
+	writeGauge(&b, "pfcp_fars", "FARs installed in active PFCP Sessions.", r.fars)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Serve metrics over HTTP, for scraping by Prometheus
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WritePrometheus(w)
+}
+
+func writeCounterByType(b *strings.Builder, name string, help string, values map[uint8]uint64) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s counter\n", name)
+	for _, t := range sortedTypes(values) {
+		fmt.Fprintf(b, "%s{%s} %d\n", name, typeLabel(t), values[t])
+	}
+}
+
+func writeCounterByCause(b *strings.Builder, name string, help string, values map[responseKey]uint64) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s counter\n", name)
+	keys := make([]responseKey, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].msgType != keys[j].msgType {
+			return keys[i].msgType < keys[j].msgType
+		}
+		return keys[i].cause < keys[j].cause
+	})
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s{%s,cause=\"%d\"} %d\n", name, typeLabel(k.msgType), k.cause, values[k])
+	}
+}
+
+func writeGauge(b *strings.Builder, name string, help string, value int64) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(b, "%s %d\n", name, value)
+}
+
+func typeLabel(t uint8) string {
+	return fmt.Sprintf("message_type=%q", pfcputil.MessageTypeName(t))
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedTypes[V any](m map[uint8]V) []uint8 {
+	keys := make([]uint8, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,384 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Package pfcpreplay replays the CP side of a captured SMF↔UPF exchange
+// against a PFCPEntityUP running on an in-process transport,
+// and checks the responses of the entity against the captured ones.
+package pfcpreplay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	pfcp_networking "github.com/nextmn/go-pfcp-networking/pfcp"
+	"github.com/nextmn/go-pfcp-networking/pfcpcapture"
+	"github.com/nextmn/go-pfcp-networking/pfcptransport"
+	"github.com/nextmn/go-pfcp-networking/pfcputil"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// Options of a replay
+type Options struct {
+	// Time to wait for each Response. Default is T1 * (N1 + 1).
+	ResponseTimeout time.Duration
+	// When true, IEs of Responses are compared (after normalisation of SEIDs and timestamps)
+	// in addition to message types, causes and offending IEs.
+	CompareIEs bool
+	// Called with the UP entity before it is started
+	Setup func(entity *pfcp_networking.PFCPEntityUP) error
+}
+
+// An Exchange is a captured Request, with the captured and the actual Responses
+type Exchange struct {
+	Request  message.Message
+	Expected message.Message // nil if the Response was not captured
+	Actual   message.Message // nil if the entity did not respond
+	Err      error           // nil if Actual matches Expected
+}
+
+// A Report is the result of a replay
+type Report struct {
+	CPAddr    *net.UDPAddr
+	UPAddr    *net.UDPAddr
+	Exchanges []*Exchange
+}
+
+// Returns an error describing every mismatch, or nil if all Responses match
+func (r *Report) Err() error {
+	errs := make([]error, 0)
+	for i, e := range r.Exchanges {
+		if e.Err != nil {
+			errs = append(errs, fmt.Errorf("exchange %d (%s, sequence number %d): %w", i, e.Request.MessageTypeName(), e.Request.Sequence(), e.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Replay a pcap file
+func ReplayFile(path string, opts Options) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := pfcpcapture.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	datagrams, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return Replay(datagrams, opts)
+}
+
+// A captured Request, and its captured Response
+type capturedExchange struct {
+	request  []byte
+	response []byte
+}
+
+// Replay captured datagrams. The CP function is the sender of the first
+// Session Establishment Request (or Association Setup Request),
+// the UP function is its receiver.
+func Replay(datagrams []*pfcpcapture.Datagram, opts Options) (*Report, error) {
+	if opts.ResponseTimeout == 0 {
+		opts.ResponseTimeout = pfcputil.MESSAGE_RETRANSMISSION_T1 * (pfcputil.MESSAGE_RETRANSMISSION_N1 + 1)
+	}
+	cpAddr, upAddr, err := findEndpoints(datagrams)
+	if err != nil {
+		return nil, err
+	}
+	exchanges := extractExchanges(datagrams, cpAddr, upAddr)
+
+	network := pfcptransport.NewMemoryNetwork()
+	defer network.Close()
+	up := pfcp_networking.NewPFCPEntityUP(upAddr.IP.String())
+	if err := up.SetTransport(network); err != nil {
+		return nil, err
+	}
+	if opts.Setup != nil {
+		if err := opts.Setup(up); err != nil {
+			return nil, err
+		}
+	}
+	if err := up.Start(); err != nil {
+		return nil, err
+	}
+	conn, err := network.ListenUDP(cpAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	report := &Report{
+		CPAddr:    cpAddr,
+		UPAddr:    upAddr,
+		Exchanges: make([]*Exchange, 0, len(exchanges)),
+	}
+
+	// The entity rejects session related messages without association:
+	// when the capture starts after the association setup, an association is created first.
+	if len(exchanges) > 0 && exchanges[0].request[1] != message.MsgTypeAssociationSetupRequest {
+		asr := message.NewAssociationSetupRequest(pfcputil.SEQUENCE_NUMBER_MAX, ie.NewNodeIDHeuristic(cpAddr.IP.String()), ie.NewRecoveryTimeStamp(time.Now()))
+		b := make([]byte, asr.MarshalLen())
+		if err := asr.MarshalTo(b); err != nil {
+			return nil, err
+		}
+		if _, err := exchange(conn, upAddr, b, opts.ResponseTimeout); err != nil {
+			return nil, fmt.Errorf("Association setup failed: %w", err)
+		}
+	}
+
+	// captured UP SEID -> actual UP SEID
+	seids := make(map[uint64]uint64)
+	for _, ex := range exchanges {
+		request, err := rewriteSEID(ex.request, seids)
+		if err != nil {
+			return nil, err
+		}
+		e := &Exchange{}
+		if e.Request, err = message.Parse(ex.request); err != nil {
+			return nil, err
+		}
+		if ex.response != nil {
+			if e.Expected, err = message.Parse(ex.response); err != nil {
+				return nil, err
+			}
+		}
+		actual, err := exchange(conn, upAddr, request, opts.ResponseTimeout)
+		switch {
+		case err != nil && e.Expected != nil:
+			e.Err = err
+		case err == nil:
+			if e.Actual, err = message.Parse(actual); err != nil {
+				return nil, err
+			}
+			if e.Expected == nil {
+				e.Err = fmt.Errorf("unexpected %s", e.Actual.MessageTypeName())
+			} else {
+				e.Err = compare(ex.response, actual, opts.CompareIEs)
+				mapSEIDs(e.Expected, e.Actual, seids)
+			}
+		}
+		report.Exchanges = append(report.Exchanges, e)
+	}
+	return report, nil
+}
+
+// Find addresses of CP and UP functions
+func findEndpoints(datagrams []*pfcpcapture.Datagram) (cp *net.UDPAddr, up *net.UDPAddr, err error) {
+	for _, t := range []uint8{message.MsgTypeSessionEstablishmentRequest, message.MsgTypeAssociationSetupRequest} {
+		for _, d := range datagrams {
+			if isPFCP(d) && d.Payload[1] == t && strconv.Itoa(d.Dst.Port) == pfcputil.PFCP_PORT {
+				return d.Src, d.Dst, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("No PFCP Session Establishment Request or Association Setup Request in capture")
+}
+
+// Returns true if datagram looks like a PFCP message
+func isPFCP(d *pfcpcapture.Datagram) bool {
+	port := strconv.Itoa(d.Src.Port)
+	dport := strconv.Itoa(d.Dst.Port)
+	return (port == pfcputil.PFCP_PORT || dport == pfcputil.PFCP_PORT) && len(d.Payload) >= 4 && d.Payload[0]>>5 == 1
+}
+
+func sameAddr(a *net.UDPAddr, b *net.UDPAddr) bool {
+	return a.IP.Equal(b.IP) && a.Port == b.Port
+}
+
+// Returns Requests sent by the CP function to the UP function, with their Response
+func extractExchanges(datagrams []*pfcpcapture.Datagram, cp *net.UDPAddr, up *net.UDPAddr) []*capturedExchange {
+	exchanges := make([]*capturedExchange, 0)
+	for i, d := range datagrams {
+		if !isPFCP(d) || !sameAddr(d.Src, cp) || !sameAddr(d.Dst, up) || !pfcputil.IsMessageTypeRequest(d.Payload[1]) {
+			continue
+		}
+		h, err := message.ParseHeader(d.Payload)
+		if err != nil {
+			continue
+		}
+		if len(exchanges) > 0 && bytes.Equal(exchanges[len(exchanges)-1].request, d.Payload) {
+			// retransmission
+			continue
+		}
+		ex := &capturedExchange{request: d.Payload}
+		for _, r := range datagrams[i+1:] {
+			if !isPFCP(r) || !sameAddr(r.Src, up) || !sameAddr(r.Dst, cp) || !pfcputil.IsMessageTypeResponse(r.Payload[1]) {
+				continue
+			}
+			if rh, err := message.ParseHeader(r.Payload); err == nil && rh.SequenceNumber == h.SequenceNumber {
+				ex.response = r.Payload
+				break
+			}
+		}
+		exchanges = append(exchanges, ex)
+	}
+	return exchanges
+}
+
+// Send a Request, and wait for the Response with the same sequence number
+func exchange(conn net.PacketConn, up *net.UDPAddr, request []byte, timeout time.Duration) ([]byte, error) {
+	h, err := message.ParseHeader(request)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteTo(request, up); err != nil {
+		return nil, err
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, pfcputil.DEFAULT_MTU)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return nil, fmt.Errorf("no response: %w", err)
+		}
+		rh, err := message.ParseHeader(buf[:n])
+		if err != nil || !pfcputil.IsMessageTypeResponse(rh.MessageType()) || rh.SequenceNumber != h.SequenceNumber {
+			continue
+		}
+		return append([]byte{}, buf[:n]...), nil
+	}
+}
+
+// Replace captured UP SEID by actual UP SEID in the header of a Request
+func rewriteSEID(request []byte, seids map[uint64]uint64) ([]byte, error) {
+	h, err := message.ParseHeader(request)
+	if err != nil {
+		return nil, err
+	}
+	if !h.HasSEID() {
+		return request, nil
+	}
+	if seid, exists := seids[h.SEID]; exists {
+		h.SetSEID(seid)
+		return h.Marshal()
+	}
+	return request, nil
+}
+
+// Record mapping between captured and actual UP F-SEIDs
+func mapSEIDs(expected message.Message, actual message.Message, seids map[uint64]uint64) {
+	e, ok := expected.(*message.SessionEstablishmentResponse)
+	if !ok || e.UPFSEID == nil {
+		return
+	}
+	a, ok := actual.(*message.SessionEstablishmentResponse)
+	if !ok || a.UPFSEID == nil {
+		return
+	}
+	ef, err := e.UPFSEID.FSEID()
+	if err != nil {
+		return
+	}
+	af, err := a.UPFSEID.FSEID()
+	if err != nil {
+		return
+	}
+	seids[ef.SEID] = af.SEID
+}
+
+// Compare captured and actual Responses
+func compare(expected []byte, actual []byte, compareIEs bool) error {
+	if expected[1] != actual[1] {
+		return fmt.Errorf("expected %s, got %s", pfcputil.MessageTypeName(expected[1]), pfcputil.MessageTypeName(actual[1]))
+	}
+	eies, err := payloadIEs(expected)
+	if err != nil {
+		return err
+	}
+	aies, err := payloadIEs(actual)
+	if err != nil {
+		return err
+	}
+	for _, t := range []uint16{ie.Cause, ie.OffendingIE} {
+		e := findIE(eies, t)
+		a := findIE(aies, t)
+		switch {
+		case e == nil && a == nil:
+		case e == nil || a == nil || !bytes.Equal(e.Payload, a.Payload):
+			return fmt.Errorf("expected %s, got %s", describeIE(t, e), describeIE(t, a))
+		}
+	}
+	if !compareIEs {
+		return nil
+	}
+	es := normalise(eies)
+	as := normalise(aies)
+	if len(es) != len(as) {
+		return fmt.Errorf("expected %d IEs, got %d", len(es), len(as))
+	}
+	for i := range es {
+		if es[i] != as[i] {
+			return fmt.Errorf("IEs differ")
+		}
+	}
+	return nil
+}
+
+func payloadIEs(b []byte) ([]*ie.IE, error) {
+	h, err := message.ParseHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	return ie.ParseMultiIEs(h.Payload)
+}
+
+func findIE(ies []*ie.IE, t uint16) *ie.IE {
+	for _, i := range ies {
+		if i.Type == t {
+			return i
+		}
+	}
+	return nil
+}
+
+func describeIE(t uint16, i *ie.IE) string {
+	if i == nil {
+		return "no IE " + strconv.Itoa(int(t))
+	}
+	switch t {
+	case ie.Cause:
+		if c, err := i.Cause(); err == nil {
+			return "cause " + strconv.Itoa(int(c))
+		}
+	case ie.OffendingIE:
+		if o, err := i.OffendingIE(); err == nil {
+			return "offending IE " + strconv.Itoa(int(o))
+		}
+	}
+	return fmt.Sprintf("IE %d %x", t, i.Payload)
+}
+
+// Returns sorted serialised IEs with timestamps and SEIDs zeroed
+func normalise(ies []*ie.IE) []string {
+	s := make([]string, 0, len(ies))
+	for _, i := range ies {
+		payload := append([]byte{}, i.Payload...)
+		switch i.Type {
+		case ie.RecoveryTimeStamp, ie.StartTime, ie.EndTime, ie.TimeOfFirstPacket, ie.TimeOfLastPacket:
+			payload = make([]byte, len(payload))
+		case ie.FSEID:
+			// flags (1 octet), then SEID (8 octets)
+			for j := 1; j < 9 && j < len(payload); j++ {
+				payload[j] = 0
+			}
+		}
+		s = append(s, strconv.Itoa(int(i.Type))+":"+string(payload))
+	}
+	sort.Strings(s)
+	return s
+}
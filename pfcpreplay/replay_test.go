@@ -0,0 +1,121 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcpreplay_test
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	pfcp_networking "github.com/nextmn/go-pfcp-networking/pfcp"
+	"github.com/nextmn/go-pfcp-networking/pfcpreplay"
+	"github.com/nextmn/go-pfcp-networking/pfcptest"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// testdata/smf.pcap is a capture of a CP function (10.0.0.1) exchanging with a UP function (10.0.0.2)
+// with a TEID pool on Network Instance "internet":
+// association setup, heartbeat, session establishment, modification and deletion,
+// and Requests rejected for a missing CP F-SEID and for unknown sessions
+const testCapture = "testdata/smf.pcap"
+
+// Configure the entity like the UP function of the capture, with the datapath of a FakeUP
+func setupUP(entity *pfcp_networking.PFCPEntityUP) error {
+	entity.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	pool, err := pfcp_networking.NewTEIDPool(net.ParseIP("10.0.0.2"), nil, 1, 1000)
+	if err != nil {
+		return err
+	}
+	if err := entity.AddTEIDPool("internet", ie.SrcInterfaceAccess, pool); err != nil {
+		return err
+	}
+	return entity.SetDatapath(pfcptest.NewDatapath())
+}
+
+// Returns the Cause of a Response, or nil
+func causeOf(m message.Message) *ie.IE {
+	switch res := m.(type) {
+	case *message.AssociationSetupResponse:
+		return res.Cause
+	case *message.SessionEstablishmentResponse:
+		return res.Cause
+	case *message.SessionModificationResponse:
+		return res.Cause
+	case *message.SessionDeletionResponse:
+		return res.Cause
+	}
+	return nil
+}
+
+func TestReplayFile(t *testing.T) {
+	expected := []struct {
+		msgType uint8
+		cause   uint8 // 0 when the Response has no Cause
+	}{
+		{msgType: message.MsgTypeAssociationSetupResponse, cause: ie.CauseRequestAccepted},
+		{msgType: message.MsgTypeHeartbeatResponse},
+		{msgType: message.MsgTypeSessionEstablishmentResponse, cause: ie.CauseRequestAccepted},
+		{msgType: message.MsgTypeSessionModificationResponse, cause: ie.CauseRequestAccepted},
+		{msgType: message.MsgTypeSessionEstablishmentResponse, cause: ie.CauseMandatoryIEMissing},
+		{msgType: message.MsgTypeSessionModificationResponse, cause: ie.CauseSessionContextNotFound},
+		{msgType: message.MsgTypeSessionDeletionResponse, cause: ie.CauseRequestAccepted},
+		{msgType: message.MsgTypeSessionDeletionResponse, cause: ie.CauseSessionContextNotFound},
+	}
+	report, err := pfcpreplay.ReplayFile(testCapture, pfcpreplay.Options{CompareIEs: true, Setup: setupUP})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := report.Err(); err != nil {
+		t.Error(err)
+	}
+	if len(report.Exchanges) != len(expected) {
+		t.Fatalf("got %d exchanges, expected %d", len(report.Exchanges), len(expected))
+	}
+	for i, e := range report.Exchanges {
+		if e.Actual == nil {
+			t.Errorf("exchange %d: no Response to %s", i, e.Request.MessageTypeName())
+			continue
+		}
+		if e.Actual.MessageType() != expected[i].msgType {
+			t.Errorf("exchange %d: got %s, expected message type %d", i, e.Actual.MessageTypeName(), expected[i].msgType)
+			continue
+		}
+		cause := causeOf(e.Actual)
+		switch {
+		case cause == nil && expected[i].cause == 0:
+		case cause == nil:
+			t.Errorf("exchange %d: Cause is missing, expected %d", i, expected[i].cause)
+		default:
+			if c, err := cause.Cause(); err != nil || c != expected[i].cause {
+				t.Errorf("exchange %d: got cause %d (%v), expected %d", i, c, err, expected[i].cause)
+			}
+		}
+	}
+}
+
+// Without TEID pool, the session cannot be established, and later exchanges of the session differ
+func TestReplayFileMismatch(t *testing.T) {
+	report, err := pfcpreplay.ReplayFile(testCapture, pfcpreplay.Options{Setup: func(entity *pfcp_networking.PFCPEntityUP) error {
+		entity.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+		return entity.SetDatapath(pfcptest.NewDatapath())
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Err() == nil {
+		t.Fatal("Responses match without TEID pool")
+	}
+	for i, e := range report.Exchanges {
+		mismatch := i == 2 || i == 3 || i == 6
+		if mismatch && e.Err == nil {
+			t.Errorf("exchange %d: Response matches", i)
+		} else if !mismatch && e.Err != nil {
+			t.Errorf("exchange %d: %v", i, e.Err)
+		}
+	}
+}
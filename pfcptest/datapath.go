@@ -0,0 +1,260 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcptest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+)
+
+var _ api.DatapathInterface = (*Datapath)(nil)
+
+// Operation of a DatapathInterface
+type DatapathOperation uint8
+
+const (
+	DatapathInstallSession DatapathOperation = iota
+	DatapathUpdateSession
+	DatapathRemoveSession
+)
+
+func (o DatapathOperation) String() string {
+	switch o {
+	case DatapathInstallSession:
+		return "InstallSession"
+	case DatapathUpdateSession:
+		return "UpdateSession"
+	case DatapathRemoveSession:
+		return "RemoveSession"
+	default:
+		return fmt.Sprintf("DatapathOperation(%d)", uint8(o))
+	}
+}
+
+// A call received by a Datapath
+type DatapathCall struct {
+	Operation DatapathOperation
+	SEID      api.SEID    // local SEID of the session
+	PDRs      []api.PDRID // PDRs installed, created or updated
+	FARs      []api.FARID // FARs installed, created or updated
+	Err       error       // injected failure returned to the entity, nil on success
+}
+
+// Rules of a session installed in a Datapath
+type DatapathSession struct {
+	PDRs map[api.PDRID]api.PDRInterface
+	FARs map[api.FARID]api.FARInterface
+}
+
+// A Datapath is an in-memory datapath recording every call, used to test UP functions.
+// Sessions are identified by their local SEID.
+// Failures can be injected: a failing call leaves the Datapath unchanged.
+type Datapath struct {
+	mu       sync.Mutex
+	sessions map[api.SEID]*DatapathSession
+	calls    []DatapathCall
+	failures map[DatapathOperation]int // number of calls still failing, or -1 for every call
+}
+
+// Create an empty Datapath
+func NewDatapath() *Datapath {
+	return &Datapath{
+		mu:       sync.Mutex{},
+		sessions: make(map[api.SEID]*DatapathSession),
+		calls:    make([]DatapathCall, 0),
+		failures: make(map[DatapathOperation]int),
+	}
+}
+
+// Make the next calls of this operation fail.
+// If times <= 0, every following call fails.
+func (d *Datapath) Fail(op DatapathOperation, times int) {
+	if times <= 0 {
+		times = -1
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failures[op] = times
+}
+
+// Remove injected failures
+func (d *Datapath) ClearFailures() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failures = make(map[DatapathOperation]int)
+}
+
+// Returns an injected failure for this operation, or nil.
+// Lock must be held by the caller.
+func (d *Datapath) nextFailure(op DatapathOperation) error {
+	times, exists := d.failures[op]
+	if !exists {
+		return nil
+	}
+	switch {
+	case times == 1:
+		delete(d.failures, op)
+	case times > 1:
+		d.failures[op] = times - 1
+	}
+	return fmt.Errorf("Injected %s failure", op)
+}
+
+func (d *Datapath) InstallSession(session api.PFCPSessionInterface, pdrs api.PDRMapInterface, fars api.FARMapInterface) error {
+	seid, err := session.LocalSEID()
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	call := DatapathCall{Operation: DatapathInstallSession, SEID: seid}
+	s := &DatapathSession{
+		PDRs: make(map[api.PDRID]api.PDRInterface),
+		FARs: make(map[api.FARID]api.FARInterface),
+	}
+	addRules(s, &call, pdrs, fars)
+	if _, exists := d.sessions[seid]; exists {
+		call.Err = fmt.Errorf("Session %d is already installed", seid)
+	} else {
+		call.Err = d.nextFailure(DatapathInstallSession)
+	}
+	d.calls = append(d.calls, call)
+	if call.Err != nil {
+		return call.Err
+	}
+	d.sessions[seid] = s
+	return nil
+}
+
+func (d *Datapath) UpdateSession(session api.PFCPSessionInterface, createpdrs api.PDRMapInterface, createfars api.FARMapInterface, updatepdrs api.PDRMapInterface, updatefars api.FARMapInterface) error {
+	seid, err := session.LocalSEID()
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	call := DatapathCall{Operation: DatapathUpdateSession, SEID: seid}
+	// rules are applied on a copy, which replaces the session on success
+	s := &DatapathSession{
+		PDRs: make(map[api.PDRID]api.PDRInterface),
+		FARs: make(map[api.FARID]api.FARInterface),
+	}
+	if old, exists := d.sessions[seid]; exists {
+		for id, pdr := range old.PDRs {
+			s.PDRs[id] = pdr
+		}
+		for id, far := range old.FARs {
+			s.FARs[id] = far
+		}
+		addRules(s, &call, createpdrs, createfars)
+		addRules(s, &call, updatepdrs, updatefars)
+		call.Err = d.nextFailure(DatapathUpdateSession)
+	} else {
+		call.Err = fmt.Errorf("Session %d is not installed", seid)
+	}
+	d.calls = append(d.calls, call)
+	if call.Err != nil {
+		return call.Err
+	}
+	d.sessions[seid] = s
+	return nil
+}
+
+func (d *Datapath) RemoveSession(session api.PFCPSessionInterface) error {
+	seid, err := session.LocalSEID()
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	call := DatapathCall{Operation: DatapathRemoveSession, SEID: seid}
+	if _, exists := d.sessions[seid]; exists {
+		call.Err = d.nextFailure(DatapathRemoveSession)
+	} else {
+		call.Err = fmt.Errorf("Session %d is not installed", seid)
+	}
+	d.calls = append(d.calls, call)
+	if call.Err != nil {
+		return call.Err
+	}
+	delete(d.sessions, seid)
+	return nil
+}
+
+// Add rules to a session, and to the call recording them
+func addRules(s *DatapathSession, call *DatapathCall, pdrs api.PDRMapInterface, fars api.FARMapInterface) {
+	pdrs.Foreach(func(pdr api.PDRInterface) error {
+		if id, err := pdr.ID(); err == nil {
+			s.PDRs[id] = pdr
+			call.PDRs = append(call.PDRs, id)
+		}
+		return nil
+	})
+	fars.Foreach(func(far api.FARInterface) error {
+		if id, err := far.ID(); err == nil {
+			s.FARs[id] = far
+			call.FARs = append(call.FARs, id)
+		}
+		return nil
+	})
+}
+
+// Returns calls received by the Datapath, in order
+func (d *Datapath) Calls() []DatapathCall {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	calls := make([]DatapathCall, len(d.calls))
+	copy(calls, d.calls)
+	return calls
+}
+
+// Forget recorded calls
+func (d *Datapath) ResetCalls() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = make([]DatapathCall, 0)
+}
+
+// Returns rules of an installed session
+func (d *Datapath) Session(seid api.SEID) (*DatapathSession, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, exists := d.sessions[seid]
+	if !exists {
+		return nil, fmt.Errorf("Session %d is not installed", seid)
+	}
+	c := &DatapathSession{
+		PDRs: make(map[api.PDRID]api.PDRInterface, len(s.PDRs)),
+		FARs: make(map[api.FARID]api.FARInterface, len(s.FARs)),
+	}
+	for id, pdr := range s.PDRs {
+		c.PDRs[id] = pdr
+	}
+	for id, far := range s.FARs {
+		c.FARs[id] = far
+	}
+	return c, nil
+}
+
+// Returns local SEIDs of installed sessions
+func (d *Datapath) SEIDs() []api.SEID {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	seids := make([]api.SEID, 0, len(d.sessions))
+	for seid := range d.sessions {
+		seids = append(seids, seid)
+	}
+	return seids
+}
+
+// Remove every installed session, as a restart of the datapath would do
+func (d *Datapath) Flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sessions = make(map[api.SEID]*DatapathSession)
+}
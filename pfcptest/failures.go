@@ -0,0 +1,160 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcptest
+
+import (
+	"fmt"
+	"sync"
+
+	pfcp_networking "github.com/nextmn/go-pfcp-networking/pfcp"
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/nextmn/go-pfcp-networking/pfcputil"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+type failure struct {
+	drop  bool  // no response is sent
+	cause uint8 // when drop is false, Request is rejected with this cause
+	times int   // number of Requests still affected, or -1 for every Request
+}
+
+// Failures injected in the handling of Requests received by a fake function.
+// Failures are applied before the default handlers: a dropped or rejected
+// Request has no effect on the entity.
+type Failures struct {
+	mu       sync.Mutex
+	failures map[pfcputil.MessageType]*failure
+}
+
+func newFailures() *Failures {
+	return &Failures{
+		mu:       sync.Mutex{},
+		failures: make(map[pfcputil.MessageType]*failure),
+	}
+}
+
+func (f *Failures) set(msgType pfcputil.MessageType, fail *failure) {
+	if fail.times <= 0 {
+		fail.times = -1
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[msgType] = fail
+}
+
+// Reject the next Requests of this type with cause.
+// If times <= 0, every following Request is rejected.
+// Heartbeat Responses have no cause: Heartbeat Requests cannot be rejected.
+func (f *Failures) Reject(msgType pfcputil.MessageType, cause uint8, times int) error {
+	if msgType == message.MsgTypeHeartbeatRequest {
+		return fmt.Errorf("Heartbeat Requests cannot be rejected")
+	}
+	if !pfcputil.IsMessageTypeRequest(msgType) {
+		return fmt.Errorf("Only Requests can be rejected")
+	}
+	f.set(msgType, &failure{cause: cause, times: times})
+	return nil
+}
+
+// Do not respond to the next Requests of this type.
+// If times <= 0, no following Request is answered.
+func (f *Failures) Drop(msgType pfcputil.MessageType, times int) error {
+	if !pfcputil.IsMessageTypeRequest(msgType) {
+		return fmt.Errorf("Only Requests can be dropped")
+	}
+	f.set(msgType, &failure{drop: true, times: times})
+	return nil
+}
+
+// Remove failures injected for this type of Request
+func (f *Failures) Clear(msgType pfcputil.MessageType) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.failures, msgType)
+}
+
+// Remove every injected failure
+func (f *Failures) ClearAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = make(map[pfcputil.MessageType]*failure)
+}
+
+// Returns the failure to apply to a Request of this type, if any
+func (f *Failures) next(msgType pfcputil.MessageType) (fail failure, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	current, exists := f.failures[msgType]
+	if !exists {
+		return failure{}, false
+	}
+	if current.times > 0 {
+		current.times--
+		if current.times == 0 {
+			delete(f.failures, msgType)
+		}
+	}
+	return *current, true
+}
+
+// Middleware applying injected failures
+func (f *Failures) middleware(next pfcp_networking.PFCPMessageHandler) pfcp_networking.PFCPMessageHandler {
+	return func(msg pfcp_networking.ReceivedMessage) error {
+		fail, ok := f.next(msg.MessageType())
+		if !ok {
+			return next(msg)
+		}
+		if fail.drop {
+			msg.Logger().Debug("Dropping Request (injected failure)")
+			return nil
+		}
+		msg.Logger().Debug("Rejecting Request (injected failure)")
+		res, err := newRejection(msg, fail.cause)
+		if err != nil {
+			return err
+		}
+		return msg.ReplyTo(res)
+	}
+}
+
+// Create a Response to msg with the given cause
+func newRejection(msg pfcp_networking.ReceivedMessage, cause uint8) (message.Message, error) {
+	nodeID := msg.Entity.NodeID()
+	seq := msg.Sequence()
+	c := ie.NewCause(cause)
+	var rseid api.SEID = 0
+	if msg.Session != nil {
+		if seid, err := msg.Session.RemoteSEID(); err == nil {
+			rseid = seid
+		}
+	}
+	switch m := msg.Message.(type) {
+	case *message.AssociationSetupRequest:
+		return message.NewAssociationSetupResponse(seq, nodeID, c, msg.Entity.RecoveryTimeStamp()), nil
+	case *message.AssociationUpdateRequest:
+		return message.NewAssociationUpdateResponse(seq, nodeID, c), nil
+	case *message.AssociationReleaseRequest:
+		return message.NewAssociationReleaseResponse(seq, nodeID, c), nil
+	case *message.NodeReportRequest:
+		return message.NewNodeReportResponse(seq, nodeID, c, nil), nil
+	case *message.SessionEstablishmentRequest:
+		if m.CPFSEID != nil {
+			if fseid, err := m.CPFSEID.FSEID(); err == nil {
+				rseid = fseid.SEID
+			}
+		}
+		return message.NewSessionEstablishmentResponse(0, 0, rseid, seq, 0, nodeID, c), nil
+	case *message.SessionModificationRequest:
+		return message.NewSessionModificationResponse(0, 0, rseid, seq, 0, c), nil
+	case *message.SessionDeletionRequest:
+		return message.NewSessionDeletionResponse(0, 0, rseid, seq, 0, c), nil
+	case *message.SessionReportRequest:
+		return message.NewSessionReportResponse(0, 0, rseid, seq, 0, c), nil
+	default:
+		return nil, fmt.Errorf("Cannot reject %s", msg.MessageTypeName())
+	}
+}
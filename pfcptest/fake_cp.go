@@ -0,0 +1,209 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcptest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	pfcp_networking "github.com/nextmn/go-pfcp-networking/pfcp"
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/wmnsk/go-pfcp/ie"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+// A FakeCP is a CP function, used to test UP functions.
+// Sessions are established, modified and deleted with messages built from
+// the IEs given by the test, so invalid Requests can be sent as well.
+type FakeCP struct {
+	nodeID   string
+	ip       net.IP
+	options  Options
+	recorder *Recorder
+	failures *Failures
+	mu       sync.Mutex
+	entity   *pfcp_networking.PFCPEntityCP
+}
+
+// Create a FakeCP; nodeID must be an IP Address
+func NewFakeCP(nodeID string, options Options) (*FakeCP, error) {
+	ip, err := parseNodeID(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	f := &FakeCP{
+		nodeID:   nodeID,
+		ip:       ip,
+		options:  options,
+		recorder: NewRecorder(),
+		failures: newFailures(),
+	}
+	if err := f.newEntity(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FakeCP) newEntity() error {
+	entity := pfcp_networking.NewPFCPEntityCP(f.nodeID)
+	if err := f.options.apply(entity, f.recorder); err != nil {
+		return err
+	}
+	entity.AddMiddleware(f.failures.middleware)
+	f.entity = entity
+	return nil
+}
+
+// Start the FakeCP
+func (f *FakeCP) Start() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.entity.Start()
+}
+
+// Stop the FakeCP
+func (f *FakeCP) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.entity.Close()
+}
+
+// Simulate a restart: associations are lost,
+// and the new entity has a new Recovery Time Stamp.
+// Recovery Time Stamps have a resolution of one second:
+// when using a manual clock, advance it before restarting.
+func (f *FakeCP) Restart() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.entity.Close(); err != nil {
+		return err
+	}
+	if err := f.newEntity(); err != nil {
+		return err
+	}
+	return f.entity.Start()
+}
+
+// Returns the current entity (it changes on Restart)
+func (f *FakeCP) Entity() *pfcp_networking.PFCPEntityCP {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.entity
+}
+
+// Returns the Recorder of messages sent and received by the FakeCP
+func (f *FakeCP) Recorder() *Recorder {
+	return f.recorder
+}
+
+// Returns failures injected in the FakeCP
+func (f *FakeCP) Failures() *Failures {
+	return f.failures
+}
+
+// Perform the PFCP Association Setup Procedure with a UP function
+func (f *FakeCP) Associate(upNodeID string) (api.PFCPAssociationInterface, error) {
+	return f.Entity().NewEstablishedPFCPAssociation(ie.NewNodeIDHeuristic(upNodeID))
+}
+
+// Returns the association with a UP function
+func (f *FakeCP) Association(upNodeID string) (api.PFCPAssociationInterface, error) {
+	return f.Entity().GetPFCPAssociation(upNodeID)
+}
+
+// Send a Session Establishment Request to a UP function.
+// NodeID and CP F-SEID IEs are added to ies.
+// The returned session is nil when the Request is not accepted.
+func (f *FakeCP) EstablishSession(upNodeID string, ies ...*ie.IE) (*Session, *message.SessionEstablishmentResponse, error) {
+	association, err := f.Association(upNodeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	seid := association.GetNextSEID()
+	var fseid *ie.IE
+	if ip4 := f.ip.To4(); ip4 != nil {
+		fseid = ie.NewFSEID(seid, ip4, nil)
+	} else {
+		fseid = ie.NewFSEID(seid, nil, f.ip.To16())
+	}
+	req := message.NewSessionEstablishmentRequest(0, 0, 0, 0, 0, append([]*ie.IE{ie.NewNodeIDHeuristic(f.nodeID), fseid}, ies...)...)
+	resp, err := send(association, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, ok := resp.(*message.SessionEstablishmentResponse)
+	if !ok {
+		return nil, nil, fmt.Errorf("Unexpected %s", resp.MessageTypeName())
+	}
+	if res.Cause == nil {
+		return nil, res, fmt.Errorf("Cause is missing")
+	}
+	cause, err := res.Cause.Cause()
+	if err != nil {
+		return nil, res, err
+	}
+	if cause != ie.CauseRequestAccepted {
+		return nil, res, nil
+	}
+	if res.UPFSEID == nil {
+		return nil, res, fmt.Errorf("UP F-SEID is missing")
+	}
+	upFseid, err := res.UPFSEID.FSEID()
+	if err != nil {
+		return nil, res, err
+	}
+	return &Session{
+		association: association,
+		LocalSEID:   seid,
+		RemoteSEID:  upFseid.SEID,
+	}, res, nil
+}
+
+// Send a Request and wait for the Response.
+// Rejections are not errors: the Response is returned to the test.
+func send(association api.PFCPAssociationInterface, req message.Message) (message.Message, error) {
+	resp, err := association.Send(req)
+	var rejection *pfcp_networking.CauseError
+	if err != nil && !(errors.As(err, &rejection) && resp != nil) {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// A Session established by a FakeCP
+type Session struct {
+	association api.PFCPAssociationInterface
+	LocalSEID   api.SEID // SEID allocated by the FakeCP
+	RemoteSEID  api.SEID // SEID allocated by the UP function
+}
+
+// Send a Session Modification Request with the given IEs
+func (s *Session) Modify(ies ...*ie.IE) (*message.SessionModificationResponse, error) {
+	resp, err := send(s.association, message.NewSessionModificationRequest(0, 0, s.RemoteSEID, 0, 0, ies...))
+	if err != nil {
+		return nil, err
+	}
+	res, ok := resp.(*message.SessionModificationResponse)
+	if !ok {
+		return nil, fmt.Errorf("Unexpected %s", resp.MessageTypeName())
+	}
+	return res, nil
+}
+
+// Send a Session Deletion Request
+func (s *Session) Delete() (*message.SessionDeletionResponse, error) {
+	resp, err := send(s.association, message.NewSessionDeletionRequest(0, 0, s.RemoteSEID, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+	res, ok := resp.(*message.SessionDeletionResponse)
+	if !ok {
+		return nil, fmt.Errorf("Unexpected %s", resp.MessageTypeName())
+	}
+	return res, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcptest
+
+import (
+	"sync"
+
+	pfcp_networking "github.com/nextmn/go-pfcp-networking/pfcp"
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+)
+
+// A FakeUP is a UP function with default handlers, used to test CP functions
+type FakeUP struct {
+	nodeID   string
+	options  Options
+	recorder *Recorder
+	failures *Failures
+	datapath *Datapath
+	mu       sync.Mutex
+	entity   *pfcp_networking.PFCPEntityUP
+}
+
+// Create a FakeUP; nodeID must be an IP Address
+func NewFakeUP(nodeID string, options Options) (*FakeUP, error) {
+	if _, err := parseNodeID(nodeID); err != nil {
+		return nil, err
+	}
+	f := &FakeUP{
+		nodeID:   nodeID,
+		options:  options,
+		recorder: NewRecorder(),
+		failures: newFailures(),
+		datapath: NewDatapath(),
+	}
+	if err := f.newEntity(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FakeUP) newEntity() error {
+	entity := pfcp_networking.NewPFCPEntityUP(f.nodeID)
+	if err := f.options.apply(entity, f.recorder); err != nil {
+		return err
+	}
+	entity.AddMiddleware(f.failures.middleware)
+	if err := entity.SetDatapath(f.datapath); err != nil {
+		return err
+	}
+	f.entity = entity
+	return nil
+}
+
+// Start the FakeUP
+func (f *FakeUP) Start() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.entity.Start()
+}
+
+// Stop the FakeUP
+func (f *FakeUP) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.entity.Close()
+}
+
+// Simulate a restart: associations and sessions are lost (also in the Datapath),
+// and the new entity has a new Recovery Time Stamp.
+// Recovery Time Stamps have a resolution of one second:
+// when using a manual clock, advance it before restarting.
+func (f *FakeUP) Restart() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.entity.Close(); err != nil {
+		return err
+	}
+	f.datapath.Flush()
+	if err := f.newEntity(); err != nil {
+		return err
+	}
+	return f.entity.Start()
+}
+
+// Returns the current entity (it changes on Restart)
+func (f *FakeUP) Entity() *pfcp_networking.PFCPEntityUP {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.entity
+}
+
+// Returns the Recorder of messages sent and received by the FakeUP
+func (f *FakeUP) Recorder() *Recorder {
+	return f.recorder
+}
+
+// Returns failures injected in the FakeUP
+func (f *FakeUP) Failures() *Failures {
+	return f.failures
+}
+
+// Returns the Datapath of the FakeUP, where rules of sessions are installed
+func (f *FakeUP) Datapath() *Datapath {
+	return f.datapath
+}
+
+// Returns the sessions established on the FakeUP
+func (f *FakeUP) Sessions() []api.PFCPSessionInterface {
+	return f.Entity().GetPFCPSessions()
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Package pfcptest provides a scriptable fake CP function and a fake UP function,
+// built on the real entity types, for integration tests of PFCP functions.
+// Every message they exchange is recorded, and failures (reject causes,
+// missing responses, restarts) can be injected.
+package pfcptest
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+)
+
+// Options of fake functions
+type Options struct {
+	// Transport of the entity; default is UDP sockets of the operating system
+	Transport api.TransportInterface
+	// Clock of the entity; default is the real clock
+	Clock api.ClockInterface
+	// Logger of the entity; default is slog.Default()
+	Logger *slog.Logger
+}
+
+// Set options on an entity
+func (o Options) apply(entity interface {
+	SetTransport(api.TransportInterface) error
+	SetClock(api.ClockInterface) error
+	SetLogger(*slog.Logger)
+	SetRecorder(api.RecorderInterface)
+}, recorder *Recorder) error {
+	if o.Transport != nil {
+		if err := entity.SetTransport(o.Transport); err != nil {
+			return err
+		}
+	}
+	if o.Clock != nil {
+		if err := entity.SetClock(o.Clock); err != nil {
+			return err
+		}
+	}
+	if o.Logger != nil {
+		entity.SetLogger(o.Logger)
+	}
+	entity.SetRecorder(recorder)
+	return nil
+}
+
+// Fake functions require a NodeID which is an IP Address
+func parseNodeID(nodeID string) (net.IP, error) {
+	ip := net.ParseIP(nodeID)
+	if ip == nil {
+		return nil, fmt.Errorf("NodeID of a fake function must be an IP Address")
+	}
+	return ip, nil
+}
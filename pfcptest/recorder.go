@@ -0,0 +1,86 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcptest
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/wmnsk/go-pfcp/message"
+)
+
+var _ api.RecorderInterface = (*Recorder)(nil)
+
+// A message sent or received by a fake function
+type RecordedMessage struct {
+	Time    time.Time
+	Src     net.Addr
+	Dst     net.Addr
+	Message message.Message
+}
+
+// A Recorder keeps every PFCP message sent or received by an entity
+type Recorder struct {
+	mu       sync.Mutex
+	messages []RecordedMessage
+}
+
+// Create a new Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		mu:       sync.Mutex{},
+		messages: make([]RecordedMessage, 0),
+	}
+}
+
+// Record a datagram; datagrams which are not PFCP messages are ignored
+func (r *Recorder) Record(src, dst net.Addr, payload []byte) {
+	b := make([]byte, len(payload))
+	copy(b, payload)
+	msg, err := message.Parse(b)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, RecordedMessage{
+		Time:    time.Now(),
+		Src:     src,
+		Dst:     dst,
+		Message: msg,
+	})
+}
+
+// Returns recorded messages, in order
+func (r *Recorder) Messages() []RecordedMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := make([]RecordedMessage, len(r.messages))
+	copy(messages, r.messages)
+	return messages
+}
+
+// Returns recorded messages of a given type, in order
+func (r *Recorder) MessagesOfType(msgType uint8) []RecordedMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := make([]RecordedMessage, 0)
+	for _, m := range r.messages {
+		if m.Message.MessageType() == msgType {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// Forget recorded messages
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = make([]RecordedMessage, 0)
+}
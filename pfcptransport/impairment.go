@@ -0,0 +1,132 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package pfcptransport
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+)
+
+var _ api.TransportInterface = (*ImpairedTransport)(nil)
+
+// Impairments applied to datagrams sent through an ImpairedTransport.
+// Probabilities are between 0 and 1.
+type Impairment struct {
+	Loss        float64       // probability a datagram is dropped
+	Duplication float64       // probability a datagram is sent twice
+	Reordering  float64       // probability a datagram is held back by ReorderDelay, letting next datagrams overtake it
+	Delay       time.Duration // fixed delay of every datagram
+	Jitter      time.Duration // random delay added to Delay, between 0 and Jitter
+	// Extra delay of reordered datagrams; default is Delay + Jitter + 10ms
+	ReorderDelay time.Duration
+	// Seed of the random generator, for reproducible runs
+	Seed int64
+	// Clock used for delays; default is the real clock
+	Clock api.ClockInterface
+}
+
+// ImpairedTransport wraps a transport (typically a MemoryNetwork)
+// and impairs datagrams sent by its sockets
+type ImpairedTransport struct {
+	transport  api.TransportInterface
+	impairment Impairment
+	randMu     sync.Mutex
+	rand       *rand.Rand
+}
+
+// Create a transport impairing datagrams sent through transport
+func NewImpairedTransport(transport api.TransportInterface, impairment Impairment) *ImpairedTransport {
+	if impairment.ReorderDelay == 0 {
+		impairment.ReorderDelay = impairment.Delay + impairment.Jitter + 10*time.Millisecond
+	}
+	return &ImpairedTransport{
+		transport:  transport,
+		impairment: impairment,
+		randMu:     sync.Mutex{},
+		rand:       rand.New(rand.NewSource(impairment.Seed)),
+	}
+}
+
+func (t *ImpairedTransport) ListenUDP(laddr *net.UDPAddr) (net.PacketConn, error) {
+	c, err := t.transport.ListenUDP(laddr)
+	if err != nil {
+		return nil, err
+	}
+	return &impairedConn{PacketConn: c, transport: t}, nil
+}
+
+func (t *ImpairedTransport) DialUDP(localIP net.IP, raddr *net.UDPAddr) (net.PacketConn, error) {
+	c, err := t.transport.DialUDP(localIP, raddr)
+	if err != nil {
+		return nil, err
+	}
+	return &impairedConn{PacketConn: c, transport: t}, nil
+}
+
+// Returns true with probability p
+func (t *ImpairedTransport) happens(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	t.randMu.Lock()
+	defer t.randMu.Unlock()
+	return t.rand.Float64() < p
+}
+
+// Returns the delay of a datagram
+func (t *ImpairedTransport) delay() time.Duration {
+	d := t.impairment.Delay
+	if t.impairment.Jitter > 0 {
+		t.randMu.Lock()
+		d += time.Duration(t.rand.Int63n(int64(t.impairment.Jitter) + 1))
+		t.randMu.Unlock()
+	}
+	if t.happens(t.impairment.Reordering) {
+		d += t.impairment.ReorderDelay
+	}
+	return d
+}
+
+type impairedConn struct {
+	net.PacketConn
+	transport *ImpairedTransport
+}
+
+// Impair the datagram, then send it. Errors of delayed datagrams are ignored, like losses.
+func (c *impairedConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	copies := 1
+	if c.transport.happens(c.transport.impairment.Duplication) {
+		copies++
+	}
+	for i := 0; i < copies; i++ {
+		if c.transport.happens(c.transport.impairment.Loss) {
+			continue
+		}
+		d := c.transport.delay()
+		if d <= 0 {
+			if _, err := c.PacketConn.WriteTo(p, addr); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		b := make([]byte, len(p))
+		copy(b, p)
+		after := time.After
+		if c.transport.impairment.Clock != nil {
+			after = c.transport.impairment.Clock.After
+		}
+		ch := after(d)
+		go func() {
+			<-ch
+			c.PacketConn.WriteTo(b, addr)
+		}()
+	}
+	return len(p), nil
+}
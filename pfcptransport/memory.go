@@ -0,0 +1,213 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Package pfcptransport provides transports for PFCP entities
+// other than the UDP sockets of the operating system.
+package pfcptransport
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+)
+
+const (
+	// Number of datagrams a socket can hold before new ones are dropped
+	memoryQueueLen = 1024
+	// First port used for ephemeral sockets
+	memoryFirstEphemeralPort = 49152
+)
+
+var _ api.TransportInterface = (*MemoryNetwork)(nil)
+
+type datagram struct {
+	src     *net.UDPAddr
+	payload []byte
+}
+
+// MemoryNetwork is an in-process transport: datagrams are exchanged
+// between sockets of the same MemoryNetwork without using the operating system.
+// Like UDP, datagrams sent to an address nobody listens on are dropped.
+type MemoryNetwork struct {
+	mu       sync.Mutex
+	conns    map[string]*MemoryConn
+	nextPort int
+}
+
+// Create a new MemoryNetwork
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{
+		mu:       sync.Mutex{},
+		conns:    make(map[string]*MemoryConn),
+		nextPort: memoryFirstEphemeralPort,
+	}
+}
+
+// Open a socket receiving datagrams sent to laddr.
+// If laddr.Port is 0, an ephemeral port is chosen.
+func (n *MemoryNetwork) ListenUDP(laddr *net.UDPAddr) (net.PacketConn, error) {
+	return n.listen(laddr)
+}
+
+// Open a socket bound to localIP and an ephemeral port
+func (n *MemoryNetwork) DialUDP(localIP net.IP, raddr *net.UDPAddr) (net.PacketConn, error) {
+	if localIP == nil {
+		return nil, fmt.Errorf("A local IP Address is required on a memory network")
+	}
+	return n.listen(&net.UDPAddr{IP: localIP, Port: 0})
+}
+
+func (n *MemoryNetwork) listen(laddr *net.UDPAddr) (*MemoryConn, error) {
+	if laddr == nil || laddr.IP == nil {
+		return nil, fmt.Errorf("A local IP Address is required on a memory network")
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	addr := &net.UDPAddr{IP: laddr.IP, Port: laddr.Port}
+	if addr.Port == 0 {
+		for {
+			addr.Port = n.nextPort
+			n.nextPort++
+			if n.nextPort > 65535 {
+				n.nextPort = memoryFirstEphemeralPort
+			}
+			if _, exists := n.conns[addr.String()]; !exists {
+				break
+			}
+		}
+	}
+	if _, exists := n.conns[addr.String()]; exists {
+		return nil, fmt.Errorf("Address %s already in use", addr)
+	}
+	c := &MemoryConn{
+		network: n,
+		laddr:   addr,
+		queue:   make(chan datagram, memoryQueueLen),
+		closed:  make(chan struct{}),
+	}
+	n.conns[addr.String()] = c
+	return c, nil
+}
+
+// Deliver a datagram; it is dropped if nobody listens on dst, or if the queue is full
+func (n *MemoryNetwork) deliver(src *net.UDPAddr, dst *net.UDPAddr, payload []byte) {
+	n.mu.Lock()
+	c, exists := n.conns[dst.String()]
+	n.mu.Unlock()
+	if !exists {
+		return
+	}
+	b := make([]byte, len(payload))
+	copy(b, payload)
+	select {
+	case <-c.closed:
+	case c.queue <- datagram{src: src, payload: b}:
+	default:
+	}
+}
+
+func (n *MemoryNetwork) remove(c *MemoryConn) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conns[c.laddr.String()] == c {
+		delete(n.conns, c.laddr.String())
+	}
+}
+
+// Close every socket of the network
+func (n *MemoryNetwork) Close() error {
+	n.mu.Lock()
+	conns := make([]*MemoryConn, 0, len(n.conns))
+	for _, c := range n.conns {
+		conns = append(conns, c)
+	}
+	n.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+	return nil
+}
+
+// MemoryConn is a socket of a MemoryNetwork. It implements net.PacketConn.
+type MemoryConn struct {
+	network   *MemoryNetwork
+	laddr     *net.UDPAddr
+	queue     chan datagram
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	deadlineMu   sync.Mutex
+	readDeadline time.Time
+}
+
+func (c *MemoryConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	c.deadlineMu.Lock()
+	deadline := c.readDeadline
+	c.deadlineMu.Unlock()
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return 0, nil, os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-c.closed:
+		return 0, nil, net.ErrClosed
+	case <-timeout:
+		return 0, nil, os.ErrDeadlineExceeded
+	case d := <-c.queue:
+		return copy(p, d.payload), d.src, nil
+	}
+}
+
+func (c *MemoryConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	dst, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return 0, fmt.Errorf("Destination is not an UDP address")
+	}
+	c.network.deliver(c.laddr, dst, p)
+	return len(p), nil
+}
+
+func (c *MemoryConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.network.remove(c)
+	})
+	return nil
+}
+
+func (c *MemoryConn) LocalAddr() net.Addr {
+	return c.laddr
+}
+
+func (c *MemoryConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *MemoryConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+// Writes never block
+func (c *MemoryConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -23,4 +23,19 @@ const (
 	// The setting of the T1 timer and N1 counter is implementation specific.
 	MESSAGE_RETRANSMISSION_T1 = time.Millisecond * 500
 	MESSAGE_RETRANSMISSION_N1 = 3
+
+	// The Sequence Number field is 3 octets long,
+	// sequence numbers wrap around after this value.
+	SEQUENCE_NUMBER_MAX = 0xFFFFFF
+
+	// Maximum number of outstanding Request messages per PFCP Peer.
+	// When this limit is reached, new Requests are delayed until
+	// a transaction completes.
+	// This value can be changed on each peer.
+	DEFAULT_MAX_OUTSTANDING_REQUESTS = 256
+
+	// Responses are kept to answer retransmitted Requests (TS 29.244, section 6.4)
+	// as long as peers using the same T1 timer and N1 counter may retransmit them.
+	// This value can be changed on each entity, when peers use other timers.
+	DEFAULT_RESPONSE_CACHE_LIFETIME = MESSAGE_RETRANSMISSION_T1 * (MESSAGE_RETRANSMISSION_N1 + 1)
 )
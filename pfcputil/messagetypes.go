@@ -80,3 +80,82 @@ func IsMessageTypeResponse(msgType MessageType) bool {
 		return false
 	}
 }
+
+// Returns true when message is a PFCP Session related message
+// (i.e. its header contains a SEID)
+func IsMessageTypeSessionRelated(msgType MessageType) bool {
+	switch msgType {
+	case message.MsgTypeSessionEstablishmentRequest:
+		fallthrough
+	case message.MsgTypeSessionEstablishmentResponse:
+		fallthrough
+	case message.MsgTypeSessionModificationRequest:
+		fallthrough
+	case message.MsgTypeSessionModificationResponse:
+		fallthrough
+	case message.MsgTypeSessionDeletionRequest:
+		fallthrough
+	case message.MsgTypeSessionDeletionResponse:
+		fallthrough
+	case message.MsgTypeSessionReportRequest:
+		fallthrough
+	case message.MsgTypeSessionReportResponse:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the name of a PFCP message type
+func MessageTypeName(msgType MessageType) string {
+	switch msgType {
+	case message.MsgTypeHeartbeatRequest:
+		return "Heartbeat Request"
+	case message.MsgTypeHeartbeatResponse:
+		return "Heartbeat Response"
+	case message.MsgTypePFDManagementRequest:
+		return "PFD Management Request"
+	case message.MsgTypePFDManagementResponse:
+		return "PFD Management Response"
+	case message.MsgTypeAssociationSetupRequest:
+		return "Association Setup Request"
+	case message.MsgTypeAssociationSetupResponse:
+		return "Association Setup Response"
+	case message.MsgTypeAssociationUpdateRequest:
+		return "Association Update Request"
+	case message.MsgTypeAssociationUpdateResponse:
+		return "Association Update Response"
+	case message.MsgTypeAssociationReleaseRequest:
+		return "Association Release Request"
+	case message.MsgTypeAssociationReleaseResponse:
+		return "Association Release Response"
+	case message.MsgTypeVersionNotSupportedResponse:
+		return "Version Not Supported Response"
+	case message.MsgTypeNodeReportRequest:
+		return "Node Report Request"
+	case message.MsgTypeNodeReportResponse:
+		return "Node Report Response"
+	case message.MsgTypeSessionSetDeletionRequest:
+		return "Session Set Deletion Request"
+	case message.MsgTypeSessionSetDeletionResponse:
+		return "Session Set Deletion Response"
+	case message.MsgTypeSessionEstablishmentRequest:
+		return "Session Establishment Request"
+	case message.MsgTypeSessionEstablishmentResponse:
+		return "Session Establishment Response"
+	case message.MsgTypeSessionModificationRequest:
+		return "Session Modification Request"
+	case message.MsgTypeSessionModificationResponse:
+		return "Session Modification Response"
+	case message.MsgTypeSessionDeletionRequest:
+		return "Session Deletion Request"
+	case message.MsgTypeSessionDeletionResponse:
+		return "Session Deletion Response"
+	case message.MsgTypeSessionReportRequest:
+		return "Session Report Request"
+	case message.MsgTypeSessionReportResponse:
+		return "Session Report Response"
+	default:
+		return "Unknown"
+	}
+}
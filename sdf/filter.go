@@ -0,0 +1,180 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package sdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+const (
+	// Flags of the SDF Filter IE
+	flagFD  = 0x01
+	flagTTC = 0x02
+	flagSPI = 0x04
+	flagFL  = 0x08
+	flagBID = 0x10
+
+	// Flow Label is 20 bits long
+	flowLabelMask = 0xFFFFF
+)
+
+// A Filter is a parsed SDF Filter IE
+type Filter struct {
+	FlowDescription *FlowDescription // nil when not present
+
+	HasToSTrafficClass  bool
+	ToSTrafficClass     uint8
+	ToSTrafficClassMask uint8
+
+	HasSecurityParameterIndex bool
+	SecurityParameterIndex    uint32
+
+	HasFlowLabel bool
+	FlowLabel    uint32
+
+	HasFilterID bool
+	FilterID    uint32
+}
+
+// Parse a SDF Filter IE
+func FromIE(i *ie.IE) (*Filter, error) {
+	if i == nil {
+		return nil, fmt.Errorf("SDF Filter IE is nil")
+	}
+	if i.Type != ie.SDFFilter {
+		return nil, fmt.Errorf("IE is not a SDF Filter")
+	}
+	// go-pfcp does not check length of the Flow Description
+	if len(i.Payload) >= 4 && i.Payload[0]&flagFD != 0 {
+		if len(i.Payload) < 4+int(binary.BigEndian.Uint16(i.Payload[2:4])) {
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+	fields, err := i.SDFFilter()
+	if err != nil {
+		return nil, err
+	}
+	return FromFields(fields)
+}
+
+// Parse fields of a SDF Filter IE
+func FromFields(fields *ie.SDFFilterFields) (*Filter, error) {
+	f := Filter{}
+	if fields.HasFD() {
+		fd, err := ParseFlowDescription(fields.FlowDescription)
+		if err != nil {
+			return nil, err
+		}
+		f.FlowDescription = fd
+	}
+	if fields.HasTTC() {
+		if len(fields.ToSTrafficClass) != 2 {
+			return nil, fmt.Errorf("Wrong length of ToS/Traffic Class")
+		}
+		f.HasToSTrafficClass = true
+		f.ToSTrafficClass = fields.ToSTrafficClass[0]
+		f.ToSTrafficClassMask = fields.ToSTrafficClass[1]
+	}
+	if fields.HasSPI() {
+		if len(fields.SecurityParameterIndex) != 4 {
+			return nil, fmt.Errorf("Wrong length of Security Parameter Index")
+		}
+		f.HasSecurityParameterIndex = true
+		f.SecurityParameterIndex = binary.BigEndian.Uint32([]byte(fields.SecurityParameterIndex))
+	}
+	if fields.HasFL() {
+		if len(fields.FlowLabel) != 3 {
+			return nil, fmt.Errorf("Wrong length of Flow Label")
+		}
+		f.HasFlowLabel = true
+		fl := []byte(fields.FlowLabel)
+		f.FlowLabel = (uint32(fl[0])<<16 | uint32(fl[1])<<8 | uint32(fl[2])) & flowLabelMask
+	}
+	if fields.HasBID() {
+		f.HasFilterID = true
+		f.FilterID = fields.SDFFilterID
+	}
+	return &f, nil
+}
+
+// Create a SDF Filter IE
+func (f *Filter) IE() *ie.IE {
+	fields := ie.SDFFilterFields{}
+	if f.FlowDescription != nil {
+		fields.Flags |= flagFD
+		fields.FlowDescription = f.FlowDescription.String()
+		fields.FDLength = uint16(len(fields.FlowDescription))
+	}
+	if f.HasToSTrafficClass {
+		fields.Flags |= flagTTC
+		fields.ToSTrafficClass = string([]byte{f.ToSTrafficClass, f.ToSTrafficClassMask})
+	}
+	if f.HasSecurityParameterIndex {
+		fields.Flags |= flagSPI
+		spi := make([]byte, 4)
+		binary.BigEndian.PutUint32(spi, f.SecurityParameterIndex)
+		fields.SecurityParameterIndex = string(spi)
+	}
+	if f.HasFlowLabel {
+		fields.Flags |= flagFL
+		fl := f.FlowLabel & flowLabelMask
+		fields.FlowLabel = string([]byte{byte(fl >> 16), byte(fl >> 8), byte(fl)})
+	}
+	if f.HasFilterID {
+		fields.Flags |= flagBID
+		fields.SDFFilterID = f.FilterID
+	}
+	b, _ := fields.Marshal()
+	return ie.New(ie.SDFFilter, b)
+}
+
+func (f *Filter) String() string {
+	parts := make([]string, 0)
+	if f.FlowDescription != nil {
+		parts = append(parts, f.FlowDescription.String())
+	}
+	if f.HasToSTrafficClass {
+		parts = append(parts, fmt.Sprintf("tos 0x%02x/0x%02x", f.ToSTrafficClass, f.ToSTrafficClassMask))
+	}
+	if f.HasSecurityParameterIndex {
+		parts = append(parts, fmt.Sprintf("spi 0x%08x", f.SecurityParameterIndex))
+	}
+	if f.HasFlowLabel {
+		parts = append(parts, fmt.Sprintf("flow-label 0x%05x", f.FlowLabel))
+	}
+	if f.HasFilterID {
+		parts = append(parts, fmt.Sprintf("id %d", f.FilterID))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Returns true if the flow is permitted by the SDF Filter: every field present in the filter must match.
+// See FlowDescription.Match for dir and assigned.
+func (f *Filter) Match(flow *Flow, dir Direction, assigned []*net.IPNet) bool {
+	if f.FlowDescription != nil {
+		if f.FlowDescription.Action != ActionPermit || !f.FlowDescription.Match(flow, dir, assigned) {
+			return false
+		}
+	}
+	if f.HasToSTrafficClass && flow.TrafficClass&f.ToSTrafficClassMask != f.ToSTrafficClass&f.ToSTrafficClassMask {
+		return false
+	}
+	if f.HasSecurityParameterIndex && (!flow.HasSPI || flow.SPI != f.SecurityParameterIndex) {
+		return false
+	}
+	if f.HasFlowLabel {
+		if flow.SrcIP == nil || flow.SrcIP.To4() != nil || flow.FlowLabel&flowLabelMask != f.FlowLabel {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,329 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Package sdf parses SDF Filters (TS 29.244, section 8.2.5)
+// and their Flow Description, an IPFilterRule (RFC 6733, section 4.3.1)
+// restricted as described in TS 29.212, section 5.4.2.
+package sdf
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+type Action uint8
+
+const (
+	ActionPermit Action = iota
+	ActionDeny
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionPermit:
+		return "permit"
+	case ActionDeny:
+		return "deny"
+	default:
+		return fmt.Sprintf("action(%d)", uint8(a))
+	}
+}
+
+// Direction of a Flow Description.
+// "out" is the downlink direction: source is the remote side and destination is the UE.
+// "in" is the uplink direction: source is the UE and destination is the remote side.
+type Direction uint8
+
+const (
+	DirectionIn Direction = iota
+	DirectionOut
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionIn:
+		return "in"
+	case DirectionOut:
+		return "out"
+	default:
+		return fmt.Sprintf("direction(%d)", uint8(d))
+	}
+}
+
+// A PortRange is an inclusive range of ports
+type PortRange struct {
+	First uint16
+	Last  uint16
+}
+
+func (r PortRange) Contains(port uint16) bool {
+	return port >= r.First && port <= r.Last
+}
+
+func (r PortRange) String() string {
+	if r.First == r.Last {
+		return strconv.Itoa(int(r.First))
+	}
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// An Endpoint is the source or the destination of a Flow Description
+type Endpoint struct {
+	Not      bool        // modifier "!": every other address matches (ports are not negated, see RFC 6733)
+	Any      bool        // keyword "any": any address
+	Assigned bool        // keyword "assigned": address(es) assigned to the UE
+	Network  *net.IPNet  // set when neither Any nor Assigned is true; IP keeps host bits as written
+	Ports    []PortRange // empty when any port matches
+
+	hasPrefixLength bool // address was written with a prefix length
+}
+
+func (e *Endpoint) String() string {
+	var s string
+	if e.Not {
+		s = "!"
+	}
+	switch {
+	case e.Any:
+		s += "any"
+	case e.Assigned:
+		s += "assigned"
+	default:
+		// net.IPNet.String would clear host bits
+		ones, bits := e.Network.Mask.Size()
+		if ones == bits && !e.hasPrefixLength {
+			s += e.Network.IP.String()
+		} else {
+			s += fmt.Sprintf("%s/%d", e.Network.IP, ones)
+		}
+	}
+	if len(e.Ports) > 0 {
+		ports := make([]string, len(e.Ports))
+		for i, r := range e.Ports {
+			ports[i] = r.String()
+		}
+		s = s + " " + strings.Join(ports, ",")
+	}
+	return s
+}
+
+// Returns true if addr is contained in the endpoint.
+// When assigned is empty, the keyword "assigned" matches any address.
+func (e *Endpoint) containsAddress(addr net.IP, assigned []*net.IPNet) bool {
+	contains := false
+	switch {
+	case e.Any:
+		contains = true
+	case e.Assigned:
+		contains = len(assigned) == 0
+		for _, n := range assigned {
+			if n.Contains(addr) {
+				contains = true
+				break
+			}
+		}
+	default:
+		contains = addr != nil && e.Network.Contains(addr)
+	}
+	if e.Not {
+		return addr != nil && !contains
+	}
+	return contains
+}
+
+func (e *Endpoint) containsPort(port uint16) bool {
+	if len(e.Ports) == 0 {
+		return true
+	}
+	for _, r := range e.Ports {
+		if r.Contains(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// A FlowDescription is a parsed IPFilterRule
+type FlowDescription struct {
+	Action      Action
+	Direction   Direction
+	AnyProtocol bool  // keyword "ip": any protocol
+	Protocol    uint8 // used when AnyProtocol is false
+	Source      Endpoint
+	Destination Endpoint
+	Options     []string // options are kept but not evaluated
+}
+
+// Parse a Flow Description, e.g. "permit out 17 from 10.0.0.0/8 1000-2000 to assigned".
+// Addresses can be negated with "!", e.g. "permit out ip from !10.0.0.0/8 to assigned".
+func ParseFlowDescription(s string) (*FlowDescription, error) {
+	tokens := strings.Fields(s)
+	if len(tokens) < 7 {
+		return nil, fmt.Errorf("Flow Description is too short: %q", s)
+	}
+	fd := FlowDescription{}
+	switch tokens[0] {
+	case "permit":
+		fd.Action = ActionPermit
+	case "deny":
+		fd.Action = ActionDeny
+	default:
+		return nil, fmt.Errorf("Unknown action in Flow Description: %q", tokens[0])
+	}
+	switch tokens[1] {
+	case "in":
+		fd.Direction = DirectionIn
+	case "out":
+		fd.Direction = DirectionOut
+	default:
+		return nil, fmt.Errorf("Unknown direction in Flow Description: %q", tokens[1])
+	}
+	if tokens[2] == "ip" {
+		fd.AnyProtocol = true
+	} else {
+		proto, err := strconv.ParseUint(tokens[2], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("Wrong protocol in Flow Description: %q", tokens[2])
+		}
+		fd.Protocol = uint8(proto)
+	}
+	if tokens[3] != "from" {
+		return nil, fmt.Errorf("Keyword \"from\" is missing in Flow Description")
+	}
+	rest, err := parseEndpoint(tokens[4:], &fd.Source)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) == 0 || rest[0] != "to" {
+		return nil, fmt.Errorf("Keyword \"to\" is missing in Flow Description")
+	}
+	rest, err = parseEndpoint(rest[1:], &fd.Destination)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		fd.Options = rest
+	}
+	return &fd, nil
+}
+
+// Parse an address followed by optional ports, and returns remaining tokens
+func parseEndpoint(tokens []string, e *Endpoint) ([]string, error) {
+	if len(tokens) > 0 && strings.HasPrefix(tokens[0], "!") {
+		// "!" may be followed by a space
+		e.Not = true
+		if tokens[0] == "!" {
+			tokens = tokens[1:]
+		} else {
+			tokens = append([]string{tokens[0][1:]}, tokens[1:]...)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("Address is missing in Flow Description")
+	}
+	switch addr := tokens[0]; addr {
+	case "any":
+		e.Any = true
+	case "assigned":
+		e.Assigned = true
+	default:
+		if strings.Contains(addr, "/") {
+			ip, n, err := net.ParseCIDR(addr)
+			if err != nil {
+				return nil, fmt.Errorf("Wrong address in Flow Description: %q", addr)
+			}
+			if len(n.IP) == net.IPv4len {
+				ip = ip.To4()
+			}
+			e.Network = &net.IPNet{IP: ip, Mask: n.Mask}
+			e.hasPrefixLength = true
+		} else {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				return nil, fmt.Errorf("Wrong address in Flow Description: %q", addr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+				bits = 8 * net.IPv4len
+			}
+			e.Network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+	}
+	tokens = tokens[1:]
+	if len(tokens) == 0 || tokens[0] == "" || tokens[0][0] < '0' || tokens[0][0] > '9' {
+		// no ports
+		return tokens, nil
+	}
+	for _, p := range strings.Split(tokens[0], ",") {
+		r, err := parsePortRange(p)
+		if err != nil {
+			return nil, err
+		}
+		e.Ports = append(e.Ports, r)
+	}
+	return tokens[1:], nil
+}
+
+func parsePortRange(s string) (PortRange, error) {
+	first, last, isRange := strings.Cut(s, "-")
+	f, err := strconv.ParseUint(first, 10, 16)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("Wrong port in Flow Description: %q", s)
+	}
+	if !isRange {
+		return PortRange{First: uint16(f), Last: uint16(f)}, nil
+	}
+	l, err := strconv.ParseUint(last, 10, 16)
+	if err != nil || l < f {
+		return PortRange{}, fmt.Errorf("Wrong port range in Flow Description: %q", s)
+	}
+	return PortRange{First: uint16(f), Last: uint16(l)}, nil
+}
+
+func (fd *FlowDescription) String() string {
+	proto := "ip"
+	if !fd.AnyProtocol {
+		proto = strconv.Itoa(int(fd.Protocol))
+	}
+	s := fmt.Sprintf("%s %s %s from %s to %s", fd.Action, fd.Direction, proto, fd.Source.String(), fd.Destination.String())
+	if len(fd.Options) > 0 {
+		s = s + " " + strings.Join(fd.Options, " ")
+	}
+	return s
+}
+
+// A Flow is the part of a packet matched by SDF Filters
+type Flow struct {
+	SrcIP    net.IP
+	DstIP    net.IP
+	Protocol uint8
+	SrcPort  uint16
+	DstPort  uint16
+
+	TrafficClass uint8 // IPv4 ToS or IPv6 Traffic Class
+	HasSPI       bool  // true for IPsec packets
+	SPI          uint32
+	FlowLabel    uint32 // IPv6 only
+}
+
+// Returns true if the flow matches the addresses, protocol and ports of the Flow Description.
+// dir is the direction of the flow: when it is not the direction of the Flow Description,
+// source and destination of the Flow Description are swapped (TS 29.212, section 5.4.2).
+// assigned are the addresses of the UE; when empty, "assigned" matches any address.
+// Action of the Flow Description is not taken into account.
+func (fd *FlowDescription) Match(f *Flow, dir Direction, assigned []*net.IPNet) bool {
+	if !fd.AnyProtocol && fd.Protocol != f.Protocol {
+		return false
+	}
+	src, dst := &fd.Source, &fd.Destination
+	if dir != fd.Direction {
+		src, dst = dst, src
+	}
+	return src.containsAddress(f.SrcIP, assigned) && src.containsPort(f.SrcPort) &&
+		dst.containsAddress(f.DstIP, assigned) && dst.containsPort(f.DstPort)
+}
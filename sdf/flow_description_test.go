@@ -0,0 +1,114 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package sdf
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseFlowDescription(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		out  string // expected String(), same as in when empty
+		err  bool
+	}{
+		{name: "any protocol", in: "permit out ip from any to assigned"},
+		{name: "protocol", in: "permit in 17 from assigned to any"},
+		{name: "deny", in: "deny out 6 from any to assigned"},
+		{name: "port", in: "permit out 17 from 10.0.0.0/8 53 to assigned"},
+		{name: "port range", in: "permit out 17 from 10.0.0.0/8 1000-2000 to assigned"},
+		{name: "port list", in: "permit out 6 from any 80,443,8000-8080 to assigned 1024-65535"},
+		{name: "host", in: "permit out ip from 192.0.2.1 to assigned"},
+		{name: "host bits kept", in: "permit out ip from 10.1.2.3/8 to assigned"},
+		{name: "prefix length kept", in: "permit out ip from 192.0.2.1/32 to assigned"},
+		{name: "ipv6", in: "permit out 17 from 2001:db8::1 5060 to assigned"},
+		{name: "ipv6 prefix", in: "permit in ip from assigned to 2001:db8::/32"},
+		{name: "not", in: "permit out ip from !10.0.0.0/8 to assigned"},
+		{name: "not with space", in: "permit out ip from ! 10.0.0.0/8 80 to assigned", out: "permit out ip from !10.0.0.0/8 80 to assigned"},
+		{name: "options", in: "permit in 6 from assigned to 192.0.2.1 22 established"},
+		{name: "spaces", in: "permit  out ip  from any to assigned", out: "permit out ip from any to assigned"},
+		{name: "empty", in: "", err: true},
+		{name: "too short", in: "permit out ip from any to", err: true},
+		{name: "unknown action", in: "allow out ip from any to assigned", err: true},
+		{name: "unknown direction", in: "permit both ip from any to assigned", err: true},
+		{name: "wrong protocol", in: "permit out udp from any to assigned", err: true},
+		{name: "protocol out of range", in: "permit out 256 from any to assigned", err: true},
+		{name: "missing from", in: "permit out ip to any to assigned", err: true},
+		{name: "missing to", in: "permit out ip from any from assigned", err: true},
+		{name: "wrong address", in: "permit out ip from 10.0.0 to assigned", err: true},
+		{name: "wrong prefix", in: "permit out ip from 10.0.0.0/33 to assigned", err: true},
+		{name: "wrong port", in: "permit out 17 from any 65536 to assigned", err: true},
+		{name: "reversed port range", in: "permit out 17 from any 2000-1000 to assigned", err: true},
+		{name: "not without address", in: "permit out ip from any to !", err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fd, err := ParseFlowDescription(tc.in)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", fd)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			out := tc.out
+			if out == "" {
+				out = tc.in
+			}
+			if fd.String() != out {
+				t.Errorf("got %q, expected %q", fd.String(), out)
+			}
+		})
+	}
+}
+
+func TestFlowDescriptionMatch(t *testing.T) {
+	ue := []*net.IPNet{{IP: net.ParseIP("10.45.0.1").To4(), Mask: net.CIDRMask(32, 32)}}
+	dl := func(src string, srcPort uint16, proto uint8) *Flow {
+		return &Flow{SrcIP: net.ParseIP(src), DstIP: net.ParseIP("10.45.0.1"), Protocol: proto, SrcPort: srcPort, DstPort: 40000}
+	}
+	for _, tc := range []struct {
+		name     string
+		fd       string
+		flow     *Flow
+		dir      Direction
+		assigned []*net.IPNet
+		match    bool
+	}{
+		{name: "any", fd: "permit out ip from any to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: ue, match: true},
+		{name: "protocol", fd: "permit out 17 from any to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: ue, match: false},
+		{name: "network", fd: "permit out ip from 192.0.2.0/24 to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: ue, match: true},
+		{name: "other network", fd: "permit out ip from 198.51.100.0/24 to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: ue, match: false},
+		{name: "host bits", fd: "permit out ip from 192.0.2.99/24 to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: ue, match: true},
+		{name: "port", fd: "permit out 6 from any 80 to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: ue, match: true},
+		{name: "other port", fd: "permit out 6 from any 443 to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: ue, match: false},
+		{name: "port range", fd: "permit out 6 from any 1-1024 to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: ue, match: true},
+		{name: "port list", fd: "permit out 6 from any 443,8000-8080 to assigned", flow: dl("192.0.2.1", 8080, 6), dir: DirectionOut, assigned: ue, match: true},
+		{name: "other ue", fd: "permit out ip from any to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: []*net.IPNet{{IP: net.ParseIP("10.45.0.2").To4(), Mask: net.CIDRMask(32, 32)}}, match: false},
+		{name: "assigned unknown", fd: "permit out ip from any to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: nil, match: true},
+		{name: "uplink", fd: "permit out 6 from 192.0.2.0/24 80 to assigned", flow: &Flow{SrcIP: net.ParseIP("10.45.0.1"), DstIP: net.ParseIP("192.0.2.1"), Protocol: 6, SrcPort: 40000, DstPort: 80}, dir: DirectionIn, assigned: ue, match: true},
+		{name: "uplink wrong port", fd: "permit out 6 from 192.0.2.0/24 80 to assigned", flow: &Flow{SrcIP: net.ParseIP("10.45.0.1"), DstIP: net.ParseIP("192.0.2.1"), Protocol: 6, SrcPort: 80, DstPort: 40000}, dir: DirectionIn, assigned: ue, match: false},
+		{name: "ipv6", fd: "permit out ip from 2001:db8::/32 to any", flow: &Flow{SrcIP: net.ParseIP("2001:db8::1"), DstIP: net.ParseIP("2001:db8:1::1"), Protocol: 17}, dir: DirectionOut, match: true},
+		{name: "ipv6 other network", fd: "permit out ip from 2001:db8::/32 to any", flow: &Flow{SrcIP: net.ParseIP("2001:db9::1"), DstIP: net.ParseIP("2001:db8:1::1"), Protocol: 17}, dir: DirectionOut, match: false},
+		{name: "ipv4 against ipv6", fd: "permit out ip from 2001:db8::/32 to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: ue, match: false},
+		{name: "not", fd: "permit out ip from !192.0.2.0/24 to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: ue, match: false},
+		{name: "not other network", fd: "permit out ip from !198.51.100.0/24 to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: ue, match: true},
+		{name: "not does not apply to ports", fd: "permit out 6 from !198.51.100.0/24 443 to assigned", flow: dl("192.0.2.1", 80, 6), dir: DirectionOut, assigned: ue, match: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fd, err := ParseFlowDescription(tc.fd)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if match := fd.Match(tc.flow, tc.dir, tc.assigned); match != tc.match {
+				t.Errorf("got %t, expected %t", match, tc.match)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package userplane
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// GTP-U (TS 29.281)
+const (
+	GTPUPort = 2152
+
+	MessageTypeEchoRequest  = 1
+	MessageTypeEchoResponse = 2
+	MessageTypeGPDU         = 255
+
+	gtpuHeaderLen         = 8
+	gtpuOptionalFieldsLen = 4
+	gtpuFlagsVersion1     = 0x20
+	gtpuFlagProtocolType  = 0x10
+	gtpuFlagE             = 0x04 // Extension Header present
+	gtpuFlagS             = 0x02 // Sequence Number present
+	gtpuFlagPN            = 0x01 // N-PDU Number present
+
+	extensionHeaderPDUSessionContainer = 0x85
+	ieTypeRecovery                     = 14
+)
+
+// PDU Type of the PDU Session Container extension header (TS 38.415)
+const (
+	PDUTypeDownlink = 0
+	PDUTypeUplink   = 1
+)
+
+// Header of a GTP-U message
+type Header struct {
+	MessageType       uint8
+	TEID              uint32
+	HasSequenceNumber bool
+	SequenceNumber    uint16
+
+	// PDU Session Container extension header
+	HasQFI  bool
+	PDUType uint8
+	QFI     uint8
+}
+
+// Parse a GTP-U message, and returns its header and payload.
+// Extension headers other than the PDU Session Container are skipped.
+func ParseGTPU(b []byte) (*Header, []byte, error) {
+	if len(b) < gtpuHeaderLen {
+		return nil, nil, fmt.Errorf("GTP-U message is too short")
+	}
+	if b[0]&0xe0 != gtpuFlagsVersion1 || b[0]&gtpuFlagProtocolType == 0 {
+		return nil, nil, fmt.Errorf("Not a GTP-U version 1 message")
+	}
+	length := int(binary.BigEndian.Uint16(b[2:4]))
+	if gtpuHeaderLen+length > len(b) {
+		return nil, nil, fmt.Errorf("Wrong length of GTP-U message")
+	}
+	b = b[:gtpuHeaderLen+length]
+	h := Header{
+		MessageType: b[1],
+		TEID:        binary.BigEndian.Uint32(b[4:8]),
+	}
+	offset := gtpuHeaderLen
+	if b[0]&(gtpuFlagE|gtpuFlagS|gtpuFlagPN) == 0 {
+		return &h, b[offset:], nil
+	}
+	if len(b) < offset+gtpuOptionalFieldsLen {
+		return nil, nil, fmt.Errorf("GTP-U optional fields are missing")
+	}
+	if b[0]&gtpuFlagS != 0 {
+		h.HasSequenceNumber = true
+		h.SequenceNumber = binary.BigEndian.Uint16(b[8:10])
+	}
+	next := uint8(0)
+	if b[0]&gtpuFlagE != 0 {
+		next = b[11]
+	}
+	offset += gtpuOptionalFieldsLen
+	for next != 0 {
+		if len(b) <= offset {
+			return nil, nil, fmt.Errorf("GTP-U extension header is truncated")
+		}
+		l := 4 * int(b[offset])
+		if l == 0 || len(b) < offset+l {
+			return nil, nil, fmt.Errorf("Wrong length of GTP-U extension header")
+		}
+		if next == extensionHeaderPDUSessionContainer && l >= 4 {
+			h.HasQFI = true
+			h.PDUType = b[offset+1] >> 4
+			h.QFI = b[offset+2] & 0x3f
+		}
+		next = b[offset+l-1]
+		offset += l
+	}
+	return &h, b[offset:], nil
+}
+
+// Create a GTP-U message
+func (h *Header) Marshal(payload []byte) []byte {
+	flags := uint8(gtpuFlagsVersion1 | gtpuFlagProtocolType)
+	optional := make([]byte, 0, 12)
+	if h.HasSequenceNumber || h.HasQFI {
+		optional = binary.BigEndian.AppendUint16(optional, h.SequenceNumber)
+		optional = append(optional, 0) // N-PDU Number
+		if h.HasSequenceNumber {
+			flags |= gtpuFlagS
+		}
+		if h.HasQFI {
+			flags |= gtpuFlagE
+			optional = append(optional, extensionHeaderPDUSessionContainer, 1, h.PDUType<<4, h.QFI&0x3f, 0)
+		} else {
+			optional = append(optional, 0)
+		}
+	}
+	b := make([]byte, gtpuHeaderLen, gtpuHeaderLen+len(optional)+len(payload))
+	b[0] = flags
+	b[1] = h.MessageType
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(optional)+len(payload)))
+	binary.BigEndian.PutUint32(b[4:8], h.TEID)
+	b = append(b, optional...)
+	return append(b, payload...)
+}
+
+// Create the Echo Response to an Echo Request
+func newEchoResponse(req *Header) []byte {
+	h := Header{
+		MessageType:       MessageTypeEchoResponse,
+		HasSequenceNumber: true,
+		SequenceNumber:    req.SequenceNumber,
+	}
+	// Recovery IE, restart counter is not used in GTP-U
+	return h.Marshal([]byte{ieTypeRecovery, 0})
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package userplane
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGTPURoundTrip(t *testing.T) {
+	payload := []byte{0x45, 0x00, 0x00, 0x14}
+	for _, tc := range []struct {
+		name    string
+		header  Header
+		payload []byte
+	}{
+		{name: "G-PDU", header: Header{MessageType: MessageTypeGPDU, TEID: 0x12345678}, payload: payload},
+		{name: "empty G-PDU", header: Header{MessageType: MessageTypeGPDU, TEID: 1}, payload: []byte{}},
+		{name: "sequence number", header: Header{MessageType: MessageTypeGPDU, TEID: 1, HasSequenceNumber: true, SequenceNumber: 0xabcd}, payload: payload},
+		{name: "uplink QFI", header: Header{MessageType: MessageTypeGPDU, TEID: 1, HasQFI: true, PDUType: PDUTypeUplink, QFI: 9}, payload: payload},
+		{name: "downlink QFI", header: Header{MessageType: MessageTypeGPDU, TEID: 1, HasQFI: true, PDUType: PDUTypeDownlink, QFI: 0x3f}, payload: payload},
+		{name: "sequence number and QFI", header: Header{MessageType: MessageTypeGPDU, TEID: 1, HasSequenceNumber: true, SequenceNumber: 1, HasQFI: true, QFI: 5}, payload: payload},
+		{name: "Echo Request", header: Header{MessageType: MessageTypeEchoRequest, HasSequenceNumber: true, SequenceNumber: 7}, payload: []byte{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h, p, err := ParseGTPU(tc.header.Marshal(tc.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *h != tc.header {
+				t.Errorf("got header %+v, expected %+v", *h, tc.header)
+			}
+			if !bytes.Equal(p, tc.payload) {
+				t.Errorf("got payload %x, expected %x", p, tc.payload)
+			}
+		})
+	}
+}
+
+func TestParseGTPU(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		in      []byte
+		header  Header
+		payload []byte
+		err     bool
+	}{
+		{name: "trailing bytes", in: []byte{0x30, 0xff, 0x00, 0x01, 0, 0, 0, 1, 0xaa, 0xbb}, header: Header{MessageType: MessageTypeGPDU, TEID: 1}, payload: []byte{0xaa}},
+		{name: "N-PDU number", in: []byte{0x31, 0xff, 0x00, 0x05, 0, 0, 0, 1, 0, 0, 0x10, 0, 0xaa}, header: Header{MessageType: MessageTypeGPDU, TEID: 1}, payload: []byte{0xaa}},
+		{name: "unknown extension header skipped", in: []byte{0x34, 0xff, 0x00, 0x09, 0, 0, 0, 1, 0, 0, 0, 0x40, 1, 0xc3, 0x50, 0, 0xaa}, header: Header{MessageType: MessageTypeGPDU, TEID: 1}, payload: []byte{0xaa}},
+		{name: "too short", in: []byte{0x30, 0xff, 0x00, 0x00, 0, 0, 0}, err: true},
+		{name: "GTP version 2", in: []byte{0x48, 0xff, 0x00, 0x00, 0, 0, 0, 1}, err: true},
+		{name: "GTP'", in: []byte{0x20, 0xff, 0x00, 0x00, 0, 0, 0, 1}, err: true},
+		{name: "wrong length", in: []byte{0x30, 0xff, 0x00, 0x02, 0, 0, 0, 1, 0xaa}, err: true},
+		{name: "missing optional fields", in: []byte{0x32, 0xff, 0x00, 0x00, 0, 0, 0, 1}, err: true},
+		{name: "truncated extension header", in: []byte{0x34, 0xff, 0x00, 0x04, 0, 0, 0, 1, 0, 0, 0, 0x85}, err: true},
+		{name: "extension header of length 0", in: []byte{0x34, 0xff, 0x00, 0x08, 0, 0, 0, 1, 0, 0, 0, 0x85, 0, 0, 0, 0}, err: true},
+		{name: "extension header too long", in: []byte{0x34, 0xff, 0x00, 0x08, 0, 0, 0, 1, 0, 0, 0, 0x85, 2, 0, 0, 0}, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h, p, err := ParseGTPU(tc.in)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", *h)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if *h != tc.header {
+				t.Errorf("got header %+v, expected %+v", *h, tc.header)
+			}
+			if !bytes.Equal(p, tc.payload) {
+				t.Errorf("got payload %x, expected %x", p, tc.payload)
+			}
+		})
+	}
+}
+
+// Parsing must not panic, and a parsed message must be encoded back to the same header and payload
+func FuzzParseGTPU(f *testing.F) {
+	for _, h := range []Header{
+		{MessageType: MessageTypeGPDU, TEID: 1},
+		{MessageType: MessageTypeGPDU, TEID: 1, HasSequenceNumber: true, SequenceNumber: 1, HasQFI: true, PDUType: PDUTypeUplink, QFI: 9},
+		{MessageType: MessageTypeEchoRequest, HasSequenceNumber: true},
+	} {
+		f.Add(h.Marshal([]byte{0x45, 0x00, 0x00, 0x14}))
+	}
+	f.Add([]byte{0x34, 0xff, 0x00, 0x09, 0, 0, 0, 1, 0, 0, 0, 0x40, 1, 0xc3, 0x50, 0, 0xaa})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		h, p, err := ParseGTPU(b)
+		if err != nil {
+			return
+		}
+		h2, p2, err := ParseGTPU(h.Marshal(p))
+		if err != nil {
+			t.Fatalf("cannot parse encoded message: %s", err)
+		}
+		if *h2 != *h {
+			t.Errorf("got header %+v, expected %+v", *h2, *h)
+		}
+		if !bytes.Equal(p2, p) {
+			t.Errorf("got payload %x, expected %x", p2, p)
+		}
+	})
+}
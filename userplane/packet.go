@@ -0,0 +1,77 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package userplane
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/nextmn/go-pfcp-networking/classifier"
+)
+
+// IP protocol numbers
+const (
+	protocolTCP  = 6
+	protocolUDP  = 17
+	protocolESP  = 50
+	protocolSCTP = 132
+
+	ipv4HeaderMinLen = 20
+	ipv6HeaderLen    = 40
+)
+
+// Fill addresses, protocol, ports and traffic fields of p from an IP packet.
+// IPv6 extension headers are not walked: ports are only found when they directly follow the IPv6 header.
+func parseIP(b []byte, p *classifier.Packet) error {
+	if len(b) == 0 {
+		return fmt.Errorf("Empty IP packet")
+	}
+	var transport []byte
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < ipv4HeaderMinLen {
+			return fmt.Errorf("IPv4 packet is too short")
+		}
+		ihl := 4 * int(b[0]&0x0f)
+		if ihl < ipv4HeaderMinLen || len(b) < ihl {
+			return fmt.Errorf("Wrong IPv4 header length")
+		}
+		p.TrafficClass = b[1]
+		p.Protocol = b[9]
+		p.SrcIP = net.IP(b[12:16])
+		p.DstIP = net.IP(b[16:20])
+		// only the first fragment holds the transport header
+		if binary.BigEndian.Uint16(b[6:8])&0x1fff == 0 {
+			transport = b[ihl:]
+		}
+	case 6:
+		if len(b) < ipv6HeaderLen {
+			return fmt.Errorf("IPv6 packet is too short")
+		}
+		p.TrafficClass = b[0]<<4 | b[1]>>4
+		p.FlowLabel = binary.BigEndian.Uint32(b[0:4]) & 0x000fffff
+		p.Protocol = b[6]
+		p.SrcIP = net.IP(b[8:24])
+		p.DstIP = net.IP(b[24:40])
+		transport = b[ipv6HeaderLen:]
+	default:
+		return fmt.Errorf("Not an IP packet")
+	}
+	switch p.Protocol {
+	case protocolTCP, protocolUDP, protocolSCTP:
+		if len(transport) >= 4 {
+			p.SrcPort = binary.BigEndian.Uint16(transport[0:2])
+			p.DstPort = binary.BigEndian.Uint16(transport[2:4])
+		}
+	case protocolESP:
+		if len(transport) >= 4 {
+			p.HasSPI = true
+			p.SPI = binary.BigEndian.Uint32(transport[0:4])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+// Package userplane is a reference user plane in pure Go, driven by the PFCP sessions of a UP function.
+// It terminates GTP-U over a UDP socket (N3), and uses a second UDP socket as a stand-in for N6:
+// IP packets of the data network are carried as UDP payload.
+//
+// Packets are classified by PDI; the Outer Header Removal of the PDR is applied,
+// then the FAR is applied: FORW and DROP are supported, other actions drop the packet.
+// Outer Header Creation GTP-U/UDP/IP and UDP/IP are supported.
+// QERs, URRs and BARs are not applied.
+package userplane
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nextmn/go-pfcp-networking/classifier"
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+// Outer Header Removal Description (TS 29.244, section 8.2.64)
+const (
+	outerHeaderRemovalGTPUUDPIPv4 = 0
+	outerHeaderRemovalGTPUUDPIPv6 = 1
+	outerHeaderRemovalGTPUUDPIP   = 6
+)
+
+// Outer Header Creation Description, first octet (TS 29.244, section 8.2.56)
+const (
+	outerHeaderCreationGTPUUDPIPv4 = 0x01
+	outerHeaderCreationGTPUUDPIPv6 = 0x02
+	outerHeaderCreationUDPIPv4     = 0x04
+	outerHeaderCreationUDPIPv6     = 0x08
+)
+
+// Largest UDP payload
+const maxDatagramLen = 65535
+
+var _ api.DatapathInterface = (*UserPlane)(nil)
+
+// Configuration of a UserPlane
+type Config struct {
+	// GTP-U address (N3); it should be the address of F-TEIDs of the UP function
+	N3Address *net.UDPAddr
+	// Local address of the N6 stand-in
+	N6Address *net.UDPAddr
+	// Packets forwarded toward Core without Outer Header Creation are sent to this address;
+	// when nil, they are dropped
+	N6Peer *net.UDPAddr
+	// Transport of the sockets; default is UDP sockets of the operating system
+	Transport api.TransportInterface
+	// Logger; default is slog.Default()
+	Logger *slog.Logger
+}
+
+// Counters of a UserPlane
+type Stats struct {
+	N3Received uint64
+	N6Received uint64
+	Forwarded  uint64
+	Dropped    uint64
+}
+
+// A UserPlane forwards packets according to the PFCP sessions installed into it.
+// It must be set as datapath of the UP function with SetDatapath.
+type UserPlane struct {
+	config     Config
+	logger     *slog.Logger
+	classifier *classifier.Classifier
+
+	sessions   map[api.PFCPSessionInterface]struct{}
+	sessionsMu sync.RWMutex
+
+	n3      net.PacketConn
+	n6      net.PacketConn
+	closing atomic.Bool
+	wg      sync.WaitGroup
+
+	n3Received atomic.Uint64
+	n6Received atomic.Uint64
+	forwarded  atomic.Uint64
+	dropped    atomic.Uint64
+}
+
+// Create a UserPlane
+func New(config Config) (*UserPlane, error) {
+	if config.N3Address == nil || config.N6Address == nil {
+		return nil, fmt.Errorf("N3 and N6 addresses are required")
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	u := &UserPlane{
+		config:     config,
+		logger:     logger.With(slog.String("component", "userplane")),
+		sessions:   make(map[api.PFCPSessionInterface]struct{}),
+		sessionsMu: sync.RWMutex{},
+	}
+	u.classifier = classifier.NewWithSessions(u.installedSessions)
+	return u, nil
+}
+
+func (u *UserPlane) listen(laddr *net.UDPAddr) (net.PacketConn, error) {
+	if u.config.Transport != nil {
+		return u.config.Transport.ListenUDP(laddr)
+	}
+	return net.ListenUDP("udp", laddr)
+}
+
+// Open the N3 and N6 sockets, and start forwarding packets
+func (u *UserPlane) Start() error {
+	n3, err := u.listen(u.config.N3Address)
+	if err != nil {
+		return err
+	}
+	n6, err := u.listen(u.config.N6Address)
+	if err != nil {
+		n3.Close()
+		return err
+	}
+	u.n3, u.n6 = n3, n6
+	u.wg.Add(2)
+	go u.loop(n3, u.handleN3)
+	go u.loop(n6, u.handleN6)
+	return nil
+}
+
+// Close sockets and wait for packets being forwarded
+func (u *UserPlane) Close() error {
+	if u.n3 == nil {
+		return nil
+	}
+	u.closing.Store(true)
+	err3 := u.n3.Close()
+	err6 := u.n6.Close()
+	u.wg.Wait()
+	if err3 != nil {
+		return err3
+	}
+	return err6
+}
+
+// Local address of the N3 socket
+func (u *UserPlane) N3Addr() net.Addr {
+	return u.n3.LocalAddr()
+}
+
+// Local address of the N6 socket
+func (u *UserPlane) N6Addr() net.Addr {
+	return u.n6.LocalAddr()
+}
+
+func (u *UserPlane) Stats() Stats {
+	return Stats{
+		N3Received: u.n3Received.Load(),
+		N6Received: u.n6Received.Load(),
+		Forwarded:  u.forwarded.Load(),
+		Dropped:    u.dropped.Load(),
+	}
+}
+
+func (u *UserPlane) loop(conn net.PacketConn, handle func([]byte, net.Addr)) {
+	defer u.wg.Done()
+	buf := make([]byte, maxDatagramLen)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !u.closing.Load() {
+				u.logger.Error("Cannot read packet", slog.Any("error", err))
+			}
+			return
+		}
+		b := make([]byte, n)
+		copy(b, buf[:n])
+		handle(b, addr)
+	}
+}
+
+// Handle a GTP-U message received on N3
+func (u *UserPlane) handleN3(b []byte, from net.Addr) {
+	u.n3Received.Add(1)
+	h, payload, err := ParseGTPU(b)
+	if err != nil {
+		u.drop("Malformed GTP-U message", err)
+		return
+	}
+	switch h.MessageType {
+	case MessageTypeEchoRequest:
+		if _, err := u.n3.WriteTo(newEchoResponse(h), from); err != nil {
+			u.logger.Info("Cannot send Echo Response", slog.Any("error", err))
+		}
+		return
+	case MessageTypeGPDU:
+	default:
+		u.drop("Unsupported GTP-U message", fmt.Errorf("Message type %d", h.MessageType))
+		return
+	}
+	p := classifier.Packet{
+		SourceInterface: ie.SrcInterfaceAccess,
+		HasTEID:         true,
+		TEID:            h.TEID,
+		HasQFI:          h.HasQFI,
+		QFI:             h.QFI,
+	}
+	if local := u.config.N3Address.IP; local != nil && !local.IsUnspecified() {
+		p.LocalAddress = local
+	}
+	if err := parseIP(payload, &p); err != nil {
+		u.drop("Malformed inner packet", err)
+		return
+	}
+	m, err := u.classifier.Classify(&p)
+	if err != nil {
+		u.drop("No PDR matches the packet", err)
+		return
+	}
+	if ohr := m.PDR.OuterHeaderRemoval(); ohr != nil {
+		desc, err := ohr.OuterHeaderRemovalDescription()
+		if err == nil && (desc == outerHeaderRemovalGTPUUDPIPv4 || desc == outerHeaderRemovalGTPUUDPIPv6 || desc == outerHeaderRemovalGTPUUDPIP) {
+			b = payload
+		}
+	}
+	u.applyFAR(m, b)
+}
+
+// Handle an IP packet received on N6
+func (u *UserPlane) handleN6(b []byte, from net.Addr) {
+	u.n6Received.Add(1)
+	p := classifier.Packet{
+		SourceInterface: ie.SrcInterfaceCore,
+	}
+	if err := parseIP(b, &p); err != nil {
+		u.drop("Malformed packet", err)
+		return
+	}
+	m, err := u.classifier.Classify(&p)
+	if err != nil {
+		u.drop("No PDR matches the packet", err)
+		return
+	}
+	// the UDP header of the N6 stand-in is already removed
+	u.applyFAR(m, b)
+}
+
+// Apply the FAR of the matching PDR to the packet
+func (u *UserPlane) applyFAR(m *classifier.Match, b []byte) {
+	aa := m.FAR.ApplyAction()
+	if aa == nil || !aa.HasFORW() {
+		u.drop("Packet is not forwarded by the FAR", nil)
+		return
+	}
+	fp := m.FAR.ForwardingParameters()
+	if fp == nil {
+		u.drop("FAR has no Forwarding Parameters", nil)
+		return
+	}
+	ohc, err := fp.OuterHeaderCreation()
+	if err != nil {
+		// no Outer Header Creation: only Core is reachable, through the N6 stand-in
+		dst, err := fp.DestinationInterface()
+		if err != nil || (dst != ie.DstInterfaceCore && dst != ie.DstInterfaceSGiLANN6LAN) || u.config.N6Peer == nil {
+			u.drop("No destination for the packet", err)
+			return
+		}
+		u.send(u.n6, b, u.config.N6Peer)
+		return
+	}
+	desc := uint8(ohc.OuterHeaderCreationDescription >> 8)
+	switch {
+	case desc&outerHeaderCreationGTPUUDPIPv4 != 0 && ohc.IPv4Address != nil:
+		u.sendGTPU(ohc.TEID, b, ohc.IPv4Address)
+	case desc&outerHeaderCreationGTPUUDPIPv6 != 0 && ohc.IPv6Address != nil:
+		u.sendGTPU(ohc.TEID, b, ohc.IPv6Address)
+	case desc&outerHeaderCreationUDPIPv4 != 0 && ohc.IPv4Address != nil:
+		u.send(u.n6, b, &net.UDPAddr{IP: ohc.IPv4Address, Port: int(ohc.PortNumber)})
+	case desc&outerHeaderCreationUDPIPv6 != 0 && ohc.IPv6Address != nil:
+		u.send(u.n6, b, &net.UDPAddr{IP: ohc.IPv6Address, Port: int(ohc.PortNumber)})
+	default:
+		u.drop("Unsupported Outer Header Creation", fmt.Errorf("Description %#04x", ohc.OuterHeaderCreationDescription))
+	}
+}
+
+func (u *UserPlane) sendGTPU(teid uint32, b []byte, addr net.IP) {
+	h := Header{
+		MessageType: MessageTypeGPDU,
+		TEID:        teid,
+	}
+	u.send(u.n3, h.Marshal(b), &net.UDPAddr{IP: addr, Port: GTPUPort})
+}
+
+func (u *UserPlane) send(conn net.PacketConn, b []byte, to *net.UDPAddr) {
+	if _, err := conn.WriteTo(b, to); err != nil {
+		u.drop("Cannot send packet", err)
+		return
+	}
+	u.forwarded.Add(1)
+}
+
+func (u *UserPlane) drop(reason string, err error) {
+	u.dropped.Add(1)
+	if err != nil {
+		u.logger.Debug(reason, slog.Any("error", err))
+	} else {
+		u.logger.Debug(reason)
+	}
+}
+
+func (u *UserPlane) installedSessions() []api.PFCPSessionInterface {
+	u.sessionsMu.RLock()
+	defer u.sessionsMu.RUnlock()
+	sessions := make([]api.PFCPSessionInterface, 0, len(u.sessions))
+	for s := range u.sessions {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// Rules are read from the session when packets are classified: installing a session only makes it visible
+func (u *UserPlane) InstallSession(session api.PFCPSessionInterface, pdrs api.PDRMapInterface, fars api.FARMapInterface) error {
+	u.sessionsMu.Lock()
+	defer u.sessionsMu.Unlock()
+	u.sessions[session] = struct{}{}
+	return nil
+}
+
+func (u *UserPlane) UpdateSession(session api.PFCPSessionInterface, createpdrs api.PDRMapInterface, createfars api.FARMapInterface, updatepdrs api.PDRMapInterface, updatefars api.FARMapInterface) error {
+	u.sessionsMu.RLock()
+	defer u.sessionsMu.RUnlock()
+	if _, exists := u.sessions[session]; !exists {
+		return fmt.Errorf("Session is not installed")
+	}
+	return nil
+}
+
+func (u *UserPlane) RemoveSession(session api.PFCPSessionInterface) error {
+	u.sessionsMu.Lock()
+	defer u.sessionsMu.Unlock()
+	delete(u.sessions, session)
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 Louis Royer and the go-pfcp-networking contributors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+// SPDX-License-Identifier: MIT
+
+package userplane_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nextmn/go-pfcp-networking/pfcp/api"
+	"github.com/nextmn/go-pfcp-networking/pfcptest"
+	"github.com/nextmn/go-pfcp-networking/pfcptransport"
+	"github.com/nextmn/go-pfcp-networking/userplane"
+	"github.com/wmnsk/go-pfcp/ie"
+)
+
+const (
+	testCPNodeID = "10.0.0.1"
+	testUPNodeID = "10.0.0.2"
+	testGNB      = "10.0.0.3"
+	testUE       = "10.45.0.1"
+	testDN       = "192.0.2.1"
+
+	uplinkTEID   = 0x100
+	downlinkTEID = 0x200
+)
+
+var (
+	testN3Address = &net.UDPAddr{IP: net.ParseIP(testUPNodeID), Port: userplane.GTPUPort}
+	testN6Address = &net.UDPAddr{IP: net.ParseIP(testUPNodeID), Port: 9000}
+	testN6Peer    = &net.UDPAddr{IP: net.ParseIP("10.0.1.1"), Port: 9000}
+)
+
+// Returns an IPv4/UDP packet
+func newUDPPacket(src, dst string, srcPort, dstPort uint16, payload []byte) []byte {
+	b := make([]byte, 28, 28+len(payload))
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:4], uint16(28+len(payload)))
+	b[8] = 64
+	b[9] = 17
+	copy(b[12:16], net.ParseIP(src).To4())
+	copy(b[16:20], net.ParseIP(dst).To4())
+	binary.BigEndian.PutUint16(b[20:22], srcPort)
+	binary.BigEndian.PutUint16(b[22:24], dstPort)
+	binary.BigEndian.PutUint16(b[24:26], uint16(8+len(payload)))
+	return append(b, payload...)
+}
+
+func listen(t *testing.T, network *pfcptransport.MemoryNetwork, addr *net.UDPAddr) net.PacketConn {
+	t.Helper()
+	conn, err := network.ListenUDP(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func read(t *testing.T, conn net.PacketConn) []byte {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 65535)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+// A session is established by a CP function on a UP function using the UserPlane as datapath,
+// then a G-PDU is forwarded from N3 to N6, and the reply from N6 to N3
+func TestForwarding(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	network := pfcptransport.NewMemoryNetwork()
+	options := pfcptest.Options{Transport: network, Logger: logger}
+
+	u, err := userplane.New(userplane.Config{
+		N3Address: testN3Address,
+		N6Address: testN6Address,
+		N6Peer:    testN6Peer,
+		Transport: network,
+		Logger:    logger,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	up, err := pfcptest.NewFakeUP(testUPNodeID, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := up.Entity().SetDatapath(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := up.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer up.Close()
+	cp, err := pfcptest.NewFakeCP(testCPNodeID, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+	if _, err := cp.Associate(testUPNodeID); err != nil {
+		t.Fatal(err)
+	}
+	_, res, err := cp.EstablishSession(testUPNodeID,
+		ie.NewCreatePDR(ie.NewPDRID(1), ie.NewPrecedence(1), ie.NewPDI(
+			ie.NewSourceInterface(ie.SrcInterfaceAccess),
+			ie.NewFTEID(0x01, uplinkTEID, net.ParseIP(testUPNodeID), nil, 0),
+			ie.NewUEIPAddress(api.UEIPFlagV4, testUE, "", 0, 0),
+		), ie.NewOuterHeaderRemoval(0, 0), ie.NewFARID(1)),
+		ie.NewCreatePDR(ie.NewPDRID(2), ie.NewPrecedence(1), ie.NewPDI(
+			ie.NewSourceInterface(ie.SrcInterfaceCore),
+			ie.NewUEIPAddress(api.UEIPFlagV4|api.UEIPFlagSD, testUE, "", 0, 0),
+		), ie.NewFARID(2)),
+		ie.NewCreateFAR(ie.NewFARID(1), ie.NewApplyAction(0x02), ie.NewForwardingParameters(
+			ie.NewDestinationInterface(ie.DstInterfaceCore),
+		)),
+		ie.NewCreateFAR(ie.NewFARID(2), ie.NewApplyAction(0x02), ie.NewForwardingParameters(
+			ie.NewDestinationInterface(ie.DstInterfaceAccess),
+			ie.NewOuterHeaderCreation(0x0100, downlinkTEID, testGNB, "", 0, 0, 0),
+		)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cause, err := res.Cause.Cause(); err != nil || cause != ie.CauseRequestAccepted {
+		t.Fatalf("session has not been established: cause %d (%v)", cause, err)
+	}
+
+	gnb := listen(t, network, &net.UDPAddr{IP: net.ParseIP(testGNB), Port: userplane.GTPUPort})
+	dn := listen(t, network, testN6Peer)
+
+	// uplink
+	uplink := newUDPPacket(testUE, testDN, 1024, 53, []byte("query"))
+	h := userplane.Header{MessageType: userplane.MessageTypeGPDU, TEID: uplinkTEID, HasQFI: true, PDUType: userplane.PDUTypeUplink, QFI: 9}
+	if _, err := gnb.WriteTo(h.Marshal(uplink), testN3Address); err != nil {
+		t.Fatal(err)
+	}
+	if b := read(t, dn); !bytes.Equal(b, uplink) {
+		t.Errorf("got %x on N6, expected %x", b, uplink)
+	}
+
+	// downlink
+	downlink := newUDPPacket(testDN, testUE, 53, 1024, []byte("answer"))
+	if _, err := dn.WriteTo(downlink, testN6Address); err != nil {
+		t.Fatal(err)
+	}
+	gh, payload, err := userplane.ParseGTPU(read(t, gnb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gh.MessageType != userplane.MessageTypeGPDU || gh.TEID != downlinkTEID {
+		t.Errorf("got message type %d with TEID %#x, expected G-PDU with TEID %#x", gh.MessageType, gh.TEID, downlinkTEID)
+	}
+	if !bytes.Equal(payload, downlink) {
+		t.Errorf("got %x on N3, expected %x", payload, downlink)
+	}
+
+	// Forwarded is counted after the packet is sent, and may not be up to date yet
+	if stats := u.Stats(); stats.N3Received != 1 || stats.N6Received != 1 || stats.Dropped != 0 {
+		t.Errorf("got stats %+v, expected 1 packet received on N3 and N6, and none dropped", stats)
+	}
+}